@@ -0,0 +1,505 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/codeskyblue/go-sh"
+	"github.com/docker/go-units"
+	"github.com/fatih/color"
+	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
+	"github.com/meshplus/goduck/cmd/goduck/pier"
+	"github.com/meshplus/goduck/internal/download"
+	"github.com/meshplus/goduck/internal/release"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+// linkedVersion is the version shown for the linked binaries
+const linkedVersion = "linked"
+
+// libraries are the shared libraries linked along with the binaries
+var libraries = []string{"libwasmer.so", "libwasmer.dylib"}
+
+// Binary is a binary cached in $repo/bin or linked by `goduck bin link`.
+type Binary struct {
+	Component string `json:"component" yaml:"component"`
+	Version   string `json:"version" yaml:"version"`
+	Platform  string `json:"platform" yaml:"platform"`
+	Path      string `json:"path" yaml:"path"`
+	Size      int64  `json:"size" yaml:"size"`
+	SHA256    string `json:"sha256" yaml:"sha256"`
+}
+
+func binCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "bin",
+		Usage: "Manage the cached and linked binaries",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "List the cached and linked binaries",
+				Action: listBin,
+			},
+			{
+				Name:  "prune",
+				Usage: "Remove the binaries of the releases no longer supported and the partial downloads",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "all",
+						Usage: "Remove all the cached binaries, the linked ones are kept",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only print what would be removed",
+					},
+				},
+				Action: pruneBin,
+			},
+			{
+				Name:  "fetch",
+				Usage: "Download the BitXHub release, its compatible piers and their plugins",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Aliases:  []string{"version", "v"},
+						Usage:    "BitXHub version",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "system",
+						Value: runtime.GOOS,
+						Usage: "Specify the system of the binaries, one of linux or darwin",
+					},
+					&cli.StringFlag{
+						Name:  "arch",
+						Value: runtime.GOARCH,
+						Usage: "Specify the architecture of the binaries, e.g. amd64 or arm64",
+					},
+				},
+				Action: fetchBin,
+			},
+			{
+				Name:  "link",
+				Usage: "Use a locally built binary instead of the releases",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "component",
+						Usage:    "Specify the component, one of bitxhub or pier",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "path",
+						Usage:    "Specify the path of the binary",
+						Required: true,
+					},
+				},
+				Action: linkBin,
+			},
+			{
+				Name:  "unlink",
+				Usage: "Use the releases again instead of the linked binary",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "component",
+						Usage:    "Specify the component, one of bitxhub or pier",
+						Required: true,
+					},
+				},
+				Action: unlinkBin,
+			},
+		},
+	}
+}
+
+func listBin(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+
+	dirs, err := binDirs(repoRoot)
+	if err != nil {
+		return err
+	}
+
+	binaries := make([]*Binary, 0)
+	table := [][]string{{"Component", "Version", "Platform", "Path", "Size", "SHA256"}}
+	for _, dir := range dirs {
+		component, platform, version := parseBinDir(filepath.Base(dir))
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			path := filepath.Join(dir, file.Name())
+			// the linked binaries are symlinks
+			info, err := os.Stat(path)
+			if err != nil || !isBinary(info) {
+				continue
+			}
+			if version == linkedVersion {
+				if path, err = filepath.EvalSymlinks(path); err != nil {
+					return err
+				}
+			}
+			sum, err := download.SHA256(path)
+			if err != nil {
+				return err
+			}
+
+			binaries = append(binaries, &Binary{
+				Component: component,
+				Version:   version,
+				Platform:  platform,
+				Path:      path,
+				Size:      info.Size(),
+				SHA256:    sum,
+			})
+			table = append(table, []string{component, version, platform, path, units.HumanSize(float64(info.Size())), sum})
+		}
+	}
+
+	return printOutput(ctx, binaries, table)
+}
+
+func pruneBin(ctx *cli.Context) error {
+	all := ctx.Bool("all")
+	dryRun := ctx.Bool("dry-run")
+
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+	m, err := release.Load(repoRoot)
+	if err != nil {
+		return err
+	}
+
+	dirs, err := binDirs(repoRoot)
+	if err != nil {
+		return err
+	}
+
+	var removed []string
+	for _, dir := range dirs {
+		component, _, version := parseBinDir(filepath.Base(dir))
+		if version == linkedVersion {
+			continue
+		}
+
+		if all || !supported(m, component, version) {
+			removed = append(removed, dir)
+			continue
+		}
+
+		// the partial downloads left by the interrupted ones
+		parts, err := filepath.Glob(filepath.Join(dir, "*.part"))
+		if err != nil {
+			return err
+		}
+		removed = append(removed, parts...)
+	}
+
+	var freed int64
+	for _, path := range removed {
+		size, err := diskUsage(path)
+		if err != nil {
+			return err
+		}
+		freed += size
+
+		if dryRun {
+			color.Blue("Would remove %s", path)
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		color.Blue("Removed %s", path)
+	}
+
+	if dryRun {
+		color.Green("%s would be freed", units.HumanSize(float64(freed)))
+	} else {
+		color.Green("%s freed", units.HumanSize(float64(freed)))
+	}
+
+	return nil
+}
+
+func fetchBin(ctx *cli.Context) error {
+	version := ctx.String("version")
+	system := ctx.String("system")
+	arch := ctx.String("arch")
+
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+	m, err := release.Load(repoRoot)
+	if err != nil {
+		return err
+	}
+	bxh, err := m.FindBitXHub(version)
+	if err != nil {
+		return err
+	}
+
+	if err := bitxhub.DownloadBitxhubBinary(repoRoot, version, system, arch); err != nil {
+		return fmt.Errorf("download bitxhub binary error:%w", err)
+	}
+	if err := bitxhub.ExtractBitxhubBinary(repoRoot, version, system, arch); err != nil {
+		return fmt.Errorf("extract bitxhub binary error:%w", err)
+	}
+
+	for _, pierVersion := range bxh.Pier {
+		p, err := m.FindPier(pierVersion)
+		if err != nil {
+			return err
+		}
+		if err := pier.DownloadPierBinary(repoRoot, p.Version, system, arch); err != nil {
+			return fmt.Errorf("download pier binary error:%w", err)
+		}
+
+		var appchains []string
+		for appchain := range p.Plugins {
+			appchains = append(appchains, appchain)
+		}
+		sort.Strings(appchains)
+		for _, appchain := range appchains {
+			if err := pier.DownloadPierPlugin(repoRoot, appchain, p.Version, system, arch); err != nil {
+				return fmt.Errorf("download pier %s plugin error:%w", appchain, err)
+			}
+		}
+	}
+
+	color.Green("Fetched BitXHub %s and pier %s for %s/%s", version, strings.Join(bxh.Pier, ", "), system, arch)
+
+	return nil
+}
+
+func linkBin(ctx *cli.Context) error {
+	component := ctx.String("component")
+
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+	if err := checkLinkComponent(component); err != nil {
+		return err
+	}
+
+	path, err := filepath.Abs(ctx.String("path"))
+	if err != nil {
+		return fmt.Errorf("get absolute binary path: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || info.Mode()&0111 == 0 {
+		return fmt.Errorf("%s is not an executable file", path)
+	}
+
+	dir := repo.GetLinkPath(repoRoot, component)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.Symlink(path, filepath.Join(dir, component)); err != nil {
+		return err
+	}
+	// the binary is run with the libraries built along with it
+	for _, lib := range libraries {
+		libPath := filepath.Join(filepath.Dir(path), lib)
+		if _, err := os.Stat(libPath); err != nil {
+			continue
+		}
+		if err := os.Symlink(libPath, filepath.Join(dir, lib)); err != nil {
+			return err
+		}
+	}
+
+	color.Green("Linked %s to %s, it is used instead of the releases until `goduck bin unlink --component %s`", component, path, component)
+
+	return nil
+}
+
+func unlinkBin(ctx *cli.Context) error {
+	component := ctx.String("component")
+
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+	if err := checkLinkComponent(component); err != nil {
+		return err
+	}
+	if !repo.Linked(repoRoot, component) {
+		return fmt.Errorf("no %s binary is linked", component)
+	}
+
+	if err := os.RemoveAll(repo.GetLinkPath(repoRoot, component)); err != nil {
+		return err
+	}
+
+	color.Green("Unlinked %s, the releases are used again", component)
+
+	return nil
+}
+
+// bitxhubBinary downloads the BitXHub release of the version for this host and
+// returns the directory of the binary to run, the linked one takes precedence.
+func bitxhubBinary(repoRoot, version string) (string, error) {
+	if repo.Linked(repoRoot, "bitxhub") {
+		return repo.GetLinkPath(repoRoot, "bitxhub"), nil
+	}
+
+	if err := bitxhub.DownloadBitxhubBinary(repoRoot, version, runtime.GOOS, runtime.GOARCH); err != nil {
+		return "", fmt.Errorf("download binary error:%w", err)
+	}
+	if err := bitxhub.ExtractBitxhubBinary(repoRoot, version, runtime.GOOS, runtime.GOARCH); err != nil {
+		return "", fmt.Errorf("extract binary error:%w", err)
+	}
+
+	return repo.GetBinPath(repoRoot, "bitxhub", runtime.GOOS, runtime.GOARCH, version), nil
+}
+
+// pierBinary downloads the pier release of the version for this host and
+// returns the directory of the binary to run, the linked one takes precedence.
+func pierBinary(repoRoot, version string) (string, error) {
+	if repo.Linked(repoRoot, "pier") {
+		return repo.GetLinkPath(repoRoot, "pier"), nil
+	}
+
+	if err := pier.DownloadPierBinary(repoRoot, version, runtime.GOOS, runtime.GOARCH); err != nil {
+		return "", fmt.Errorf("download pier binary error:%w", err)
+	}
+
+	return repo.GetBinPath(repoRoot, "pier", runtime.GOOS, runtime.GOARCH, version), nil
+}
+
+// packLinked packs the linked binary of the component into a tarball in dir
+// to deploy it, it returns false if no binary is linked for the platform.
+func packLinked(repoRoot, component, system, arch, dir string) (string, bool, error) {
+	if !repo.Linked(repoRoot, component) {
+		return "", false, nil
+	}
+	if system != runtime.GOOS || arch != runtime.GOARCH {
+		color.Yellow("The linked %s is built for %s/%s, the release for %s/%s is deployed", component, runtime.GOOS, runtime.GOARCH, system, arch)
+		return "", false, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", false, err
+	}
+	name := component + "_linked.tar.gz"
+	// h follows the symlinks to pack the binaries themselves
+	err := sh.Command("tar", "czhf", filepath.Join(dir, name), "-C", repo.GetLinkPath(repoRoot, component), ".").Run()
+	if err != nil {
+		return "", false, fmt.Errorf("pack linked %s: %w", component, err)
+	}
+
+	return name, true, nil
+}
+
+func checkLinkComponent(component string) error {
+	if component != "bitxhub" && component != "pier" {
+		return fmt.Errorf("unsupported component %s, one of bitxhub or pier", component)
+	}
+
+	return nil
+}
+
+// binDirs returns the directories under $repo/bin
+func binDirs(repoRoot string) ([]string, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(repoRoot, "bin"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var dirs []string
+	for _, info := range infos {
+		if info.IsDir() {
+			dirs = append(dirs, filepath.Join(repoRoot, "bin", info.Name()))
+		}
+	}
+
+	return dirs, nil
+}
+
+// parseBinDir parses the directory names like bitxhub_linux_v2.8.0,
+// pier_linux-arm64_v2.8.0 and bitxhub_link.
+func parseBinDir(name string) (component, platform, version string) {
+	fields := strings.SplitN(name, "_", 3)
+	if len(fields) == 2 && fields[1] == "link" {
+		return fields[0], runtime.GOOS + "/" + runtime.GOARCH, linkedVersion
+	}
+	if len(fields) != 3 {
+		return name, "", ""
+	}
+
+	platform = fields[1]
+	if !strings.Contains(platform, "-") {
+		platform += "-" + types.AMD64Arch
+	}
+
+	return fields[0], strings.Replace(platform, "-", "/", 1), fields[2]
+}
+
+// supported reports whether the release of the component is still in the
+// manifest, the unknown components are always kept.
+func supported(m *release.Manifest, component, version string) bool {
+	switch component {
+	case "bitxhub":
+		_, err := m.FindBitXHub(version)
+		return err == nil
+	case "pier":
+		_, err := m.FindPier(version)
+		return err == nil
+	case "geth":
+		return version == types.GethVersion
+	default:
+		return true
+	}
+}
+
+func isBinary(info os.FileInfo) bool {
+	if !info.Mode().IsRegular() || info.Mode()&0111 == 0 {
+		return false
+	}
+	for _, lib := range libraries {
+		if info.Name() == lib {
+			return false
+		}
+	}
+
+	return !strings.HasSuffix(info.Name(), ".part")
+}
+
+// diskUsage returns the total size of the files under the path
+func diskUsage(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+
+	return size, err
+}
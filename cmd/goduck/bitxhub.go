@@ -1,15 +1,18 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"runtime"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
 
+	"github.com/fatih/color"
 	"github.com/meshplus/bitxhub-kit/fileutil"
 	"github.com/meshplus/goduck/internal/repo"
 	"github.com/meshplus/goduck/internal/types"
@@ -17,24 +20,6 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
-type Release struct {
-	Bitxhub []string `json:"bitxhub"`
-	Pier    []string `json:"pier"`
-}
-
-var bxhConfigMap = map[string]string{
-	"v1.6.1":  "v1.6.1",
-	"v1.6.5":  "v1.6.5", // same to 1.6.1
-	"v1.7.0":  "v1.7.0",
-	"v1.8.0":  "v1.8.0",
-	"v1.9.0":  "v1.9.0",
-	"v1.11.0": "v1.11.0",
-	"v1.11.1": "v1.11.0",
-	"v1.11.3": "v1.11.3",
-	"v1.23.0": "v1.23.0",
-	"v2.8.0":  "v2.8.0",
-}
-
 func bitxhubCMD() *cli.Command {
 	return &cli.Command{
 		Name:  "bitxhub",
@@ -62,14 +47,69 @@ func bitxhubCMD() *cli.Command {
 						Value:   "v2.8.0",
 						Usage:   "BitXHub version",
 					},
+					&cli.IntSliceFlag{
+						Name:  "node",
+						Usage: "Specify the index of the binary node to operate on, e.g. --node 1 --node 3, default: all nodes",
+					},
+					&cli.StringFlag{
+						Name:  "restart",
+						Value: bitxhub.RestartNo,
+						Usage: "Restart policy of binary nodes, one of no, on-failure or always. goduck keeps watching the nodes in foreground if it is not no",
+					},
+					&cli.IntFlag{
+						Name:  "max-restarts",
+						Value: 3,
+						Usage: "Max restarts of each binary node, 0 means unlimited",
+					},
+					basePortFlag(),
+					genesisFlag(),
 				},
 				Action: startBitXHub,
 			},
 			{
-				Name:   "stop",
-				Usage:  "Stop BitXHub nodes",
+				Name:  "stop",
+				Usage: "Stop BitXHub nodes",
+				Flags: []cli.Flag{
+					&cli.IntSliceFlag{
+						Name:  "node",
+						Usage: "Specify the index of the binary node to operate on, e.g. --node 1 --node 3, default: all nodes",
+					},
+				},
 				Action: stopBitXHub,
 			},
+			{
+				Name:  "restart",
+				Usage: "Restart BitXHub nodes started from binary",
+				Flags: []cli.Flag{
+					&cli.IntSliceFlag{
+						Name:  "node",
+						Usage: "Specify the index of the binary node to operate on, e.g. --node 1 --node 3, default: all nodes",
+					},
+				},
+				Action: restartBitXHub,
+			},
+			{
+				Name:  "watch",
+				Usage: "Watch BitXHub nodes started from binary, report crashed nodes and restart them by policy",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "restart",
+						Value: bitxhub.RestartNo,
+						Usage: "Restart policy of binary nodes, one of no, on-failure or always. goduck keeps watching the nodes in foreground if it is not no",
+					},
+					&cli.IntFlag{
+						Name:  "max-restarts",
+						Value: 3,
+						Usage: "Max restarts of each binary node, 0 means unlimited",
+					},
+					&cli.DurationFlag{
+						Name:  "interval",
+						Value: 2 * time.Second,
+						Usage: "Interval to check the nodes",
+					},
+				},
+				Action: watchBitXHub,
+			},
 			{
 				Name:   "clean",
 				Usage:  "Clean BitXHub nodes",
@@ -92,9 +132,25 @@ func bitxhubCMD() *cli.Command {
 						Value:   "v2.8.0",
 						Usage:   "BitXHub version",
 					},
+					basePortFlag(),
+					genesisFlag(),
 				},
 				Action: generateBitXHubConfig,
+				Subcommands: []*cli.Command{
+					{
+						Name:  "validate",
+						Usage: "Validate the keys, certs, genesis and ports of generated BitXHub nodes",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "target",
+								Usage: "Specify the directory of the generated configuration files, default: $repo/bitxhub/.bitxhub/",
+							},
+						},
+						Action: validateBitXHubConfig,
+					},
+				},
 			},
+			bitxhubNodeCMD(),
 		},
 	}
 }
@@ -107,12 +163,79 @@ func stopBitXHub(ctx *cli.Context) error {
 	if !fileutil.Exist(filepath.Join(repoRoot, types.PlaygroundScript)) {
 		return fmt.Errorf("please `goduck init` first")
 	}
+
+	return stopBitXHubNetwork(repoRoot, ctx.IntSlice("node"))
+}
+
+func stopBitXHubNetwork(repoRoot string, indexes []int) error {
+	sup, err := bitxhub.NewSupervisor(repoRoot)
+	if err != nil {
+		return err
+	}
+	reportCrashedNodes(sup)
+
+	nodes := nodeNames(sup.State().Mode, indexes)
+	if len(sup.State().Nodes) != 0 {
+		if err := sup.Stop(nodes...); err != nil {
+			return fmt.Errorf("stop binary nodes: %w", err)
+		}
+	}
+	if len(nodes) != 0 {
+		if len(sup.State().Nodes) == 0 {
+			return fmt.Errorf("%s is not managed by goduck", strings.Join(nodes, ","))
+		}
+		return nil
+	}
+
 	args := make([]string, 0)
 	args = append(args, filepath.Join(repoRoot, types.PlaygroundScript), "down")
 
 	return utils.ExecuteShell(args, repoRoot)
 }
 
+func restartBitXHub(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return fmt.Errorf("parse repo path error:%w", err)
+	}
+	if !fileutil.Exist(filepath.Join(repoRoot, types.PlaygroundScript)) {
+		return fmt.Errorf("please `goduck init` first")
+	}
+
+	sup, err := bitxhub.NewSupervisor(repoRoot)
+	if err != nil {
+		return err
+	}
+	reportCrashedNodes(sup)
+
+	return sup.Restart(nodeNames(sup.State().Mode, ctx.IntSlice("node"))...)
+}
+
+func watchBitXHub(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return fmt.Errorf("parse repo path error:%w", err)
+	}
+	if !fileutil.Exist(filepath.Join(repoRoot, types.PlaygroundScript)) {
+		return fmt.Errorf("please `goduck init` first")
+	}
+
+	sup, err := bitxhub.NewSupervisor(repoRoot)
+	if err != nil {
+		return err
+	}
+	if len(sup.State().Nodes) == 0 {
+		return fmt.Errorf("no bitxhub node found, please `goduck bitxhub start` first")
+	}
+	reportCrashedNodes(sup)
+
+	if err := sup.SetRestartPolicy(ctx.String("restart"), ctx.Int("max-restarts")); err != nil {
+		return err
+	}
+
+	return watchNodes(sup, ctx.Duration("interval"))
+}
+
 func startBitXHub(ctx *cli.Context) error {
 	typ := ctx.String("type")
 	configPath := ctx.String("configPath")
@@ -128,24 +251,30 @@ func startBitXHub(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	// 2. check version
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, "release.json"))
-	if err != nil {
-		return err
-	}
+	// start the given nodes of the running network
+	if len(ctx.IntSlice("node")) != 0 {
+		sup, err := bitxhub.NewSupervisor(repoRoot)
+		if err != nil {
+			return err
+		}
+		reportCrashedNodes(sup)
 
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
-		return err
+		return sup.Start(nodeNames(sup.State().Mode, ctx.IntSlice("node"))...)
 	}
 
-	if !AdjustVersion(version, release.Bitxhub) {
-		return fmt.Errorf("unsupport BitXHub verison")
+	return startBitXHubNetwork(repoRoot, typ, version, configPath, target, ctx.String("restart"), ctx.Int("max-restarts"), ctx.StringSlice("base-port"), ctx.String("genesis"))
+}
+
+func startBitXHubNetwork(repoRoot, typ, version, configPath, target, policy string, maxRestarts int, basePorts []string, genesisPath string) error {
+	// 2. check version
+	r, err := bxhRelease(repoRoot, version)
+	if err != nil {
+		return err
 	}
 
 	// 3. get args and bin
 	if configPath == "" {
-		configPath = filepath.Join(repoRoot, fmt.Sprintf("bxh_config/%s/%s", bxhConfigMap[version], types.BxhModifyConfig))
+		configPath = filepath.Join(repoRoot, fmt.Sprintf("bxh_config/%s/%s", r.Config, types.BxhModifyConfig))
 	} else {
 		configPath, err = filepath.Abs(configPath)
 		if err != nil {
@@ -163,10 +292,7 @@ func startBitXHub(ctx *cli.Context) error {
 	}
 
 	if typ == types.TypeBinary {
-		err := bitxhub.DownloadBitxhubBinary(repoRoot, version, runtime.GOOS)
-		if err != nil {
-			return fmt.Errorf("download binary error:%w", err)
-		}
+		return startBinaryBitXHub(repoRoot, version, configPath, target, policy, maxRestarts, basePorts, genesisPath)
 	}
 
 	// 4. execute
@@ -176,13 +302,103 @@ func startBitXHub(ctx *cli.Context) error {
 	return utils.ExecuteShell(args, repoRoot)
 }
 
-func AdjustVersion(version string, release []string) bool {
-	for _, bxhRelease := range release {
-		if version == bxhRelease {
-			return true
+func startBinaryBitXHub(repoRoot, version, configPath, target, policy string, maxRestarts int, basePorts []string, genesisPath string) error {
+	modifyConfig, err := repo.LoadModifyConfig(configPath)
+	if err != nil {
+		return err
+	}
+	mode := modifyConfig.Get("mode")
+	num := 1
+	if mode == types.ClusterMode {
+		num, err = modifyConfig.Int("num")
+		if err != nil {
+			return err
 		}
+	} else if mode != types.SoloMode {
+		return fmt.Errorf("mode should be solo or cluster")
 	}
-	return false
+
+	sup, err := bitxhub.NewSupervisor(repoRoot)
+	if err != nil {
+		return err
+	}
+	reportCrashedNodes(sup)
+
+	binPath, err := bitxhubBinary(repoRoot, version)
+	if err != nil {
+		return err
+	}
+	if err := sup.Setup(version, mode, binPath, target, num); err != nil {
+		return err
+	}
+
+	nodeRepo := filepath.Join(target, bitxhub.NodeName(mode, 1))
+	if fileutil.Exist(nodeRepo) {
+		rewrite := modifyConfig.Bool("rewrite")
+		color.Blue("BitXHub %s configuration file already exists", mode)
+		color.Blue("reinitializing would overwrite your configuration? (%t)", rewrite)
+		if rewrite {
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !fileutil.Exist(nodeRepo) {
+		if err := generateBitXHubConfigFiles(repoRoot, version, target, configPath, basePorts, genesisPath); err != nil {
+			return err
+		}
+	}
+
+	color.Blue("======> Start bitxhub %s by binary", mode)
+	if err := sup.Start(); err != nil {
+		return err
+	}
+	if err := sup.SetRestartPolicy(policy, maxRestarts); err != nil {
+		return err
+	}
+
+	color.Blue("You can use the \"goduck status list\" command to check the status of the startup BitXHub node.")
+	if version == "v1.8.0" {
+		color.Blue("Note: To register the appchain, you need to execute the \"bitxhub client did init\" command.")
+	}
+
+	if policy == bitxhub.RestartNo {
+		return nil
+	}
+
+	return watchNodes(sup, 2*time.Second)
+}
+
+func watchNodes(sup *bitxhub.Supervisor, interval time.Duration) error {
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	color.Blue("Watching bitxhub nodes with restart policy %s, press Ctrl+C to exit, the nodes keep running", sup.State().Policy)
+	return sup.Watch(c, interval)
+}
+
+func reportCrashedNodes(sup *bitxhub.Supervisor) {
+	for _, node := range sup.Refresh() {
+		color.Red("bitxhub %s(pid: %d) exited unexpectedly, see %s", node.Name, node.Pid, filepath.Join(node.Repo, types.BitxhubNodeOutput))
+	}
+}
+
+func nodeNames(mode string, nodes []int) []string {
+	var names []string
+	for _, i := range nodes {
+		names = append(names, bitxhub.NodeName(mode, i))
+	}
+
+	return names
 }
 
 func cleanBitXHub(ctx *cli.Context) error {
@@ -193,6 +409,25 @@ func cleanBitXHub(ctx *cli.Context) error {
 	if !fileutil.Exist(filepath.Join(repoRoot, types.PlaygroundScript)) {
 		return fmt.Errorf("please `goduck init` first")
 	}
+	sup, err := bitxhub.NewSupervisor(repoRoot)
+	if err != nil {
+		return err
+	}
+	if len(sup.State().Nodes) != 0 {
+		if err := sup.Stop(); err != nil {
+			return fmt.Errorf("stop binary nodes: %w", err)
+		}
+	}
+
+	if err := releasePorts(repoRoot, filepath.Join(repoRoot, "bitxhub/.bitxhub")); err != nil {
+		return fmt.Errorf("release ports: %w", err)
+	}
+	if target := sup.State().Target; target != "" {
+		if err := releasePorts(repoRoot, target); err != nil {
+			return fmt.Errorf("release ports: %w", err)
+		}
+	}
+
 	args := make([]string, 0)
 	args = append(args, filepath.Join(repoRoot, types.PlaygroundScript), "clean")
 	return utils.ExecuteShell(args, repoRoot)
@@ -211,20 +446,11 @@ func generateBitXHubConfig(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, "release.json"))
+	r, err := bxhRelease(repoRoot, version)
 	if err != nil {
 		return err
 	}
 
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
-		return err
-	}
-
-	if !AdjustVersion(version, release.Bitxhub) {
-		return fmt.Errorf("unsupport BitXHub verison")
-	}
-
 	if target == "" {
 		target = filepath.Join(repoRoot, fmt.Sprintf("bitxhub/.bitxhub"))
 	} else {
@@ -234,34 +460,82 @@ func generateBitXHubConfig(ctx *cli.Context) error {
 		}
 	}
 
+	if configPath == "" {
+		configPath = filepath.Join(repoRoot, fmt.Sprintf("bxh_config/%s/%s", r.Config, types.BxhModifyConfig))
+	} else {
+		configPath, err = filepath.Abs(configPath)
+		if err != nil {
+			return fmt.Errorf("get absolute config path: %w", err)
+		}
+	}
+
+	return generateBitXHubConfigFiles(repoRoot, version, target, configPath, ctx.StringSlice("base-port"), ctx.String("genesis"))
+}
+
+func generateBitXHubConfigFiles(repoRoot, version, target, configPath string, basePorts []string, genesisPath string) error {
 	if _, err := os.Stat(target); os.IsNotExist(err) {
 		if err := os.MkdirAll(target, 0755); err != nil {
 			return err
 		}
 	}
 
-	if configPath == "" {
-		configPath = filepath.Join(repoRoot, fmt.Sprintf("bxh_config/%s/%s", bxhConfigMap[version], types.BxhModifyConfig))
-	} else {
-		configPath, err = filepath.Abs(configPath)
-		if err != nil {
-			return fmt.Errorf("get absolute config path: %w", err)
+	configPath, err := allocateBxhPorts(repoRoot, target, configPath, basePorts)
+	if err != nil {
+		return fmt.Errorf("allocate ports: %w", err)
+	}
+
+	// check the genesis spec before generating anything
+	spec, err := LoadGenesisSpec(genesisPath)
+	if err != nil {
+		return err
+	}
+	modifyConfig, err := repo.LoadModifyConfig(configPath)
+	if err != nil {
+		return err
+	}
+	mode := modifyConfig.Get("bitxhub.mode")
+	num := 1
+	if mode == types.ClusterMode {
+		if num, err = modifyConfig.Int("bitxhub.num"); err != nil {
+			return err
 		}
+		if spec.Consensus != "" {
+			if err := modifyConfig.Set("bitxhub.consensus_type", spec.Consensus); err != nil {
+				return err
+			}
+			if err := modifyConfig.Write(configPath); err != nil {
+				return err
+			}
+		}
+	}
+	if err := spec.Validate(version, mode, num); err != nil {
+		return fmt.Errorf("invalid genesis spec: %w", err)
+	}
+	if err := applyBitXHubCA(target, modifyConfig); err != nil {
+		return err
 	}
 
-	err = bitxhub.DownloadBitxhubBinary(repoRoot, version, runtime.GOOS)
+	r, err := bxhRelease(repoRoot, version)
 	if err != nil {
-		return fmt.Errorf("download binary error:%w", err)
+		return err
 	}
-	err = bitxhub.ExtractBitxhubBinary(repoRoot, version, runtime.GOOS)
+
+	binPath, err := bitxhubBinary(repoRoot, version)
 	if err != nil {
-		return fmt.Errorf("extract binary error:%w", err)
+		return err
 	}
-	binPath := filepath.Join(repoRoot, fmt.Sprintf("bin/%s", fmt.Sprintf("bitxhub_%s_%s", runtime.GOOS, version)))
 	fmt.Println(binPath)
 
 	args := make([]string, 0)
-	args = append(args, filepath.Join(repoRoot, types.BxhConfigRepo, bxhConfigMap[version], types.BitxhubConfigScript))
+	args = append(args, filepath.Join(repoRoot, types.BxhConfigRepo, r.Config, types.BitxhubConfigScript))
 	args = append(args, "-t", target, "-b", binPath, "-p", configPath)
-	return utils.ExecuteShell(args, repoRoot)
+	if err := utils.ExecuteShell(args, repoRoot); err != nil {
+		return err
+	}
+
+	if genesisPath == "" {
+		return nil
+	}
+
+	return applyGenesisSpec(target, version, mode, spec)
 }
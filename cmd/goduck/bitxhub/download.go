@@ -8,52 +8,52 @@ import (
 	"github.com/codeskyblue/go-sh"
 	"github.com/meshplus/bitxhub-kit/fileutil"
 	"github.com/meshplus/goduck/internal/download"
+	"github.com/meshplus/goduck/internal/release"
+	"github.com/meshplus/goduck/internal/repo"
 	"github.com/meshplus/goduck/internal/types"
 )
 
-func DownloadBitxhubBinary(repoPath string, version string, system string) error {
-	path := fmt.Sprintf("bitxhub_%s_%s", system, version)
-	root := filepath.Join(repoPath, "bin", path)
+func DownloadBitxhubBinary(repoPath string, version string, system string, arch string) error {
+	artifact, checksums, err := bitxhubArtifact(repoPath, version, system, arch)
+	if err != nil {
+		return err
+	}
+
+	root := repo.GetBinPath(repoPath, "bitxhub", system, arch, version)
 	if !fileutil.Exist(root) {
 		err := os.MkdirAll(root, 0755)
 		if err != nil {
 			return err
 		}
 	}
+	url := fmt.Sprintf(types.BitxhubReleaseUrl, version, artifact.Name)
+	tarPath := filepath.Join(root, artifact.Name)
 
-	if system == types.LinuxSystem {
-		if !fileutil.Exist(filepath.Join(root, "bitxhub")) {
-			url := fmt.Sprintf(types.BitxhubUrlLinux, version, version)
-			err := download.Download(root, url)
-			if err != nil {
-				return err
-			}
+	// the binary may be extracted from a truncated tarball left by an
+	// interrupted download, so it is trusted only if the tarball is complete
+	if !fileutil.Exist(filepath.Join(root, "bitxhub")) || !checksums.Complete(tarPath) {
+		// remove the stale binary so that it is extracted again
+		if err := os.RemoveAll(filepath.Join(root, "bitxhub")); err != nil {
+			return err
 		}
-	}
-	if system == types.DarwinSystem {
-		if !fileutil.Exist(filepath.Join(root, "bitxhub")) {
-			url := fmt.Sprintf(types.BitxhubUrlMacOS, version, version)
-			err := download.Download(root, url)
-			if err != nil {
-				return err
-			}
+		err := download.Download(tarPath, url, download.WithManifest(checksums))
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func ExtractBitxhubBinary(repoPath string, version string, system string) error {
-	path := filepath.Join(repoPath, "bin", fmt.Sprintf("bitxhub_%s_%s", system, version))
-	var file string
-	if system == types.LinuxSystem {
-		file = fmt.Sprintf(types.BitxhubTarNameLinux, version)
-	} else if system == types.DarwinSystem {
-		file = fmt.Sprintf(types.BitxhubTarNameMacOS, version)
+func ExtractBitxhubBinary(repoPath string, version string, system string, arch string) error {
+	path := repo.GetBinPath(repoPath, "bitxhub", system, arch, version)
+	artifact, _, err := bitxhubArtifact(repoPath, version, system, arch)
+	if err != nil {
+		return err
 	}
 
 	if !fileutil.Exist(filepath.Join(path, "bitxhub")) {
-		err := sh.Command("/bin/bash", "-c", fmt.Sprintf("cd %s && tar xzf %s", path, file)).Run()
+		err := sh.Command("/bin/bash", "-c", fmt.Sprintf("cd %s && tar xzf %s", path, artifact.Name)).Run()
 		if err != nil {
 			return err
 		}
@@ -62,6 +62,29 @@ func ExtractBitxhubBinary(repoPath string, version string, system string) error
 	return nil
 }
 
+// bitxhubArtifact returns the tarball of the version for the platform in the
+// release manifest, and the checksums to verify it.
+func bitxhubArtifact(repoPath string, version string, system string, arch string) (*release.Artifact, download.Manifest, error) {
+	m, err := release.Load(repoPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := m.FindBitXHub(version)
+	if err != nil {
+		return nil, nil, err
+	}
+	artifact, err := r.Artifact(system, arch)
+	if err != nil {
+		return nil, nil, err
+	}
+	checksums, err := m.Checksums(repoPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return artifact, checksums, nil
+}
+
 func DownloadBitxhubConfig(repoPath string, version string) error {
 	if !fileutil.Exist(repoPath) {
 		err := os.MkdirAll(repoPath, 0755)
@@ -0,0 +1,656 @@
+package bitxhub
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/internal/types"
+	gops "github.com/shirou/gopsutil/process"
+	"golang.org/x/mod/semver"
+)
+
+const (
+	NodeRunning = "running"
+	NodeStopped = "stopped"
+	NodeExited  = "exited"
+
+	RestartNo        = "no"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+
+	stopTimeout = 10 * time.Second
+)
+
+// NodeState is the state of a bitxhub node started from binary.
+type NodeState struct {
+	Name      string    `json:"name"`
+	Repo      string    `json:"repo"`
+	Pid       int       `json:"pid"`
+	Status    string    `json:"status"`
+	StartedAt time.Time `json:"started_at"`
+	ExitedAt  time.Time `json:"exited_at,omitempty"`
+	Restarts  int       `json:"restarts"`
+}
+
+// State is persisted in $repo/bitxhub/bitxhub-nodes.json.
+type State struct {
+	Version     string       `json:"version"`
+	Mode        string       `json:"mode"`
+	BinPath     string       `json:"bin_path"`
+	Target      string       `json:"target"`
+	Policy      string       `json:"restart_policy"`
+	MaxRestarts int          `json:"max_restarts"`
+	Nodes       []*NodeState `json:"nodes"`
+}
+
+// Supervisor spawns, stops and watches bitxhub nodes started from binary.
+type Supervisor struct {
+	repoRoot string
+	state    *State
+	exited   map[int]*os.ProcessState
+	lock     sync.Mutex
+}
+
+func NewSupervisor(repoRoot string) (*Supervisor, error) {
+	s := &Supervisor{
+		repoRoot: repoRoot,
+		state:    &State{},
+		exited:   make(map[int]*os.ProcessState),
+	}
+
+	data, err := ioutil.ReadFile(s.statePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read bitxhub state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, s.state); err != nil {
+		return nil, fmt.Errorf("unmarshal bitxhub state: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *Supervisor) State() *State {
+	return s.state
+}
+
+// Setup resets the state for a new network. It fails if any node of the
+// previous network is still running.
+func (s *Supervisor) Setup(version, mode, binPath, target string, num int) error {
+	s.Refresh()
+	if running := s.running(); len(running) != 0 {
+		return fmt.Errorf("bitxhub already run in daemon processes: %s", strings.Join(running, ","))
+	}
+
+	nodes := make([]*NodeState, 0, num)
+	for i := 1; i <= num; i++ {
+		name := NodeName(mode, i)
+		nodes = append(nodes, &NodeState{
+			Name:   name,
+			Repo:   filepath.Join(target, name),
+			Status: NodeStopped,
+		})
+	}
+
+	// the pid file may be left by the previous network or by the scripts
+	// starting the same target
+	repos := make([]string, 0, len(s.state.Nodes)+len(nodes))
+	for _, node := range s.state.Nodes {
+		repos = append(repos, node.Repo)
+	}
+	for _, node := range nodes {
+		repos = append(repos, node.Repo)
+	}
+	if err := s.cleanStalePidFile(repos); err != nil {
+		return err
+	}
+
+	s.state = &State{
+		Version:     version,
+		Mode:        mode,
+		BinPath:     binPath,
+		Target:      target,
+		Policy:      RestartNo,
+		MaxRestarts: s.state.MaxRestarts,
+		Nodes:       nodes,
+	}
+
+	return s.save()
+}
+
+// SetRestartPolicy sets the policy used by Watch to restart exited nodes.
+func (s *Supervisor) SetRestartPolicy(policy string, maxRestarts int) error {
+	switch policy {
+	case RestartNo, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("unsupported restart policy %s, one of no, on-failure or always", policy)
+	}
+
+	s.state.Policy = policy
+	s.state.MaxRestarts = maxRestarts
+
+	return s.save()
+}
+
+// AddNode adds a node joining the cluster in the target, it is not started.
+func (s *Supervisor) AddNode(name string) error {
+	if s.state.Mode != types.ClusterMode {
+		return fmt.Errorf("nodes can only be added to a cluster")
+	}
+	if s.node(name) != nil {
+		return fmt.Errorf("bitxhub %s already exists", name)
+	}
+
+	s.state.Nodes = append(s.state.Nodes, &NodeState{
+		Name:   name,
+		Repo:   filepath.Join(s.state.Target, name),
+		Status: NodeStopped,
+	})
+
+	return s.save()
+}
+
+// Start starts the given nodes, or all nodes if names is empty.
+func (s *Supervisor) Start(names ...string) error {
+	nodes, err := s.nodes(names)
+	if err != nil {
+		return err
+	}
+
+	s.Refresh()
+	for _, node := range nodes {
+		if node.Status == NodeRunning {
+			color.Blue("%s is already running, pid: %d", node.Name, node.Pid)
+			continue
+		}
+
+		if err := s.spawn(node); err != nil {
+			return fmt.Errorf("start %s: %w", node.Name, err)
+		}
+		fmt.Printf("Start bitxhub %s, pid: %d\n", node.Name, node.Pid)
+	}
+
+	return s.save()
+}
+
+// Stop stops the given nodes, or all nodes if names is empty.
+func (s *Supervisor) Stop(names ...string) error {
+	nodes, err := s.nodes(names)
+	if err != nil {
+		return err
+	}
+
+	s.Refresh()
+	for _, node := range nodes {
+		if node.Status != NodeRunning {
+			node.Pid = 0
+			node.Status = NodeStopped
+			continue
+		}
+
+		if err := s.kill(node); err != nil {
+			return fmt.Errorf("stop %s: %w", node.Name, err)
+		}
+		fmt.Printf("node %s pid:%d exit\n", node.Name, node.Pid)
+		node.Pid = 0
+		node.Status = NodeStopped
+	}
+
+	return s.save()
+}
+
+func (s *Supervisor) Restart(names ...string) error {
+	if err := s.Stop(names...); err != nil {
+		return err
+	}
+
+	return s.Start(names...)
+}
+
+// Refresh checks whether the recorded processes are still alive and returns
+// the nodes that exited since the last check.
+func (s *Supervisor) Refresh() []*NodeState {
+	var crashed []*NodeState
+	for _, node := range s.state.Nodes {
+		if node.Status != NodeRunning {
+			continue
+		}
+
+		if !s.alive(node) {
+			node.Status = NodeExited
+			node.ExitedAt = time.Now()
+			crashed = append(crashed, node)
+		}
+	}
+
+	if len(crashed) != 0 {
+		_ = s.save()
+	}
+
+	return crashed
+}
+
+// Watch checks the nodes every interval until ctx is done, and restarts
+// exited nodes according to the restart policy.
+func (s *Supervisor) Watch(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		for _, node := range s.Refresh() {
+			failed := s.failed(node)
+			color.Red("bitxhub %s exited unexpectedly, %s", node.Name, s.exitReason(node))
+
+			if s.state.Policy == RestartNo || (s.state.Policy == RestartOnFailure && !failed) {
+				continue
+			}
+			if s.state.MaxRestarts > 0 && node.Restarts >= s.state.MaxRestarts {
+				color.Red("bitxhub %s reached max restarts %d", node.Name, s.state.MaxRestarts)
+				continue
+			}
+
+			node.Restarts++
+			if err := s.spawn(node); err != nil {
+				color.Red("restart %s: %s", node.Name, err)
+				continue
+			}
+			color.Blue("Restart bitxhub %s, pid: %d, restarts: %d", node.Name, node.Pid, node.Restarts)
+			if err := s.save(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// NodeName returns the name of the ith node, node index starts from 1.
+func NodeName(mode string, i int) string {
+	if mode == types.SoloMode {
+		return "nodeSolo"
+	}
+
+	return fmt.Sprintf("node%d", i)
+}
+
+func (s *Supervisor) spawn(node *NodeState) error {
+	if !fileutil.Exist(node.Repo) {
+		return fmt.Errorf("node repo %s not found", node.Repo)
+	}
+
+	if err := s.preparePlugins(node); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(filepath.Join(node.Repo, types.BitxhubNodeOutput), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	cmd := exec.Command(filepath.Join(s.state.BinPath, types.BitXHub), "--repo", node.Repo, "start")
+	cmd.Dir = node.Repo
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("LD_LIBRARY_PATH=%s:%s", s.state.BinPath, os.Getenv("LD_LIBRARY_PATH")),
+		fmt.Sprintf("DYLD_LIBRARY_PATH=%s:%s", s.state.BinPath, os.Getenv("DYLD_LIBRARY_PATH")),
+	)
+	// run nodes in their own process group so that they survive goduck and
+	// are not hit by signals sent to the terminal
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	node.Pid = cmd.Process.Pid
+	node.Status = NodeRunning
+	node.StartedAt = time.Now()
+	node.ExitedAt = time.Time{}
+
+	pid := node.Pid
+	go func() {
+		_ = cmd.Wait()
+		s.lock.Lock()
+		s.exited[pid] = cmd.ProcessState
+		s.lock.Unlock()
+	}()
+
+	return nil
+}
+
+func (s *Supervisor) kill(node *NodeState) error {
+	if err := syscall.Kill(-node.Pid, syscall.SIGTERM); err != nil {
+		if err := syscall.Kill(node.Pid, syscall.SIGTERM); err != nil {
+			return err
+		}
+	}
+
+	deadline := time.Now().Add(stopTimeout)
+	for time.Now().Before(deadline) {
+		if !s.alive(node) {
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	color.Red("node %s did not exit in %s, kill it", node.Name, stopTimeout)
+	_ = syscall.Kill(-node.Pid, syscall.SIGKILL)
+	return syscall.Kill(node.Pid, syscall.SIGKILL)
+}
+
+// alive reports whether pid is still a bitxhub process of the node, so that a
+// reused pid is not taken for a running node.
+func (s *Supervisor) alive(node *NodeState) bool {
+	if node.Pid <= 0 {
+		return false
+	}
+	if _, ok := s.exitState(node.Pid); ok {
+		return false
+	}
+
+	return isBitxhubProcess(node.Pid, node.Repo)
+}
+
+func (s *Supervisor) failed(node *NodeState) bool {
+	state, ok := s.exitState(node.Pid)
+	if !ok {
+		// the exit status of processes not started by this goduck is unknown
+		return true
+	}
+
+	return !state.Success()
+}
+
+func (s *Supervisor) exitReason(node *NodeState) string {
+	if state, ok := s.exitState(node.Pid); ok {
+		return fmt.Sprintf("pid: %d, %s", node.Pid, state.String())
+	}
+
+	return fmt.Sprintf("pid: %d, see %s", node.Pid, filepath.Join(node.Repo, types.BitxhubNodeOutput))
+}
+
+func (s *Supervisor) exitState(pid int) (*os.ProcessState, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	state, ok := s.exited[pid]
+	return state, ok
+}
+
+func (s *Supervisor) preparePlugins(node *NodeState) error {
+	if semver.Compare(s.state.Version, "v1.11.0") >= 0 {
+		return nil
+	}
+
+	plugin := "raft.so"
+	if s.state.Mode == types.SoloMode {
+		plugin = "solo.so"
+	}
+
+	dst := filepath.Join(node.Repo, "plugins", plugin)
+	if fileutil.Exist(dst) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	return copyFile(filepath.Join(s.state.BinPath, plugin), dst)
+}
+
+func (s *Supervisor) nodes(names []string) ([]*NodeState, error) {
+	if len(s.state.Nodes) == 0 {
+		return nil, fmt.Errorf("no bitxhub node found, please `goduck bitxhub start` first")
+	}
+
+	if len(names) == 0 {
+		return s.state.Nodes, nil
+	}
+
+	var nodes []*NodeState
+	for _, name := range names {
+		node := s.node(name)
+		if node == nil {
+			return nil, fmt.Errorf("bitxhub %s not found", name)
+		}
+		nodes = append(nodes, node)
+	}
+
+	return nodes, nil
+}
+
+func (s *Supervisor) node(name string) *NodeState {
+	for _, node := range s.state.Nodes {
+		if node.Name == name {
+			return node
+		}
+	}
+
+	return nil
+}
+
+func (s *Supervisor) running() []string {
+	var names []string
+	for _, node := range s.state.Nodes {
+		if node.Status == NodeRunning {
+			names = append(names, node.Name)
+		}
+	}
+
+	return names
+}
+
+// cleanStalePidFile removes bitxhub.pid left by nodes that are no longer
+// running, it fails if any of the recorded processes is still a bitxhub node
+// of the node repos.
+func (s *Supervisor) cleanStalePidFile(nodeRepos []string) error {
+	pidPath := filepath.Join(s.repoRoot, types.BitXHub, types.BitxhubPidFile)
+	pids, err := readPids(pidPath)
+	if err != nil {
+		return err
+	}
+
+	for _, pid := range pids {
+		for _, nodeRepo := range nodeRepos {
+			if isBitxhubProcess(pid, nodeRepo) {
+				return fmt.Errorf("bitxhub already run in daemon processes, pid: %d", pid)
+			}
+		}
+	}
+
+	if len(pids) != 0 {
+		color.Blue("Remove stale %s", pidPath)
+	}
+
+	return s.writePidFiles()
+}
+
+// save persists the state, and keeps bitxhub.pid and bitxhub.version in sync
+// with it for the scripts which still read them.
+func (s *Supervisor) save() error {
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.statePath()), 0755); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(s.statePath(), data, 0644); err != nil {
+		return fmt.Errorf("write bitxhub state: %w", err)
+	}
+
+	return s.writePidFiles()
+}
+
+func (s *Supervisor) writePidFiles() error {
+	dir := filepath.Join(s.repoRoot, types.BitXHub)
+	pidPath := filepath.Join(dir, types.BitxhubPidFile)
+	versionPath := filepath.Join(dir, types.BitxhubVersionFile)
+	binaryVersionPath := filepath.Join(dir, types.BitxhubBinaryVersionFile)
+
+	var pids, versions []string
+	for _, node := range s.state.Nodes {
+		if node.Status == NodeRunning {
+			pids = append(pids, strconv.Itoa(node.Pid))
+			versions = append(versions, s.state.Version)
+		}
+	}
+
+	if len(pids) == 0 {
+		for _, path := range []string{pidPath, versionPath, binaryVersionPath} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := ioutil.WriteFile(pidPath, []byte(strings.Join(pids, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(versionPath, []byte(strings.Join(versions, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+
+	if !fileutil.Exist(binaryVersionPath) {
+		out, err := exec.Command(filepath.Join(s.state.BinPath, types.BitXHub), "version").Output()
+		if err == nil {
+			return ioutil.WriteFile(binaryVersionPath, out, 0644)
+		}
+	}
+
+	return nil
+}
+
+func (s *Supervisor) statePath() string {
+	return filepath.Join(s.repoRoot, types.BitXHub, types.BitxhubStateFile)
+}
+
+// ProcessExists reports whether the process of pid is running.
+func ProcessExists(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil && err != syscall.EPERM {
+		return false
+	}
+
+	p, err := gops.NewProcess(int32(pid))
+	if err != nil {
+		return false
+	}
+
+	status, err := p.Status()
+	return err == nil && status != "Z"
+}
+
+func isBitxhubProcess(pid int, nodeRepo string) bool {
+	if !ProcessExists(pid) {
+		return false
+	}
+
+	p, err := gops.NewProcess(int32(pid))
+	if err != nil {
+		return false
+	}
+
+	args, err := p.CmdlineSlice()
+	if err != nil {
+		return false
+	}
+
+	repo, ok := bitxhubRepo(args)
+	if !ok {
+		return false
+	}
+	if !filepath.IsAbs(repo) {
+		cwd, err := p.Cwd()
+		if err != nil {
+			return false
+		}
+		repo = filepath.Join(cwd, repo)
+	}
+
+	return filepath.Clean(repo) == filepath.Clean(nodeRepo)
+}
+
+// bitxhubRepo returns the --repo of the bitxhub command line, so that node1
+// is not taken for node10 as a substring of its repo.
+func bitxhubRepo(args []string) (string, bool) {
+	if len(args) == 0 || filepath.Base(args[0]) != types.BitXHub {
+		return "", false
+	}
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--repo" || arg == "-repo":
+			if i+1 < len(args) {
+				return args[i+1], true
+			}
+		case strings.HasPrefix(arg, "--repo="):
+			return strings.TrimPrefix(arg, "--repo="), true
+		case strings.HasPrefix(arg, "-repo="):
+			return strings.TrimPrefix(arg, "-repo="), true
+		}
+	}
+
+	return "", false
+}
+
+func readPids(path string) ([]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var pids []int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		pid, err := strconv.Atoi(line)
+		if err != nil {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+
+	return pids, scanner.Err()
+}
+
+func copyFile(src, dst string) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(dst, data, 0755)
+}
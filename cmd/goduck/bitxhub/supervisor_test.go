@@ -0,0 +1,143 @@
+package bitxhub
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// startFakeBitxhub starts a long running shell whose command line is the one
+// of a bitxhub node in the repo.
+func startFakeBitxhub(t *testing.T, dir string, repoArgs ...string) int {
+	cmd := exec.Command("sh")
+	cmd.Args = append([]string{filepath.Join(dir, types.BitXHub), "-c", "while :; do sleep 1; done", types.BitXHub}, repoArgs...)
+	cmd.Args = append(cmd.Args, "start")
+	require.Nil(t, cmd.Start())
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	return cmd.Process.Pid
+}
+
+func writePids(t *testing.T, repoRoot string, pids ...int) string {
+	var data string
+	for _, pid := range pids {
+		data += strconv.Itoa(pid) + "\n"
+	}
+	pidPath := filepath.Join(repoRoot, types.BitXHub, types.BitxhubPidFile)
+	require.Nil(t, os.MkdirAll(filepath.Dir(pidPath), 0755))
+	require.Nil(t, ioutil.WriteFile(pidPath, []byte(data), 0644))
+
+	return pidPath
+}
+
+func TestSupervisorState(t *testing.T) {
+	repoRoot, err := ioutil.TempDir("", "supervisor")
+	require.Nil(t, err)
+	defer os.RemoveAll(repoRoot)
+
+	sup, err := NewSupervisor(repoRoot)
+	require.Nil(t, err)
+	require.Empty(t, sup.State().Nodes)
+
+	target := filepath.Join(repoRoot, "bitxhub", ".bitxhub")
+	require.Nil(t, sup.Setup("v1.11.3", types.ClusterMode, filepath.Join(repoRoot, "bin"), target, 4))
+	require.Nil(t, sup.SetRestartPolicy(RestartOnFailure, 3))
+	require.Nil(t, sup.AddNode("node5"))
+	require.NotNil(t, sup.AddNode("node5"))
+	require.NotNil(t, sup.SetRestartPolicy("sometimes", 3))
+
+	loaded, err := NewSupervisor(repoRoot)
+	require.Nil(t, err)
+	require.Equal(t, sup.State(), loaded.State())
+	require.Equal(t, RestartOnFailure, loaded.State().Policy)
+	require.Equal(t, 3, loaded.State().MaxRestarts)
+	require.Len(t, loaded.State().Nodes, 5)
+	require.Equal(t, filepath.Join(target, "node5"), loaded.State().Nodes[4].Repo)
+	require.Equal(t, NodeStopped, loaded.State().Nodes[4].Status)
+
+	// no pid file is written without running nodes
+	require.NoFileExists(t, filepath.Join(repoRoot, types.BitXHub, types.BitxhubPidFile))
+
+	// the broken state is not taken for an empty one
+	require.Nil(t, ioutil.WriteFile(sup.statePath(), []byte("{"), 0644))
+	_, err = NewSupervisor(repoRoot)
+	require.NotNil(t, err)
+}
+
+func TestSupervisorStalePid(t *testing.T) {
+	repoRoot, err := ioutil.TempDir("", "supervisor")
+	require.Nil(t, err)
+	defer os.RemoveAll(repoRoot)
+	target := filepath.Join(repoRoot, "bitxhub", ".bitxhub")
+
+	// an exited process, and a live one which is not bitxhub
+	exited := exec.Command("true")
+	require.Nil(t, exited.Run())
+	pidPath := writePids(t, repoRoot, exited.Process.Pid, os.Getpid())
+
+	sup, err := NewSupervisor(repoRoot)
+	require.Nil(t, err)
+	require.Nil(t, sup.Setup("v1.11.3", types.ClusterMode, "", target, 4))
+	require.NoFileExists(t, pidPath)
+
+	// a bitxhub node of the target is still running
+	pid := startFakeBitxhub(t, repoRoot, "--repo", filepath.Join(target, "node2"))
+	writePids(t, repoRoot, pid)
+	err = sup.Setup("v1.11.3", types.ClusterMode, "", target, 4)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), strconv.Itoa(pid))
+}
+
+func TestIsBitxhubProcess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "supervisor")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	node1 := filepath.Join(dir, "node1")
+	node10 := filepath.Join(dir, "node10")
+	pid := startFakeBitxhub(t, dir, "--repo="+node10+"/")
+
+	require.True(t, isBitxhubProcess(pid, node10))
+	require.False(t, isBitxhubProcess(pid, node1))
+	require.False(t, isBitxhubProcess(os.Getpid(), node10))
+
+	// node1 is not taken for running because of node10
+	writePids(t, dir, pid)
+	sup, err := NewSupervisor(dir)
+	require.Nil(t, err)
+	require.Nil(t, sup.cleanStalePidFile([]string{node1}))
+	writePids(t, dir, pid)
+	require.NotNil(t, sup.cleanStalePidFile([]string{node1, node10}))
+}
+
+func TestBitxhubRepo(t *testing.T) {
+	tests := []struct {
+		args []string
+		repo string
+		ok   bool
+	}{
+		{args: []string{"bitxhub", "--repo", "/tmp/node1", "start"}, repo: "/tmp/node1", ok: true},
+		{args: []string{"./bitxhub", "--repo=/tmp/node10", "start"}, repo: "/tmp/node10", ok: true},
+		{args: []string{"/opt/bin/bitxhub", "-repo", "node1", "start"}, repo: "node1", ok: true},
+		{args: []string{"bitxhub", "start"}},
+		{args: []string{"bitxhub", "--repo"}},
+		{args: []string{"pier", "--repo", "/tmp/node1", "start"}},
+		{args: []string{"bitxhub.test", "--repo", "/tmp/node1"}},
+		{},
+	}
+
+	for _, test := range tests {
+		repo, ok := bitxhubRepo(test.args)
+		require.Equal(t, test.ok, ok, test.args)
+		require.Equal(t, test.repo, repo, test.args)
+	}
+}
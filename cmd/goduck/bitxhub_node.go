@@ -0,0 +1,429 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
+	"github.com/meshplus/goduck/internal/capability"
+	"github.com/meshplus/goduck/internal/ports"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/pelletier/go-toml"
+	"github.com/spf13/viper"
+	"github.com/urfave/cli/v2"
+)
+
+// nodeConfigPorts are the keys of the node ports in bitxhub.toml
+var nodeConfigPorts = map[ports.Kind]string{
+	ports.JSONRPC: "port.jsonrpc",
+	ports.GRPC:    "port.grpc",
+	ports.Gateway: "port.gateway",
+	ports.Pprof:   "port.pprof",
+	ports.Monitor: "port.monitor",
+}
+
+var p2pPortRegexp = regexp.MustCompile(`/tcp/(\d+)`)
+
+func bitxhubNodeCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "node",
+		Usage: "Operation about the nodes of a BitXHub cluster",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "add",
+				Usage: "Generate configuration for a new node joining the running BitXHub cluster",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "target",
+						Usage: "Specify the directory of the cluster configuration, default: the directory of the started cluster or $repo/bitxhub/.bitxhub/",
+					},
+					&cli.StringFlag{
+						Aliases: []string{"version", "v"},
+						Usage:   "BitXHub version, default: the version of the started cluster",
+					},
+					&cli.StringFlag{
+						Name:  "ip",
+						Usage: "Specify the ip of the new node, default: the ip of the last node",
+					},
+					&cli.BoolFlag{
+						Name:  "start",
+						Usage: "Start the new node after its configuration is generated",
+					},
+				},
+				Action: addBitXHubNode,
+			},
+		},
+	}
+}
+
+func addBitXHubNode(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return fmt.Errorf("parse repo path error:%w", err)
+	}
+	if !fileutil.Exist(filepath.Join(repoRoot, types.PlaygroundScript)) {
+		return fmt.Errorf("please `goduck init` first")
+	}
+
+	sup, err := bitxhub.NewSupervisor(repoRoot)
+	if err != nil {
+		return err
+	}
+	state := sup.State()
+
+	target := ctx.String("target")
+	if target == "" {
+		target = state.Target
+	}
+	if target == "" {
+		target = filepath.Join(repoRoot, "bitxhub/.bitxhub")
+	}
+	if target, err = filepath.Abs(target); err != nil {
+		return fmt.Errorf("get absolute target path: %w", err)
+	}
+	managed := state.Target == target
+
+	version := ctx.String("version")
+	if version == "" && managed {
+		version = state.Version
+	}
+	if version == "" {
+		return fmt.Errorf("please specify the version of the cluster with --version")
+	}
+	if !capability.Has(version, capability.NetworkNodeList) {
+		return fmt.Errorf("BitXHub %s does not support adding nodes", version)
+	}
+
+	if fileutil.Exist(filepath.Join(target, bitxhub.NodeName(types.SoloMode, 1))) {
+		return fmt.Errorf("nodes can not be added to the solo node in %s", target)
+	}
+	num := 0
+	for fileutil.Exist(filepath.Join(target, bitxhub.NodeName(types.ClusterMode, num+1), repo.NetworkConfigName)) {
+		num++
+	}
+	if num == 0 {
+		return fmt.Errorf("no bitxhub cluster found in %s", target)
+	}
+
+	id := num + 1
+	name := bitxhub.NodeName(types.ClusterMode, id)
+	nodeRoot := filepath.Join(target, name)
+	if fileutil.Exist(nodeRoot) {
+		return fmt.Errorf("%s already exists", nodeRoot)
+	}
+
+	binPath := state.BinPath
+	if !managed || binPath == "" {
+		if binPath, err = bitxhubBinary(repoRoot, version); err != nil {
+			return err
+		}
+	}
+
+	agencyPriv, agencyCert, err := agencyKey(target)
+	if err != nil {
+		return err
+	}
+
+	// the last node knows all the nodes of the cluster
+	lastRoot := filepath.Join(target, bitxhub.NodeName(types.ClusterMode, num))
+	network, err := toml.LoadFile(filepath.Join(lastRoot, repo.NetworkConfigName))
+	if err != nil {
+		return fmt.Errorf("load network config of %s: %w", lastRoot, err)
+	}
+	peers, ok := network.Get("nodes").([]*toml.Tree)
+	if !ok || len(peers) == 0 {
+		return fmt.Errorf("no nodes found in the network config of %s", lastRoot)
+	}
+
+	ip := ctx.String("ip")
+	if ip == "" {
+		ip = peerIP(peers[len(peers)-1])
+	}
+	if net.ParseIP(ip) == nil {
+		return fmt.Errorf("invalid ip %s", ip)
+	}
+
+	alloc, nodePorts, err := allocateNodePorts(repoRoot, target, num, peers)
+	if err != nil {
+		return fmt.Errorf("allocate ports: %w", err)
+	}
+
+	color.Blue("======> Generate configuration files for %s", name)
+	success := false
+	defer func() {
+		if !success {
+			_ = os.RemoveAll(nodeRoot)
+		}
+	}()
+
+	pid, addr, err := generateNodeRepo(binPath, nodeRoot, name, agencyPriv, agencyCert, filepath.Join(lastRoot, "certs"))
+	if err != nil {
+		return err
+	}
+
+	// the new node shares the genesis and the consensus with the cluster
+	if err := copyFile(filepath.Join(nodeRoot, repo.BitXHubConfigName), filepath.Join(lastRoot, repo.BitXHubConfigName)); err != nil {
+		return err
+	}
+	if err := writeNodePorts(nodeRoot, nodePorts); err != nil {
+		return err
+	}
+
+	peer, err := toml.TreeFromMap(map[string]interface{}{
+		"id":      int64(id),
+		"pid":     pid,
+		"hosts":   []string{fmt.Sprintf("/ip4/%s/tcp/%d/p2p/", ip, nodePorts[ports.P2P])},
+		"account": addr,
+	})
+	if err != nil {
+		return err
+	}
+	network.Set("id", int64(id))
+	network.Set("n", int64(id))
+	network.Set("new", true)
+	network.Set("nodes", append(peers, peer))
+	if err := ioutil.WriteFile(filepath.Join(nodeRoot, repo.NetworkConfigName), []byte(network.String()), 0644); err != nil {
+		return err
+	}
+	if err := alloc.Save(); err != nil {
+		return fmt.Errorf("save ports: %w", err)
+	}
+	success = true
+
+	color.Green("%s is generated at %s, pid: %s, account: %s", name, nodeRoot, pid, addr)
+
+	if managed {
+		if err := sup.AddNode(name); err != nil {
+			return err
+		}
+	}
+
+	printJoinSteps(binPath, target, name, id, pid, addr, managed)
+
+	if !ctx.Bool("start") {
+		return nil
+	}
+	if !managed {
+		return fmt.Errorf("the cluster in %s is not started by goduck, please start %s yourself", target, name)
+	}
+
+	return sup.Start(name)
+}
+
+// agencyKey returns the agency key and cert which issue the node certs of
+// the cluster, which are either in the target or given by the modify config
+// the cluster was generated from. The agency.cert reissued by `cert renew
+// --agency` is kept in the target, and takes precedence over the config.
+func agencyKey(target string) (string, string, error) {
+	priv := repo.GetPrivKeyPath(repo.AgencyName, target)
+	cert := repo.GetCertPath(repo.AgencyName, target)
+	if fileutil.Exist(priv) && fileutil.Exist(cert) {
+		return priv, cert, nil
+	}
+	reissued := ""
+	if fileutil.Exist(cert) {
+		reissued = cert
+	}
+
+	configPath := target + "_" + types.BxhModifyConfig
+	if !fileutil.Exist(configPath) {
+		return "", "", fmt.Errorf("agency key of %s not found", target)
+	}
+	config, err := repo.LoadModifyConfig(configPath)
+	if err != nil {
+		return "", "", err
+	}
+	priv = config.Get("agency_priv_path")
+	cert = config.Get("agency_cert_path")
+	if !fileutil.Exist(priv) || !fileutil.Exist(cert) {
+		return "", "", fmt.Errorf("agency key %s or cert %s in %s not found", priv, cert, configPath)
+	}
+	if reissued != "" {
+		cert = reissued
+	}
+
+	return priv, cert, nil
+}
+
+// allocateNodePorts allocates the ports of the node joining the cluster of
+// num nodes in target. The ports of the nodes generated before the ports are
+// allocated are read from their configs. The allocation is not saved.
+func allocateNodePorts(repoRoot, target string, num int, peers []*toml.Tree) (*ports.Allocation, ports.Ports, error) {
+	alloc, err := ports.Load(repoRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	env := alloc.Get(target)
+	if env == nil || len(env.Nodes) < num {
+		env = &ports.Env{}
+		for i := 1; i <= num; i++ {
+			nodePorts, err := readNodePorts(filepath.Join(target, bitxhub.NodeName(types.ClusterMode, i)), i, peers)
+			if err != nil {
+				return nil, nil, err
+			}
+			env.Nodes = append(env.Nodes, nodePorts)
+		}
+		alloc.Envs[target] = env
+	}
+	env.Nodes = env.Nodes[:num]
+
+	if env, err = ports.NewAllocator(env.Nodes[0]).Allocate(alloc, target, num+1, 0); err != nil {
+		return nil, nil, err
+	}
+
+	return alloc, env.Nodes[num], nil
+}
+
+// readNodePorts reads the ports of the ith node from its configs
+func readNodePorts(nodeRoot string, i int, peers []*toml.Tree) (ports.Ports, error) {
+	v := viper.New()
+	v.SetConfigFile(filepath.Join(nodeRoot, repo.BitXHubConfigName))
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", v.ConfigFileUsed(), err)
+	}
+
+	nodePorts := make(ports.Ports)
+	for kind, key := range nodeConfigPorts {
+		if port := v.GetInt(key); port != 0 {
+			nodePorts[kind] = port
+		}
+	}
+	for _, peer := range peers {
+		if id, ok := peer.Get("id").(int64); !ok || int(id) != i {
+			continue
+		}
+		if port := peerPort(peer); port != 0 {
+			nodePorts[ports.P2P] = port
+		}
+	}
+
+	return nodePorts, nil
+}
+
+func writeNodePorts(nodeRoot string, nodePorts ports.Ports) error {
+	v := viper.New()
+	v.SetConfigFile(filepath.Join(nodeRoot, repo.BitXHubConfigName))
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("read %s: %w", v.ConfigFileUsed(), err)
+	}
+
+	for kind, key := range nodeConfigPorts {
+		if v.IsSet(key) {
+			v.Set(key, nodePorts[kind])
+		}
+	}
+
+	if err := v.WriteConfig(); err != nil {
+		return fmt.Errorf("write %s: %w", v.ConfigFileUsed(), err)
+	}
+
+	return nil
+}
+
+// generateNodeRepo generates the certs, the key and the configs of the node
+// by the bitxhub binary, and returns its pid and account.
+func generateNodeRepo(binPath, nodeRoot, name, agencyPriv, agencyCert, certsFrom string) (string, string, error) {
+	certRoot := filepath.Join(nodeRoot, "certs")
+	steps := [][]string{
+		{"cert", "priv", "gen", "--name", "node", "--target", certRoot},
+		{"cert", "csr", "--key", filepath.Join(certRoot, repo.NodeKeyName), "--org", name, "--target", certRoot},
+		{"cert", "issue", "--csr", repo.GetCSRPath("node", certRoot), "--is_ca", "false", "--key", agencyPriv, "--cert", agencyCert, "--target", certRoot},
+		{"key", "gen", "--target", nodeRoot, "--passwd", repo.KeyPassword},
+		{"--repo", nodeRoot, "init"},
+	}
+	for _, args := range steps {
+		if _, err := runBitXHub(binPath, args...); err != nil {
+			return "", "", err
+		}
+	}
+
+	if err := os.Remove(repo.GetCSRPath("node", certRoot)); err != nil {
+		return "", "", err
+	}
+	if err := copyFile(repo.GetCertPath(repo.AgencyName, certRoot), repo.GetCertPath(repo.AgencyName, certsFrom)); err != nil {
+		return "", "", fmt.Errorf("copy agency cert: %w", err)
+	}
+	if err := copyFile(repo.GetCACertPath(certRoot), repo.GetCACertPath(certsFrom)); err != nil {
+		return "", "", fmt.Errorf("copy ca cert: %w", err)
+	}
+
+	pid, err := runBitXHub(binPath, "cert", "priv", "pid", "--path", filepath.Join(certRoot, repo.NodeKeyName))
+	if err != nil {
+		return "", "", err
+	}
+	addr, err := runBitXHub(binPath, "key", "address", "--path", filepath.Join(nodeRoot, repo.KeyName), "--passwd", repo.KeyPassword)
+	if err != nil {
+		return "", "", err
+	}
+
+	return pid, addr, nil
+}
+
+// runBitXHub runs the bitxhub binary and returns its trimmed output
+func runBitXHub(binPath string, args ...string) (string, error) {
+	cmd := exec.Command(filepath.Join(binPath, types.BitXHub), args...)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("LD_LIBRARY_PATH=%s:%s", binPath, os.Getenv("LD_LIBRARY_PATH")),
+		fmt.Sprintf("DYLD_LIBRARY_PATH=%s:%s", binPath, os.Getenv("DYLD_LIBRARY_PATH")),
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("bitxhub %s: %w\n%s", strings.Join(args, " "), err, out)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+func printJoinSteps(binPath, target, name string, id int, pid, addr string, managed bool) {
+	bin := filepath.Join(binPath, types.BitXHub)
+	admin := filepath.Join(target, bitxhub.NodeName(types.ClusterMode, 1))
+
+	color.Blue("The new node joins the cluster after the admins approve it:")
+	fmt.Printf("1. propose to register %s by an admin, e.g.\n", name)
+	fmt.Printf("   %s --repo %s client governance node register --pid %s --account %s --id %d\n", bin, admin, pid, addr, id)
+	fmt.Println("2. vote for the proposal by the other admins until it is approved, e.g.")
+	fmt.Printf("   %s --repo %s client governance vote --id $PROPOSAL_ID --info approve --reason approve\n", bin, filepath.Join(target, bitxhub.NodeName(types.ClusterMode, 2)))
+	if managed {
+		fmt.Printf("3. start %s by `goduck bitxhub start --node %d`\n", name, id)
+	} else {
+		fmt.Printf("3. start %s by `%s --repo %s start`\n", name, bin, filepath.Join(target, name))
+	}
+	color.Yellow("The flags of the governance commands vary by BitXHub versions, see `%s client governance --help`", bin)
+}
+
+func peerIP(peer *toml.Tree) string {
+	hosts, _ := peer.Get("hosts").([]interface{})
+	for _, host := range hosts {
+		parts := strings.Split(fmt.Sprint(host), "/")
+		if len(parts) > 2 && parts[1] == "ip4" {
+			return parts[2]
+		}
+	}
+
+	return "127.0.0.1"
+}
+
+func peerPort(peer *toml.Tree) int {
+	hosts, _ := peer.Get("hosts").([]interface{})
+	for _, host := range hosts {
+		if m := p2pPortRegexp.FindStringSubmatch(fmt.Sprint(host)); m != nil {
+			port, _ := strconv.Atoi(m[1])
+			return port
+		}
+	}
+
+	return 0
+}
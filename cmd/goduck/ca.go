@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	libp2pcert "github.com/meshplus/go-libp2p-cert"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+)
+
+// BitXHubCA is an existing trust root which the node certificates are issued
+// under, so that the clusters and piers generated on different machines
+// trust each other. A new agency is issued by the CA if its key is given,
+// otherwise the nodes are issued by the given agency. A new CA is generated
+// if neither is given.
+type BitXHubCA struct {
+	CAPriv     string
+	CACert     string
+	AgencyPriv string
+	AgencyCert string
+}
+
+// LoadBitXHubCA reads the paths of the CA and the agency from the modify config
+func LoadBitXHubCA(config *repo.ModifyConfig) *BitXHubCA {
+	return &BitXHubCA{
+		CAPriv:     config.Get("bitxhub.ca_priv_path"),
+		CACert:     config.Get("bitxhub.ca_cert_path"),
+		AgencyPriv: config.Get("bitxhub.agency_priv_path"),
+		AgencyCert: config.Get("bitxhub.agency_cert_path"),
+	}
+}
+
+// existingCA means the nodes are issued under the given CA
+func (c *BitXHubCA) existingCA() bool {
+	return c.CAPriv != ""
+}
+
+// existingAgency means the nodes are issued by the given agency
+func (c *BitXHubCA) existingAgency() bool {
+	return !c.existingCA() && (c.AgencyPriv != "" || c.AgencyCert != "")
+}
+
+// Validate checks the keys match their certificates, and the agency is
+// issued by the CA
+func (c *BitXHubCA) Validate() error {
+	switch {
+	case c.existingCA():
+		if c.CACert == "" {
+			return fmt.Errorf("ca cert is needed with the ca private key")
+		}
+		if err := checkKeyPair(c.CAPriv, c.CACert); err != nil {
+			return err
+		}
+		caCert, err := loadCertFile(c.CACert)
+		if err != nil {
+			return err
+		}
+		if !caCert.IsCA {
+			return fmt.Errorf("%s is not a ca certificate", c.CACert)
+		}
+	case c.existingAgency():
+		if c.AgencyPriv == "" || c.AgencyCert == "" || c.CACert == "" {
+			return fmt.Errorf("agency private key, agency cert and ca cert are all needed to use an existing agency")
+		}
+		if err := checkKeyPair(c.AgencyPriv, c.AgencyCert); err != nil {
+			return err
+		}
+		agencyCert, err := loadCertFile(c.AgencyCert)
+		if err != nil {
+			return err
+		}
+		caCert, err := loadCertFile(c.CACert)
+		if err != nil {
+			return err
+		}
+		if err := libp2pcert.VerifySign(agencyCert, caCert); err != nil {
+			return fmt.Errorf("%s is not issued by %s: %w", c.AgencyCert, c.CACert, err)
+		}
+	}
+
+	return nil
+}
+
+// Issue writes ca.cert, agency.priv and agency.cert of the trust root to dir,
+// and returns the paths of the agency key and cert. The key of an existing CA
+// is not copied.
+func (c *BitXHubCA) Issue(dir string) (string, string, error) {
+	caPriv, caCert := c.CAPriv, c.CACert
+	switch {
+	case c.existingAgency():
+		files := map[string]string{
+			repo.GetCACertPath(dir):                   c.CACert,
+			repo.GetPrivKeyPath(repo.AgencyName, dir): c.AgencyPriv,
+			repo.GetCertPath(repo.AgencyName, dir):    c.AgencyCert,
+		}
+		for dst, src := range files {
+			if err := copyFileIfDiffer(dst, src); err != nil {
+				return "", "", fmt.Errorf("copy %s: %w", filepath.Base(src), err)
+			}
+		}
+		return repo.GetPrivKeyPath(repo.AgencyName, dir), repo.GetCertPath(repo.AgencyName, dir), nil
+	case c.existingCA():
+		if err := copyFileIfDiffer(repo.GetCACertPath(dir), c.CACert); err != nil {
+			return "", "", fmt.Errorf("copy ca cert: %w", err)
+		}
+	default:
+		var err error
+		if caPriv, caCert, err = generateCA(dir); err != nil {
+			return "", "", fmt.Errorf("generate CA: %w", err)
+		}
+	}
+
+	agencyPriv, agencyCert, err := generateCert(repo.AgencyName, strings.ToUpper(repo.AgencyName), dir, caPriv, caCert, true)
+	if err != nil {
+		return "", "", fmt.Errorf("generate agency cert: %w", err)
+	}
+
+	return agencyPriv, agencyCert, nil
+}
+
+// applyBitXHubCA issues the agency of an existing CA to the target, and points
+// the modify config of the scripts to it. The scripts issue the nodes by the
+// agency in the modify config as it is otherwise.
+func applyBitXHubCA(target string, config *repo.ModifyConfig) error {
+	ca := LoadBitXHubCA(config)
+	if err := ca.Validate(); err != nil {
+		return fmt.Errorf("invalid ca: %w", err)
+	}
+	if !ca.existingCA() {
+		return nil
+	}
+
+	agencyPriv, agencyCert, err := ca.Issue(target)
+	if err != nil {
+		return err
+	}
+	paths := map[string]string{
+		"bitxhub.agency_priv_path": agencyPriv,
+		"bitxhub.agency_cert_path": agencyCert,
+		"bitxhub.ca_cert_path":     repo.GetCACertPath(target),
+	}
+	for key, path := range paths {
+		if err := config.Set(key, path); err != nil {
+			return err
+		}
+	}
+
+	return config.Write(config.Path())
+}
+
+// caKey returns the ca key and cert which issue the agency of the cluster,
+// which are either in the target or given by the modify config the cluster
+// was generated from.
+func caKey(target string) (string, string, error) {
+	priv := repo.GetCAPrivKeyPath(target)
+	cert := repo.GetCACertPath(target)
+	if fileutil.Exist(priv) && fileutil.Exist(cert) {
+		return priv, cert, nil
+	}
+
+	configPath := target + "_" + types.BxhModifyConfig
+	if !fileutil.Exist(configPath) {
+		return "", "", fmt.Errorf("ca key of %s not found", target)
+	}
+	config, err := repo.LoadModifyConfig(configPath)
+	if err != nil {
+		return "", "", err
+	}
+	priv = config.Get("ca_priv_path")
+	cert = config.Get("ca_cert_path")
+	if !fileutil.Exist(priv) || !fileutil.Exist(cert) {
+		return "", "", fmt.Errorf("ca key %s or cert %s in %s not found", priv, cert, configPath)
+	}
+
+	return priv, cert, nil
+}
+
+// checkKeyPair checks the public key of the certificate is the one of the
+// private key
+func checkKeyPair(privPath, certPath string) error {
+	data, err := ioutil.ReadFile(privPath)
+	if err != nil {
+		return fmt.Errorf("read private key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("decode private key %s", privPath)
+	}
+	privKey, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("parse private key %s: %w", privPath, err)
+	}
+
+	c, err := loadCertFile(certPath)
+	if err != nil {
+		return err
+	}
+	pub, ok := c.PublicKey.(*ecdsa.PublicKey)
+	if !ok || pub.Curve != privKey.Curve || pub.X.Cmp(privKey.X) != 0 || pub.Y.Cmp(privKey.Y) != 0 {
+		return fmt.Errorf("%s does not match %s", privPath, certPath)
+	}
+
+	return nil
+}
+
+// copyFileIfDiffer copies srcFile to dstFile unless they are the same file
+func copyFileIfDiffer(dstFile, srcFile string) error {
+	src, err := filepath.Abs(srcFile)
+	if err != nil {
+		return err
+	}
+	dst, err := filepath.Abs(dstFile)
+	if err != nil {
+		return err
+	}
+	if src == dst {
+		return nil
+	}
+
+	return copyFile(dst, src)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckKeyPair(t *testing.T) {
+	target, err := ioutil.TempDir("", "ca")
+	require.Nil(t, err)
+	defer os.RemoveAll(target)
+
+	newTestCluster(t, target, 1)
+	caPriv, caCert := repo.GetCAPrivKeyPath(target), repo.GetCACertPath(target)
+	agencyPriv, agencyCert := repo.GetPrivKeyPath(repo.AgencyName, target), repo.GetCertPath(repo.AgencyName, target)
+
+	require.Nil(t, checkKeyPair(caPriv, caCert))
+	require.Nil(t, checkKeyPair(agencyPriv, agencyCert))
+
+	err = checkKeyPair(caPriv, agencyCert)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "does not match")
+	err = checkKeyPair(agencyPriv, caCert)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "does not match")
+}
+
+func TestBitXHubCAValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ca")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// two clusters under different CAs
+	target := filepath.Join(dir, "cluster")
+	other := filepath.Join(dir, "other")
+	nodes := newTestCluster(t, target, 1)
+	newTestCluster(t, other, 1)
+
+	caPriv, caCert := repo.GetCAPrivKeyPath(target), repo.GetCACertPath(target)
+	agencyPriv, agencyCert := repo.GetPrivKeyPath(repo.AgencyName, target), repo.GetCertPath(repo.AgencyName, target)
+	nodeRoot := filepath.Join(nodes[0].root, "certs")
+	nodePriv, nodeCert := repo.GetPrivKeyPath("node", nodeRoot), repo.GetCertPath("node", nodeRoot)
+	otherCACert := repo.GetCACertPath(other)
+	otherAgencyPriv, otherAgencyCert := repo.GetPrivKeyPath(repo.AgencyName, other), repo.GetCertPath(repo.AgencyName, other)
+
+	tests := []struct {
+		name string
+		ca   *BitXHubCA
+		err  string
+	}{
+		{name: "new ca", ca: &BitXHubCA{}},
+		{name: "ca", ca: &BitXHubCA{CAPriv: caPriv, CACert: caCert}},
+		{name: "ca without cert", ca: &BitXHubCA{CAPriv: caPriv}, err: "ca cert is needed"},
+		{name: "mismatched ca", ca: &BitXHubCA{CAPriv: caPriv, CACert: otherCACert}, err: "does not match"},
+		{name: "ca of a node cert", ca: &BitXHubCA{CAPriv: nodePriv, CACert: nodeCert}, err: "is not a ca certificate"},
+		{name: "agency", ca: &BitXHubCA{AgencyPriv: agencyPriv, AgencyCert: agencyCert, CACert: caCert}},
+		{name: "agency without ca cert", ca: &BitXHubCA{AgencyPriv: agencyPriv, AgencyCert: agencyCert}, err: "are all needed"},
+		{name: "mismatched agency", ca: &BitXHubCA{AgencyPriv: otherAgencyPriv, AgencyCert: agencyCert, CACert: caCert}, err: "does not match"},
+		{name: "agency of another ca", ca: &BitXHubCA{AgencyPriv: otherAgencyPriv, AgencyCert: otherAgencyCert, CACert: caCert}, err: "is not issued by"},
+	}
+
+	for _, test := range tests {
+		err := test.ca.Validate()
+		if test.err == "" {
+			require.Nil(t, err, test.name)
+			continue
+		}
+		require.NotNil(t, err, test.name)
+		require.Contains(t, err.Error(), test.err, test.name)
+	}
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+// CertInfo is the info about a certificate shown by `cert show`
+type CertInfo struct {
+	Path      string    `json:"path" yaml:"path"`
+	Subject   string    `json:"subject" yaml:"subject"`
+	Issuer    string    `json:"issuer" yaml:"issuer"`
+	Org       []string  `json:"org" yaml:"org"`
+	IsCA      bool      `json:"is_ca" yaml:"is_ca"`
+	NotBefore time.Time `json:"not_before" yaml:"not_before"`
+	NotAfter  time.Time `json:"not_after" yaml:"not_after"`
+	KeyType   string    `json:"key_type" yaml:"key_type"`
+	SANs      []string  `json:"sans" yaml:"sans"`
+	// Pid is the libp2p id derived from the public key, which is the pid of
+	// the node if it is a node certificate
+	Pid string `json:"pid" yaml:"pid"`
+}
+
+func certCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "cert",
+		Usage: "Show, verify and renew the certificates of BitXHub nodes",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "show",
+				Usage:     "Show the subject, issuer, validity, key type, SANs and pid of a certificate",
+				ArgsUsage: "<cert file>",
+				Action:    showCert,
+			},
+			{
+				Name:  "verify",
+				Usage: "Verify the certificate chain node -> agency -> ca of each node, and warn about the expiry",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "target",
+						Usage: "Specify the directory of the BitXHub nodes, default: $repo/bitxhub/.bitxhub/",
+					},
+					&cli.DurationFlag{
+						Name:  "expiry",
+						Value: 30 * 24 * time.Hour,
+						Usage: "Warn about the certificates which expire within the duration",
+					},
+				},
+				Action: verifyCerts,
+			},
+			{
+				Name:  "renew",
+				Usage: "Reissue the node certificates, and optionally the agency certificate, with the existing keys",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "target",
+						Usage: "Specify the directory of the BitXHub nodes, default: $repo/bitxhub/.bitxhub/",
+					},
+					&cli.IntFlag{
+						Name:  "node",
+						Usage: "Specify the node whose certificate is reissued, all nodes if not set",
+					},
+					&cli.BoolFlag{
+						Name:  "agency",
+						Usage: "Reissue agency.cert by ca.priv as well, into the target and the nodes",
+					},
+					&cli.IntFlag{
+						Name:  "days",
+						Value: 50 * 365,
+						Usage: "Specify the validity of the reissued certificates in days",
+					},
+					&cli.StringFlag{
+						Name:  "org",
+						Usage: "Specify the organization of the node certificates, the existing one is kept if not set",
+					},
+					&cli.StringSliceFlag{
+						Name:  "san",
+						Usage: "Specify the DNS names or IPs of the node certificates, the existing ones are kept if not set",
+					},
+				},
+				Action: renewCerts,
+			},
+		},
+	}
+}
+
+func showCert(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("please specify a certificate file")
+	}
+
+	path, err := filepath.Abs(ctx.Args().First())
+	if err != nil {
+		return fmt.Errorf("get absolute cert path: %w", err)
+	}
+	c, err := loadCertFile(path)
+	if err != nil {
+		return err
+	}
+	info := newCertInfo(path, c)
+
+	table := [][]string{
+		{"Field", "Value"},
+		{"Path", info.Path},
+		{"Subject", info.Subject},
+		{"Issuer", info.Issuer},
+		{"Org", strings.Join(info.Org, ", ")},
+		{"CA", fmt.Sprint(info.IsCA)},
+		{"Not Before", info.NotBefore.Format(time.RFC3339)},
+		{"Not After", fmt.Sprintf("%s (%s)", info.NotAfter.Format(time.RFC3339), certValidity(c, time.Now()))},
+		{"Key Type", info.KeyType},
+		{"SANs", strings.Join(info.SANs, ", ")},
+		{"Pid", info.Pid},
+	}
+
+	return printOutput(ctx, info, table)
+}
+
+func newCertInfo(path string, c *x509.Certificate) *CertInfo {
+	info := &CertInfo{
+		Path:      path,
+		Subject:   c.Subject.String(),
+		Issuer:    c.Issuer.String(),
+		Org:       c.Subject.Organization,
+		IsCA:      c.IsCA,
+		NotBefore: c.NotBefore,
+		NotAfter:  c.NotAfter,
+		KeyType:   c.PublicKeyAlgorithm.String(),
+		SANs:      append([]string{}, c.DNSNames...),
+	}
+	for _, ip := range c.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	if pub, ok := c.PublicKey.(*ecdsa.PublicKey); ok {
+		info.KeyType = fmt.Sprintf("%s %s", info.KeyType, pub.Curve.Params().Name)
+	}
+	if pid, err := certPid(c); err == nil {
+		info.Pid = pid
+	}
+
+	return info
+}
+
+// certPid returns the libp2p id of the public key in the certificate, which
+// is the same as the one derived from the private key by getPidFromPrivateKey
+func certPid(c *x509.Certificate) (string, error) {
+	pub, ok := c.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return "", fmt.Errorf("%s key has no pid", c.PublicKeyAlgorithm)
+	}
+	data, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	pk, err := crypto.UnmarshalECDSAPublicKey(data)
+	if err != nil {
+		return "", err
+	}
+	pid, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		return "", err
+	}
+
+	return pid.String(), nil
+}
+
+// certValidity describes whether the certificate is valid at the time
+func certValidity(c *x509.Certificate, now time.Time) string {
+	switch {
+	case now.Before(c.NotBefore):
+		return "not valid yet"
+	case now.After(c.NotAfter):
+		return "expired"
+	default:
+		return fmt.Sprintf("expires in %d days", int(c.NotAfter.Sub(now).Hours()/24))
+	}
+}
+
+func verifyCerts(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return fmt.Errorf("parse repo path error:%w", err)
+	}
+
+	target := ctx.String("target")
+	if target == "" {
+		target = filepath.Join(repoRoot, "bitxhub/.bitxhub")
+	}
+	if target, err = filepath.Abs(target); err != nil {
+		return fmt.Errorf("get absolute target path: %w", err)
+	}
+
+	names, err := bitxhubNodeNames(target)
+	if err != nil {
+		return err
+	}
+
+	var problems, warnings []string
+	var caCert *x509.Certificate
+	for _, name := range names {
+		certRoot := filepath.Join(target, name, "certs")
+		if err := verifyNodeCerts(certRoot); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+
+		// the nodes trust each other only if they share the same ca
+		ca, err := loadCertFile(repo.GetCertPath("ca", certRoot))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		if caCert == nil {
+			caCert = ca
+		} else if !bytes.Equal(caCert.Raw, ca.Raw) {
+			problems = append(problems, fmt.Sprintf("%s: ca.cert differs from the one of %s, the nodes can not trust each other", name, names[0]))
+		}
+
+		nodeCert, err := loadCertFile(repo.GetCertPath("node", certRoot))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		pid, err := getPidFromPrivateKey(repo.GetPrivKeyPath("node", certRoot))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: get pid from node.priv: %s", name, err))
+		} else if certPid, err := certPid(nodeCert); err != nil || certPid != pid {
+			problems = append(problems, fmt.Sprintf("%s: node.cert is not issued for node.priv", name))
+		}
+
+		for _, certName := range []string{"node", repo.AgencyName, "ca"} {
+			c, err := loadCertFile(repo.GetCertPath(certName, certRoot))
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s", name, err))
+				continue
+			}
+			problem, warning := certExpiry(c, time.Now(), ctx.Duration("expiry"))
+			if problem != "" {
+				problems = append(problems, fmt.Sprintf("%s: %s.cert %s", name, certName, problem))
+			}
+			if warning != "" {
+				warnings = append(warnings, fmt.Sprintf("%s: %s.cert %s", name, certName, warning))
+			}
+		}
+	}
+
+	for _, warning := range warnings {
+		color.Yellow(warning)
+	}
+	if len(problems) != 0 {
+		for _, problem := range problems {
+			color.Red(problem)
+		}
+		return fmt.Errorf("%d problems found in the certificates of %s", len(problems), target)
+	}
+
+	color.Green("certificates of %d BitXHub nodes in %s are valid", len(names), target)
+	return nil
+}
+
+// certExpiry returns the problem if the certificate is not valid at the time,
+// or the warning if it expires within the duration
+func certExpiry(c *x509.Certificate, now time.Time, within time.Duration) (string, string) {
+	if now.Before(c.NotBefore) || now.After(c.NotAfter) {
+		return fmt.Sprintf("is %s, valid from %s to %s", certValidity(c, now), c.NotBefore.Format(time.RFC3339), c.NotAfter.Format(time.RFC3339)), ""
+	}
+	if c.NotAfter.Sub(now) < within {
+		return "", fmt.Sprintf("%s at %s", certValidity(c, now), c.NotAfter.Format(time.RFC3339))
+	}
+
+	return "", ""
+}
+
+// CertOptions are the options of the reissued certificates
+type CertOptions struct {
+	Validity time.Duration
+	// Org replaces the organization of the subject if it is set
+	Org string
+	// SANs replace the DNS names and the IPs if they are set
+	SANs []string
+}
+
+func renewCerts(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return fmt.Errorf("parse repo path error:%w", err)
+	}
+
+	target := ctx.String("target")
+	if target == "" {
+		target = filepath.Join(repoRoot, "bitxhub/.bitxhub")
+	}
+	if target, err = filepath.Abs(target); err != nil {
+		return fmt.Errorf("get absolute target path: %w", err)
+	}
+
+	if ctx.Int("days") <= 0 {
+		return fmt.Errorf("days should be positive")
+	}
+	opts := &CertOptions{
+		Validity: time.Duration(ctx.Int("days")) * 24 * time.Hour,
+		Org:      ctx.String("org"),
+		SANs:     ctx.StringSlice("san"),
+	}
+
+	names, err := bitxhubNodeNames(target)
+	if err != nil {
+		return err
+	}
+	renewed := names
+	if n := ctx.Int("node"); n != 0 {
+		name := bitxhub.NodeName(types.ClusterMode, n)
+		if names[0] == bitxhub.NodeName(types.SoloMode, 1) && n == 1 {
+			name = names[0]
+		}
+		if !containsString(names, name) {
+			return fmt.Errorf("node %d is not found in %s", n, target)
+		}
+		renewed = []string{name}
+	}
+
+	agencyPriv, agencyCert, err := agencyKey(target)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("agency") {
+		caPriv, caCert, err := caKey(target)
+		if err != nil {
+			return fmt.Errorf("reissue agency.cert: %w", err)
+		}
+		// the agency.cert given by the modify config may be shared by other
+		// clusters and piers, so it is reissued as a copy in the target
+		if reissued := repo.GetCertPath(repo.AgencyName, target); agencyCert != reissued {
+			if err := copyFile(reissued, agencyCert); err != nil {
+				return fmt.Errorf("copy agency.cert to %s: %w", target, err)
+			}
+			agencyCert = reissued
+		}
+		if err := renewCert(agencyCert, caPriv, caCert, true, &CertOptions{Validity: opts.Validity}); err != nil {
+			return fmt.Errorf("reissue agency.cert: %w", err)
+		}
+		// the agency is shared by all nodes
+		for _, name := range names {
+			if err := copyFile(repo.GetCertPath(repo.AgencyName, filepath.Join(target, name, "certs")), agencyCert); err != nil {
+				return fmt.Errorf("copy agency.cert to %s: %w", name, err)
+			}
+		}
+		color.Green("agency.cert in %s is reissued", target)
+	}
+
+	for _, name := range renewed {
+		certRoot := filepath.Join(target, name, "certs")
+		if err := renewCert(repo.GetCertPath("node", certRoot), agencyPriv, agencyCert, false, opts); err != nil {
+			return fmt.Errorf("reissue node.cert of %s: %w", name, err)
+		}
+		if err := verifyNodeCerts(certRoot); err != nil {
+			return fmt.Errorf("verify the reissued node.cert of %s: %w", name, err)
+		}
+		color.Green("node.cert of %s is reissued", name)
+	}
+
+	fmt.Println("the keys are kept, restart the nodes to load the reissued certificates")
+	return nil
+}
+
+// renewCert reissues the certificate at path by the issuer, the public key
+// and the subject are kept, so is the pid derived from the key
+func renewCert(path, issuerPrivPath, issuerCertPath string, isCA bool, opts *CertOptions) error {
+	old, err := loadCertFile(path)
+	if err != nil {
+		return err
+	}
+	issuerCert, err := loadCertFile(issuerCertPath)
+	if err != nil {
+		return err
+	}
+	privData, err := ioutil.ReadFile(issuerPrivPath)
+	if err != nil {
+		return fmt.Errorf("read issuer private key: %w", err)
+	}
+	block, _ := pem.Decode(privData)
+	if block == nil {
+		return fmt.Errorf("decode issuer private key %s", issuerPrivPath)
+	}
+	issuerKey, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("parse issuer private key: %w", err)
+	}
+
+	sn, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return err
+	}
+
+	notBefore := time.Now().Add(-5 * time.Minute).UTC()
+	template := &x509.Certificate{
+		SerialNumber:          sn,
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(opts.Validity).UTC(),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		Issuer:                issuerCert.Subject,
+		KeyUsage:              old.KeyUsage,
+		ExtKeyUsage:           old.ExtKeyUsage,
+		Subject:               old.Subject,
+		DNSNames:              old.DNSNames,
+		IPAddresses:           old.IPAddresses,
+	}
+	if opts.Org != "" {
+		template.Subject.Organization = []string{opts.Org}
+	}
+	if len(opts.SANs) != 0 {
+		template.DNSNames, template.IPAddresses = nil, nil
+		for _, san := range opts.SANs {
+			if ip := net.ParseIP(san); ip != nil {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			} else {
+				template.DNSNames = append(template.DNSNames, san)
+			}
+		}
+	}
+
+	data, err := x509.CreateCertificate(rand.Reader, template, issuerCert, old.PublicKey, issuerKey)
+	if err != nil {
+		return fmt.Errorf("create cert: %w", err)
+	}
+
+	return ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: data}), 0644)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestCertPid(t *testing.T) {
+	target, err := ioutil.TempDir("", "cert")
+	require.Nil(t, err)
+	defer os.RemoveAll(target)
+
+	nodes := newTestCluster(t, target, 2)
+	for _, node := range nodes {
+		c, err := loadCertFile(repo.GetCertPath("node", filepath.Join(node.root, "certs")))
+		require.Nil(t, err)
+		pid, err := certPid(c)
+		require.Nil(t, err)
+		require.Equal(t, node.pid, pid)
+	}
+	require.NotEqual(t, nodes[0].pid, nodes[1].pid)
+}
+
+func TestCertExpiry(t *testing.T) {
+	target, err := ioutil.TempDir("", "cert")
+	require.Nil(t, err)
+	defer os.RemoveAll(target)
+
+	nodes := newTestCluster(t, target, 1)
+	c, err := loadCertFile(repo.GetCertPath("node", filepath.Join(nodes[0].root, "certs")))
+	require.Nil(t, err)
+
+	within := 30 * 24 * time.Hour
+	tests := []struct {
+		name    string
+		now     time.Time
+		problem string
+		warning string
+	}{
+		{name: "valid", now: c.NotBefore.Add(time.Hour)},
+		{name: "not valid yet", now: c.NotBefore.Add(-time.Hour), problem: "is not valid yet"},
+		{name: "expired", now: c.NotAfter.Add(time.Hour), problem: "is expired"},
+		{name: "expiring", now: c.NotAfter.Add(-10*24*time.Hour - time.Hour), warning: "expires in 10 days"},
+		{name: "expiring at the bound", now: c.NotAfter.Add(-within + time.Minute), warning: "expires in 29 days"},
+		{name: "not expiring yet", now: c.NotAfter.Add(-within - time.Minute)},
+	}
+	for _, test := range tests {
+		problem, warning := certExpiry(c, test.now, within)
+		if test.problem == "" {
+			require.Empty(t, problem, test.name)
+		} else {
+			require.Contains(t, problem, test.problem, test.name)
+		}
+		if test.warning == "" {
+			require.Empty(t, warning, test.name)
+		} else {
+			require.Contains(t, warning, test.warning, test.name)
+		}
+	}
+}
+
+func TestRenewCert(t *testing.T) {
+	target, err := ioutil.TempDir("", "cert")
+	require.Nil(t, err)
+	defer os.RemoveAll(target)
+
+	nodes := newTestCluster(t, target, 1)
+	certRoot := filepath.Join(nodes[0].root, "certs")
+	certPath := repo.GetCertPath("node", certRoot)
+	old, err := loadCertFile(certPath)
+	require.Nil(t, err)
+
+	opts := &CertOptions{
+		Validity: 10 * 24 * time.Hour,
+		Org:      "Renewed",
+		SANs:     []string{"node1.example.com", "10.0.0.1"},
+	}
+	agencyPriv := repo.GetPrivKeyPath(repo.AgencyName, target)
+	agencyCert := repo.GetCertPath(repo.AgencyName, target)
+	require.Nil(t, renewCert(certPath, agencyPriv, agencyCert, false, opts))
+
+	c, err := loadCertFile(certPath)
+	require.Nil(t, err)
+	require.Equal(t, old.PublicKey, c.PublicKey)
+	require.NotEqual(t, old.SerialNumber, c.SerialNumber)
+	pid, err := certPid(c)
+	require.Nil(t, err)
+	require.Equal(t, nodes[0].pid, pid)
+
+	require.Equal(t, []string{"Renewed"}, c.Subject.Organization)
+	require.Equal(t, old.Subject.CommonName, c.Subject.CommonName)
+	require.Equal(t, []string{"node1.example.com"}, c.DNSNames)
+	require.Equal(t, 1, len(c.IPAddresses))
+	require.Equal(t, "10.0.0.1", c.IPAddresses[0].String())
+	require.WithinDuration(t, time.Now().Add(opts.Validity), c.NotAfter, time.Hour)
+	require.False(t, c.IsCA)
+
+	require.Nil(t, verifyNodeCerts(certRoot))
+}
+
+func TestRenewSharedAgency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cert")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// the cluster is generated by the scripts from the agency shared by the
+	// modify config, which is not in the target
+	target := filepath.Join(dir, ".bitxhub")
+	shared := filepath.Join(dir, "certs")
+	nodes := newTestCluster(t, target, 2)
+	require.Nil(t, os.MkdirAll(shared, 0755))
+	for _, name := range []string{"ca.priv", "ca.cert", "agency.priv", "agency.cert"} {
+		require.Nil(t, os.Rename(filepath.Join(target, name), filepath.Join(shared, name)))
+	}
+	config := fmt.Sprintf("[bitxhub]\n  agency_priv_path = %s\n  agency_cert_path = %s\n  ca_cert_path = %s\n  ca_priv_path = %s\n",
+		filepath.Join(shared, "agency.priv"), filepath.Join(shared, "agency.cert"), filepath.Join(shared, "ca.cert"), filepath.Join(shared, "ca.priv"))
+	require.Nil(t, ioutil.WriteFile(target+"_"+types.BxhModifyConfig, []byte(config), 0644))
+	sharedCert, err := ioutil.ReadFile(filepath.Join(shared, "agency.cert"))
+	require.Nil(t, err)
+
+	app := cli.NewApp()
+	app.Commands = []*cli.Command{certCMD()}
+	require.Nil(t, app.Run([]string{"goduck", "cert", "renew", "--target", target, "--agency", "--days", "10"}))
+
+	// the shared agency.cert is kept, the reissued one is in the target and the nodes
+	data, err := ioutil.ReadFile(filepath.Join(shared, "agency.cert"))
+	require.Nil(t, err)
+	require.Equal(t, sharedCert, data)
+	reissued, err := ioutil.ReadFile(repo.GetCertPath(repo.AgencyName, target))
+	require.Nil(t, err)
+	require.NotEqual(t, sharedCert, reissued)
+	for _, node := range nodes {
+		certRoot := filepath.Join(node.root, "certs")
+		data, err := ioutil.ReadFile(repo.GetCertPath(repo.AgencyName, certRoot))
+		require.Nil(t, err)
+		require.Equal(t, reissued, data)
+		require.Nil(t, verifyNodeCerts(certRoot))
+	}
+
+	// the next renewal issues the nodes by the reissued agency.cert
+	_, agencyCert, err := agencyKey(target)
+	require.Nil(t, err)
+	require.Equal(t, repo.GetCertPath(repo.AgencyName, target), agencyCert)
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cheynewallace/tabby"
+	"github.com/docker/docker/client"
+	"github.com/fatih/color"
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck"
+	"github.com/meshplus/goduck/internal/logs"
+	"github.com/meshplus/goduck/internal/release"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/pelletier/go-toml"
+	"github.com/urfave/cli/v2"
+)
+
+const redacted = "<redacted>"
+
+var (
+	// secretRegexp matches the assignments of secrets in configs and logs, e.g. password = "123"
+	secretRegexp = regexp.MustCompile(`(?i)((?:password|passwd|pwd|secret|private_?key|priv_?key|mnemonic|token)"?\s*[=:]\s*)("[^"]*"|'[^']*'|\S+)`)
+	// pemRegexp matches the pem encoded private keys
+	pemRegexp = regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`)
+
+	// collectedExts are the extensions of the collected config files
+	collectedExts = map[string]bool{".toml": true, ".json": true, ".yaml": true, ".yml": true}
+	// excludedNames are the files which hold keys or passwords, they are never collected
+	excludedNames = map[string]bool{"key.json": true, "password": true, "account.key": true, "adminKey.json": true}
+	excludedExts  = map[string]bool{".priv": true, ".key": true, ".pem": true}
+)
+
+// bundle is a gzipped tar archive, all the entries are under the prefix directory.
+type bundle struct {
+	tw     *tar.Writer
+	prefix string
+	now    time.Time
+	// skipped records the files not collected because of errors
+	skipped []string
+}
+
+func collectLog(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+	if !fileutil.Exist(repoRoot) {
+		return fmt.Errorf("please `goduck init` first")
+	}
+
+	now := time.Now()
+	prefix := fmt.Sprintf("goduck-diagnostics-%s", now.Format("20060102-150405"))
+	dest := ctx.String("dest")
+	if dest == "" {
+		dest = prefix + ".tar.gz"
+	}
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", dest, err)
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	b := &bundle{
+		tw:     tar.NewWriter(gw),
+		prefix: prefix,
+		now:    now.Truncate(time.Second),
+	}
+
+	if err := b.collect(ctx, repoRoot); err != nil {
+		return err
+	}
+
+	if len(b.skipped) != 0 {
+		b.addBytes("skipped.txt", []byte(strings.Join(b.skipped, "\n")+"\n"))
+	}
+
+	if err := b.tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+
+	color.Green("Diagnostic bundle is written to %s", dest)
+	for _, s := range b.skipped {
+		color.Yellow("skipped: %s", s)
+	}
+
+	return nil
+}
+
+func (b *bundle) collect(ctx *cli.Context, repoRoot string) error {
+	// versions and system info
+	b.addBytes("system.txt", systemInfo(ctx.Context))
+	if data, err := versionsInfo(repoRoot); err != nil {
+		b.skip("versions.txt", err)
+	} else {
+		b.addBytes("versions.txt", data)
+	}
+	if err := b.addFile("release.json", filepath.Join(repoRoot, "release.json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		b.skip(filepath.Join(repoRoot, "release.json"), err)
+	}
+	for _, dir := range []string{"bitxhub", "pier"} {
+		versions, _ := filepath.Glob(filepath.Join(repoRoot, dir, "*.version"))
+		for _, path := range versions {
+			b.addFileOrSkip(filepath.Join("versions", filepath.Base(path)), path)
+		}
+	}
+	b.addFileOrSkip(filepath.Join("bitxhub", types.BitxhubStateFile), filepath.Join(repoRoot, "bitxhub", types.BitxhubStateFile))
+
+	// status of the components
+	statuses, err := listComponents(ctx.Context)
+	if err != nil {
+		b.skip("status", err)
+	} else {
+		data, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return err
+		}
+		b.addBytes("status.json", data)
+		b.addBytes("status.txt", statusTable(statuses))
+	}
+
+	// configs and logs of bitxhub nodes and piers started in binary
+	nodes, err := bxhNodeRepos(repoRoot)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		b.skip("bitxhub nodes", err)
+	}
+	for _, node := range nodes {
+		b.addRepo(filepath.Join("bitxhub", filepath.Base(node)), node, repo.BitXHubConfigName, "bitxhub.log", "")
+	}
+
+	piers, err := pierRepos(repoRoot)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		b.skip("piers", err)
+	}
+	for _, pier := range piers {
+		appchain := ""
+		if tree, err := toml.LoadFile(filepath.Join(pier, repo.PierConfigName)); err == nil {
+			appchain, _ = tree.Get("appchain.config").(string)
+		}
+		b.addRepo(filepath.Join("pier", repoName(pier, "pier")), pier, repo.PierConfigName, "pier.log", appchain)
+	}
+
+	// logs of the containers
+	for _, component := range []string{"bitxhub", "pier"} {
+		sources, err := dockerSources(ctx.Context, component, "", false, 0)
+		if err != nil {
+			if !client.IsErrConnectionFailed(errors.Unwrap(err)) {
+				b.skip(component+" containers", err)
+			}
+			continue
+		}
+		for _, source := range sources {
+			b.addContainerLog(ctx.Context, source)
+		}
+	}
+
+	return nil
+}
+
+// addRepo adds the top level config files, the config files of the appchain
+// plugin and the logs of a node repo. The key files are never added.
+func (b *bundle) addRepo(name, nodeRepo, configName, logName, pluginDir string) {
+	infos, err := ioutil.ReadDir(nodeRepo)
+	if err != nil {
+		b.skip(nodeRepo, err)
+		return
+	}
+	for _, info := range infos {
+		if !info.IsDir() && (collectable(info.Name()) || filepath.Ext(info.Name()) == ".out") {
+			b.addFileOrSkip(filepath.Join(name, info.Name()), filepath.Join(nodeRepo, info.Name()))
+		}
+	}
+
+	if pluginDir != "" {
+		infos, err := ioutil.ReadDir(filepath.Join(nodeRepo, pluginDir))
+		if err == nil {
+			for _, info := range infos {
+				if !info.IsDir() && collectable(info.Name()) {
+					b.addFileOrSkip(filepath.Join(name, pluginDir, info.Name()), filepath.Join(nodeRepo, pluginDir, info.Name()))
+				}
+			}
+		}
+	}
+
+	logDir := filepath.Dir(logPath(nodeRepo, configName, logName))
+	infos, err = ioutil.ReadDir(logDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			b.skip(logDir, err)
+		}
+		return
+	}
+	for _, info := range infos {
+		if !info.IsDir() && !excluded(info.Name()) {
+			b.addFileOrSkip(filepath.Join(name, "logs", info.Name()), filepath.Join(logDir, info.Name()))
+		}
+	}
+}
+
+func (b *bundle) addContainerLog(ctx context.Context, source logs.Source) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var buf bytes.Buffer
+	err := source.Read(ctx, -1, time.Time{}, false, func(line string, t time.Time) {
+		if !t.IsZero() {
+			buf.WriteString(t.Format(time.RFC3339Nano) + " ")
+		}
+		buf.WriteString(strings.TrimRight(line, "\r\n") + "\n")
+	})
+	if err != nil {
+		b.skip("container "+source.Name(), err)
+		return
+	}
+
+	b.addBytes(filepath.Join("containers", source.Name()+".log"), redact(buf.Bytes()))
+}
+
+func (b *bundle) addFileOrSkip(name, path string) {
+	if err := b.addFile(name, path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		b.skip(path, err)
+	}
+}
+
+// addFile adds the file with the secrets in it redacted, the key files are never added
+func (b *bundle) addFile(name, path string) error {
+	if excluded(filepath.Base(path)) {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	b.addBytes(name, redact(data))
+	return nil
+}
+
+func (b *bundle) addBytes(name string, data []byte) {
+	hdr := &tar.Header{
+		Name:    filepath.ToSlash(filepath.Join(b.prefix, name)),
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: b.now,
+	}
+	if err := b.tw.WriteHeader(hdr); err != nil {
+		b.skip(name, err)
+		return
+	}
+	if _, err := b.tw.Write(data); err != nil {
+		b.skip(name, err)
+	}
+}
+
+func (b *bundle) skip(what string, err error) {
+	b.skipped = append(b.skipped, fmt.Sprintf("%s: %s", what, err))
+}
+
+func collectable(name string) bool {
+	return collectedExts[filepath.Ext(name)] && !excluded(name)
+}
+
+func excluded(name string) bool {
+	return excludedNames[name] || excludedExts[filepath.Ext(name)]
+}
+
+// redact replaces the private keys and the values of the secret fields
+func redact(data []byte) []byte {
+	data = pemRegexp.ReplaceAll(data, []byte(redacted))
+	return secretRegexp.ReplaceAll(data, []byte("${1}"+redacted))
+}
+
+func statusTable(statuses []*ComponentStatus) []byte {
+	var buf bytes.Buffer
+	t := tabby.NewCustom(tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0))
+	addRow(t, statusHeader, true)
+	for _, s := range statuses {
+		addRow(t, s.row(), false)
+	}
+	t.Print()
+
+	return buf.Bytes()
+}
+
+// versionsInfo is the output of `goduck version all`
+func versionsInfo(repoRoot string) ([]byte, error) {
+	m, err := release.Load(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	t := tabby.NewCustom(tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0))
+	for i, row := range allVersionTable(m) {
+		addRow(t, row, i == 0)
+	}
+	t.Print()
+
+	return buf.Bytes(), nil
+}
+
+func systemInfo(ctx context.Context) []byte {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Collected at: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&buf, "Goduck version: %s-%s-%s\n", goduck.CurrentVersion, goduck.CurrentBranch, goduck.CurrentCommit)
+	fmt.Fprintf(&buf, "OS/Arch: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&buf, "CPUs: %d\n", runtime.NumCPU())
+	if out, err := exec.Command("uname", "-a").Output(); err == nil {
+		fmt.Fprintf(&buf, "Uname: %s", out)
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err == nil {
+		defer cli.Close()
+		c, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if v, err := cli.ServerVersion(c); err == nil {
+			fmt.Fprintf(&buf, "Docker version: %s (api %s, %s/%s)\n", v.Version, v.APIVersion, v.Os, v.Arch)
+			return buf.Bytes()
+		}
+	}
+	fmt.Fprintf(&buf, "Docker version: docker is not available\n")
+
+	return buf.Bytes()
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionsInfo(t *testing.T) {
+	repoRoot, err := ioutil.TempDir("", "collect")
+	require.Nil(t, err)
+	defer os.RemoveAll(repoRoot)
+
+	_, err = versionsInfo(repoRoot)
+	require.NotNil(t, err)
+
+	require.Nil(t, copyFile(filepath.Join(repoRoot, types.ReleaseJson), filepath.Join("../../scripts", types.ReleaseJson)))
+	data, err := versionsInfo(repoRoot)
+	require.Nil(t, err)
+	require.Contains(t, string(data), "Versions")
+	require.Contains(t, string(data), "v1.11.3")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/meshplus/goduck/internal/release"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/urfave/cli/v2"
+)
+
+// Compat is a compatible combination of BitXHub and pier.
+type Compat struct {
+	BitXHub          string            `json:"bitxhub" yaml:"bitxhub"`
+	BitXHubConfig    string            `json:"bitxhub_config" yaml:"bitxhub_config"`
+	Pier             string            `json:"pier" yaml:"pier"`
+	PierConfig       string            `json:"pier_config" yaml:"pier_config"`
+	Plugins          map[string]string `json:"plugins" yaml:"plugins"`
+	EthereumContract string            `json:"ethereum_contract" yaml:"ethereum_contract"`
+}
+
+func compatVersion(ctx *cli.Context) error {
+	bxhVersion := ctx.String("bitxhub")
+	pierVersion := ctx.String("pier")
+	appchain := ctx.String("appchain")
+
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+
+	m, err := release.Load(repoRoot)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Check(bxhVersion, pierVersion, appchain); err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	var compats []*Compat
+	table := [][]string{{"BitXHub", "BitXHub Config", "Pier", "Pier Config", "Plugins", "Ethereum Contract"}}
+	for _, bxh := range m.BitXHub {
+		if bxhVersion != "" && bxh.Version != bxhVersion {
+			continue
+		}
+		for _, pierV := range bxh.Pier {
+			if pierVersion != "" && pierV != pierVersion {
+				continue
+			}
+			p, err := m.FindPier(pierV)
+			if err != nil {
+				return fmt.Errorf("BitXHub %s: %w", bxh.Version, err)
+			}
+
+			compats = append(compats, &Compat{
+				BitXHub:          bxh.Version,
+				BitXHubConfig:    bxh.Config,
+				Pier:             p.Version,
+				PierConfig:       p.Config,
+				Plugins:          p.Plugins,
+				EthereumContract: p.EthereumContract,
+			})
+			table = append(table, []string{bxh.Version, bxh.Config, p.Version, p.Config, pluginsString(p.Plugins), p.EthereumContract})
+		}
+	}
+
+	if err := printOutput(ctx, compats, table); err != nil {
+		return err
+	}
+
+	if bxhVersion != "" || pierVersion != "" || appchain != "" {
+		if ctx.String("output") == OutputTable {
+			color.Green("The combination is compatible")
+		}
+	}
+
+	return nil
+}
+
+func pluginsString(plugins map[string]string) string {
+	var s []string
+	for appchain, version := range plugins {
+		s = append(s, fmt.Sprintf("%s:%s", appchain, version))
+	}
+	sort.Strings(s)
+
+	return strings.Join(s, ",")
+}
+
+// bxhRelease returns the release of the BitXHub version, it fails if the
+// version is not supported.
+func bxhRelease(repoRoot, version string) (*release.BitXHub, error) {
+	m, err := release.Load(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.FindBitXHub(version)
+}
+
+// pierRelease returns the release of the pier version, it fails if the
+// version is not supported.
+func pierRelease(repoRoot, version string) (*release.Pier, error) {
+	m, err := release.Load(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.FindPier(version)
+}
+
+// ethContractVersion returns the version of the ethereum contract config used
+// with the BitXHub version.
+func ethContractVersion(repoRoot, bxhVersion string) (string, error) {
+	m, err := release.Load(repoRoot)
+	if err != nil {
+		return "", err
+	}
+
+	return m.EthereumContract(bxhVersion)
+}
@@ -16,6 +16,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"text/template"
@@ -30,6 +31,8 @@ import (
 	"github.com/meshplus/bitxhub-kit/fileutil"
 	"github.com/meshplus/bitxhub/pkg/cert"
 	libp2pcert "github.com/meshplus/go-libp2p-cert"
+	"github.com/meshplus/goduck/internal/capability"
+	"github.com/meshplus/goduck/internal/ports"
 	"github.com/meshplus/goduck/internal/repo"
 	"github.com/meshplus/goduck/internal/types"
 	"github.com/pelletier/go-toml"
@@ -96,75 +99,45 @@ type Genesis struct {
 }
 
 type Admin struct {
-	Address string `json:"address" toml:"address"`
-	Weight  uint64 `json:"weight" toml:"weight"`
+	Address string `json:"address" toml:"address" yaml:"address"`
+	Weight  uint64 `json:"weight" toml:"weight" yaml:"weight"`
 }
 
 type BitXHubConfigGenerator struct {
-	typ     string
-	mode    string
-	target  string
-	num     int
-	ips     []string
-	tls     bool
-	version string
+	repoRoot string
+	typ      string
+	mode     string
+	target   string
+	num      int
+	ips      []string
+	tls      bool
+	version  string
+	// spec selects the consensus and the governance
+	spec *GenesisSpec
+	// ca is the trust root which the nodes are issued under
+	ca *BitXHubCA
+	// ports are the allocated ports of the nodes
+	ports []ports.Ports
 }
 
 type PierConfigGenerator struct {
-	id                   string
-	mode                 string
-	startType            string
-	bitxhub              string
-	validators           []string
-	port                 string
-	peers                []string
-	connectors           []string
-	providers            string
-	appchainType         string
-	appchainIP           string
-	appchainAddr         string
-	appPorts             []string
-	appchainContractAddr string
-	target               string
-	tls                  string
-	httpPort             string
-	pprofPort            string
-	apiPort              string
-	version              string
-	pierPath             string
-	cryptoPath           string
-	method               string
+	opts *PierConfigOptions
+	// id is the p2p id of the pier, only for direct mode
+	id string
 }
 
-func NewBitXHubConfigGenerator(typ string, mode string, target string, num int, ips []string, tls bool, version string) *BitXHubConfigGenerator {
-	return &BitXHubConfigGenerator{typ: typ, mode: mode, target: target, num: num, ips: ips, tls: tls, version: version}
+func NewBitXHubConfigGenerator(repoRoot, typ string, mode string, target string, num int, ips []string, tls bool, version string, spec *GenesisSpec, ca *BitXHubCA) *BitXHubConfigGenerator {
+	if spec == nil {
+		spec = &GenesisSpec{}
+	}
+	if ca == nil {
+		ca = &BitXHubCA{}
+	}
+	return &BitXHubConfigGenerator{repoRoot: repoRoot, typ: typ, mode: mode, target: target, num: num, ips: ips, tls: tls, version: version, spec: spec, ca: ca}
 }
 
-func NewPierConfigGenerator(mode, startType, bitxhub string, validators []string, port string, peers, connectors []string, providers, appchainType, appchainIP, appchainAddr string, appPorts []string, appchainContractAddr, target, tls, httpPort, pprofPort, apiPort, version, pierPath, cryptoPath, method string) *PierConfigGenerator {
-	return &PierConfigGenerator{
-		mode:                 mode,
-		startType:            startType,
-		bitxhub:              bitxhub,
-		validators:           validators,
-		port:                 port,
-		peers:                peers,
-		connectors:           connectors,
-		providers:            providers,
-		appchainType:         appchainType,
-		appchainIP:           appchainIP,
-		appchainAddr:         appchainAddr,
-		appPorts:             appPorts,
-		appchainContractAddr: appchainContractAddr,
-		target:               target,
-		tls:                  tls,
-		httpPort:             httpPort,
-		pprofPort:            pprofPort,
-		apiPort:              apiPort,
-		version:              version,
-		pierPath:             pierPath,
-		cryptoPath:           cryptoPath,
-		method:               method,
-	}
+func NewPierConfigGenerator(opts *PierConfigOptions) *PierConfigGenerator {
+	return &PierConfigGenerator{opts: opts}
 }
 
 // BitXHubConfigGenerator ===========================================================================
@@ -172,8 +145,8 @@ func NewPierConfigGenerator(mode, startType, bitxhub string, validators []string
 func (b *BitXHubConfigGenerator) Initialized() (bool, error) {
 	if fileutil.Exist(repo.GetCAPrivKeyPath(b.target)) ||
 		fileutil.Exist(repo.GetCACertPath(b.target)) ||
-		fileutil.Exist(repo.GetPrivKeyPath(b.target, repo.AgencyName)) ||
-		fileutil.Exist(repo.GetCertPath(b.target, repo.AgencyName)) {
+		fileutil.Exist(repo.GetPrivKeyPath(repo.AgencyName, b.target)) ||
+		fileutil.Exist(repo.GetCertPath(repo.AgencyName, b.target)) {
 		return true, nil
 	}
 
@@ -193,7 +166,8 @@ func (b *BitXHubConfigGenerator) Initialized() (bool, error) {
 }
 
 func (b *BitXHubConfigGenerator) CleanOldConfig() error {
-	if err := os.Remove(repo.GetCAPrivKeyPath(b.target)); err != nil {
+	// the key of an existing ca is not copied to the target
+	if err := os.Remove(repo.GetCAPrivKeyPath(b.target)); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("remove ca private key: %w", err)
 	}
 	if err := os.Remove(repo.GetCACertPath(b.target)); err != nil {
@@ -258,17 +232,14 @@ func (b *BitXHubConfigGenerator) InitConfig() error {
 		}
 	}
 
-	// generate ca for bitxhub, return path of ca.priv and ca.cert
-	caPrivKey, caCertPath, err := generateCA(b.target)
+	// write ca.cert, agency.priv and agency.cert of the trust root, return path of agency.priv and agency.cert
+	agencyPrivKey, agencyCertPath, err := b.ca.Issue(b.target)
 	if err != nil {
-		return fmt.Errorf("generate CA: %w", err)
+		return err
 	}
 
-	// generate cert for bitxhub base ca.priv and ca.cert, return path of agency.priv and agency.cert
-	agencyPrivKey, agencyCertPath, err := generateCert(repo.AgencyName, strings.ToUpper(repo.AgencyName), b.target,
-		caPrivKey, caCertPath, true)
-	if err != nil {
-		return fmt.Errorf("generate agency cert: %w", err)
+	if err := b.allocatePorts(); err != nil {
+		return fmt.Errorf("allocate ports: %w", err)
 	}
 
 	addrs, nodes, err := b.generateNodesConfig(b.target, b.mode, agencyPrivKey, agencyCertPath, b.ips)
@@ -277,7 +248,7 @@ func (b *BitXHubConfigGenerator) InitConfig() error {
 		return fmt.Errorf("generate nodes config: %w", err)
 	}
 
-	if err := writeNetworkAndGenesis(b.target, b.mode, addrs, nodes, b.version); err != nil {
+	if err := writeNetworkAndGenesis(b.target, b.mode, addrs, nodes, b.version, b.spec); err != nil {
 		return fmt.Errorf("write network and genesis config: %w", err)
 	}
 
@@ -312,10 +283,6 @@ func (b *BitXHubConfigGenerator) ProcessParams() error {
 		return fmt.Errorf("IPs' number is not equal to nodes' number")
 	}
 
-	if len(b.ips) == 0 && b.num >= 10 {
-		return fmt.Errorf("can not create more than 10 nodes with one IP address")
-	}
-
 	if err := checkIPs(b.ips); err != nil {
 		return err
 	}
@@ -337,196 +304,192 @@ func (b *BitXHubConfigGenerator) ProcessParams() error {
 		return fmt.Errorf("there are at least 3 nodes in cluster mode")
 	}
 
+	if err := b.spec.Validate(b.version, b.mode, b.num); err != nil {
+		return fmt.Errorf("invalid genesis spec: %w", err)
+	}
+
+	if err := b.ca.Validate(); err != nil {
+		return fmt.Errorf("invalid ca: %w", err)
+	}
+
 	return nil
 }
 
 // PierConfigGenerator ===========================================================================
+// Initialized determines whether the Pier profiles already exists under the target path.
 func (p *PierConfigGenerator) Initialized() (bool, error) {
-	if fileutil.Exist(filepath.Join(p.target, repo.PierConfigName)) {
+	if fileutil.Exist(filepath.Join(p.opts.Target, repo.PierConfigName)) {
 		return true, nil
 	}
 
-	return existDir(filepath.Join(p.target, p.appchainType))
+	return existDir(filepath.Join(p.opts.Target, p.opts.AppchainType))
 }
 
-func (p *PierConfigGenerator) copyConfigFiles() error {
-	validators := ""
-	peers := ""
-	connectors := ""
-	localIP := "0.0.0.0"
-	if p.startType == "docker" {
-		localIP = "host.docker.internal"
-		p.cryptoPath = fmt.Sprintf("/root/.pier/%s/crypto-config", p.appchainType)
-		if p.appchainIP == "127.0.0.1" {
-			p.appchainIP = localIP
-		}
-		if strings.Contains(p.appchainAddr, "127.0.0.1") {
-			p.appchainAddr = strings.Replace(p.appchainAddr, "127.0.0.1", localIP, -1)
-		}
+// generatePierKeyAndID runs `pier init` in a temporary repo, and copies the
+// keys of the version to the target. It returns the p2p id of the pier.
+func (p *PierConfigGenerator) generatePierKeyAndID() (string, error) {
+	tmpPath, err := ioutil.TempDir(p.opts.Target, types.TmpPath)
+	if err != nil {
+		return "", err
 	}
+	defer os.RemoveAll(tmpPath)
 
-	if p.mode == types.PierModeRelay {
-		for _, v := range p.validators {
-			validators += "\"" + v + "\",\n"
-		}
-	} else if p.mode == types.PierModeDirect {
-		for _, v := range p.peers {
-			peers += "\"" + v + "\",\n"
+	// pier init key
+	// version >= v1.4.0 : key.json, node.priv
+	// version < v1.4.0- : key.json
+	args := []string{"init"}
+	if capability.Has(p.opts.Version, capability.PierInitMode) {
+		args = append(args, p.opts.Mode)
+	}
+	if _, err := p.runPier(tmpPath, args...); err != nil {
+		return "", fmt.Errorf("pier init key: %w", err)
+	}
+	keys := []string{repo.KeyName}
+	if capability.Has(p.opts.Version, capability.PierNodeKey) {
+		keys = append(keys, repo.NodeKeyName)
+	}
+	if capability.Has(p.opts.Version, capability.PierAdminJSON) {
+		keys = append(keys, repo.AdminKeyName)
+	}
+	for _, k := range keys {
+		if err := copyFile(filepath.Join(p.opts.Target, k), filepath.Join(tmpPath, k)); err != nil {
+			return "", fmt.Errorf("copy pier %s: %w", k, err)
 		}
-		peers += "\"" + fmt.Sprintf("/ip4/%s/tcp/%s/p2p/%s", localIP, p.port, p.id) + "\",\n"
-	} else if p.mode == types.PierModeUnion {
-		for _, v := range p.connectors {
-			connectors += "\"" + v + "\",\n"
+	}
+
+	// pier p2p id
+	out, err := p.runPier(tmpPath, "p2p", "id")
+	if err != nil {
+		return "", fmt.Errorf("get pier id: %w", err)
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+// runPier runs the pier binary in BinPath with the repo
+func (p *PierConfigGenerator) runPier(pierRepo string, args ...string) (string, error) {
+	pierPath := filepath.Join(p.opts.BinPath, "pier")
+	session := sh.NewSession()
+	switch runtime.GOOS {
+	case types.LinuxSystem:
+		session.SetEnv("LD_LIBRARY_PATH", os.Getenv("LD_LIBRARY_PATH")+":"+p.opts.BinPath)
+	case types.DarwinSystem:
+		if err := session.Command("install_name_tool", "-change", "@rpath/libwasmer.dylib", filepath.Join(p.opts.BinPath, "libwasmer.dylib"), pierPath).Run(); err != nil {
+			return "", fmt.Errorf("change the path of libwasmer.dylib: %w", err)
 		}
-	} else {
-		return fmt.Errorf("pier does not support the mode")
 	}
 
-	pluginConfig := p.appchainType
+	cmdArgs := []interface{}{"--repo", pierRepo}
+	for _, arg := range args {
+		cmdArgs = append(cmdArgs, arg)
+	}
+	out, err := session.Command(pierPath, cmdArgs...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("pier %s: %s: %w", strings.Join(args, " "), strings.TrimSpace(string(out)), err)
+	}
+
+	return string(out), nil
+}
+
+// copyConfigFiles renders pier.toml and the api from their templates, copies
+// the tls certs and the appchain plugin, and renders the appchain configs
+func (p *PierConfigGenerator) copyConfigFiles() error {
+	appchain, err := GetPierAppchain(p.opts.AppchainType)
+	if err != nil {
+		return err
+	}
 
 	pluginFile := "appchain_plugin"
-	if p.version == "v1.0.0" || p.version == "v1.0.0-rc1" {
-		if p.appchainType == types.ChainTypeEther {
+	if capability.Has(p.opts.Version, capability.PierPluginSharedObject) {
+		if p.opts.AppchainType == types.ChainTypeEther {
 			pluginFile = "eth-client.so"
-		} else if p.appchainType == types.ChainTypeFabric {
+		} else if p.opts.AppchainType == types.ChainTypeFabric {
 			pluginFile = "fabric-client-1.4.so"
 		}
 	}
 
-	data := struct {
-		Mode         string
-		Bitxhub      string
-		Validators   string
-		Peers        string
-		Connectors   string
-		Providers    string
-		Tls          string
-		HttpPort     string
-		PprofPort    string
-		PluginFile   string
-		PluginConfig string
-		ApiPort      string
-		Method       string
-	}{p.mode, p.bitxhub, validators, peers, connectors, p.providers, p.tls, p.httpPort, p.pprofPort, pluginFile, pluginConfig, p.apiPort, p.method}
-
+	peers := p.opts.Peers
+	if p.opts.Mode == types.PierModeDirect && p.id != "" {
+		peers = append([]string{fmt.Sprintf("/ip4/127.0.0.1/tcp/%d/p2p/%s", p.opts.DirectPort, p.id)}, peers...)
+	}
+	data := &pierConfigData{
+		Mode:         p.opts.Mode,
+		Bitxhub:      tomlStrings(p.opts.BitXHubAddrs),
+		Validators:   tomlStrings(p.opts.Validators),
+		Peers:        tomlStrings(peers),
+		Connectors:   tomlStrings(p.opts.Connectors),
+		Providers:    p.opts.Providers,
+		Tls:          p.opts.TLS,
+		HttpPort:     p.opts.HTTPPort,
+		PprofPort:    p.opts.PprofPort,
+		PluginFile:   pluginFile,
+		PluginConfig: p.opts.AppchainType,
+		ApiPort:      p.opts.APIPort,
+		Method:       p.opts.Method,
+	}
 	files := []string{
 		filepath.Join("pier", "api"),
-		filepath.Join("pier", "pier.toml"),
-	}
-	if p.version >= "v1.8.0" {
-		files = append(files, filepath.Join("pier", "admin.json"))
+		filepath.Join("pier", repo.PierConfigName),
 	}
-	if err := renderConfigFile(p.target, files, data); err != nil {
+	if err := renderConfigFile(p.opts.Target, files, data); err != nil {
 		return fmt.Errorf("initialize Pier configuration file: %w", err)
 	}
 
-	dstDir := filepath.Join(p.target, p.appchainType)
-
-	srcDir := filepath.Join("pier", p.appchainType)
-
-	files2 := []string{
-		p.appchainType + ".toml",
+	certsDir := filepath.Join(p.opts.Target, types.TlsCerts)
+	if err := os.MkdirAll(certsDir, 0755); err != nil {
+		return err
 	}
-	var data2 struct {
-		AppchainAddr         string
-		AppchainContractAddr string
-		ConfigPath           string
-		AppchainIP           string
-		Port1                string
-		Port2                string
-		Port3                string
-		Port4                string
-		Port5                string
-		Port6                string
-		Port7                string
-		Port8                string
-		Port9                string
+	certs := []string{
+		filepath.Join("pier", types.TlsCerts, repo.AgencyName+".cert"),
+		filepath.Join("pier", types.TlsCerts, "ca.cert"),
+	}
+	if err := renderConfigFile(certsDir, certs, nil); err != nil {
+		return fmt.Errorf("initialize Pier tls configuration files: %w", err)
 	}
 
-	if p.appchainType == types.ChainTypeFabric {
-		files2 = append(files2, types.FabricConfig)
+	pluginDir := filepath.Join(p.opts.Target, "plugins")
+	if err := os.MkdirAll(pluginDir, 0755); err != nil {
+		return err
+	}
+	pluginPath := filepath.Join(pluginDir, pluginFile)
+	if err := copyFile(pluginPath, p.opts.PluginPath); err != nil {
+		return fmt.Errorf("copy appchain plugin: %w", err)
+	}
+	if err := os.Chmod(pluginPath, 0755); err != nil {
+		return err
+	}
 
-		if len(p.appPorts) != 9 {
-			return fmt.Errorf("the number of appPorts is not 9: %d", len(p.appPorts))
+	configDir := filepath.Join(p.opts.Target, p.opts.AppchainType)
+	if appchain.configPaths != nil {
+		for _, path := range appchain.configPaths(p.opts) {
+			if err := renderDir(configDir, path, nil, nil); err != nil {
+				return fmt.Errorf("copy %s config: %w", p.opts.AppchainType, err)
+			}
 		}
-		data2 = struct {
-			AppchainAddr         string
-			AppchainContractAddr string
-			ConfigPath           string
-			AppchainIP           string
-			Port1                string
-			Port2                string
-			Port3                string
-			Port4                string
-			Port5                string
-			Port6                string
-			Port7                string
-			Port8                string
-			Port9                string
-		}{p.appchainAddr,
-			p.appchainContractAddr,
-			p.cryptoPath,
-			p.appchainIP,
-			p.appPorts[0],
-			p.appPorts[1],
-			p.appPorts[2],
-			p.appPorts[3],
-			p.appPorts[4],
-			p.appPorts[5],
-			p.appPorts[6],
-			p.appPorts[7],
-			p.appPorts[8]}
-	} else if p.appchainType == types.ChainTypeEther {
-		if len(p.appPorts) != 1 {
-			return fmt.Errorf("the number of appPorts is not 1: %d", len(p.appPorts))
+	}
+	appchainData := &appchainConfigData{}
+	if appchain.configData != nil {
+		if appchainData, err = appchain.configData(p.opts); err != nil {
+			return err
 		}
-		data2 = struct {
-			AppchainAddr         string
-			AppchainContractAddr string
-			ConfigPath           string
-			AppchainIP           string
-			Port1                string
-			Port2                string
-			Port3                string
-			Port4                string
-			Port5                string
-			Port6                string
-			Port7                string
-			Port8                string
-			Port9                string
-		}{p.appchainAddr,
-			p.appchainContractAddr,
-			p.cryptoPath,
-			p.appchainIP,
-			p.appPorts[0],
-			"",
-			"",
-			"",
-			"",
-			"",
-			"",
-			"",
-			""}
-	}
-
-	if err := renderConfigFiles(dstDir, srcDir, files2, data2); err != nil {
-		return fmt.Errorf("initialize Pier plugin configuration files: %w", err)
 	}
-
-	if err := renderConfigFiles(filepath.Join(p.target, types.TlsCerts), filepath.Join("pier", types.TlsCerts), nil, nil); err != nil {
-		return fmt.Errorf("initialize Pier tls configuration files: %w", err)
+	if err := renderDir(configDir, p.opts.AppchainConfigPath, appchain.Templates, appchainData); err != nil {
+		return fmt.Errorf("initialize Pier plugin configuration files: %w", err)
 	}
 
 	return nil
 }
 
 func (p *PierConfigGenerator) CleanOldConfig() error {
-	if err := os.Remove(filepath.Join(p.target, repo.PierConfigName)); err != nil {
-		return fmt.Errorf("remove Pier's old configuration file: %w", err)
+	infos, err := ioutil.ReadDir(p.opts.Target)
+	if err != nil {
+		return fmt.Errorf("read Pier's old configuration: %w", err)
 	}
 
-	if _, err := removeDir(filepath.Join(p.target, p.appchainType)); err != nil {
-		return fmt.Errorf("remove Pier's old plugin configuration: %w", err)
+	for _, info := range infos {
+		if err := os.RemoveAll(filepath.Join(p.opts.Target, info.Name())); err != nil {
+			return fmt.Errorf("remove Pier's old configuration: %w", err)
+		}
 	}
 
 	return nil
@@ -537,48 +500,46 @@ func (p *PierConfigGenerator) InitConfig() error {
 		return err
 	}
 
-	fmt.Printf("initializing Pier configuration at %s\n", p.target)
+	fmt.Printf("initializing Pier configuration at %s\n", p.opts.Target)
 
 	if ok, err := p.Initialized(); err != nil {
 		return fmt.Errorf("check if Pier is initialized: %w", err)
 	} else if ok {
 		fmt.Println("Pier configuration file already exists")
-		fmt.Println("reinitializing would overwrite your configuration, Y/N?")
+		if p.opts.Rewrite {
+			color.Blue("The rewrite of the config is true, so the configuration files will be overridden.\n")
+		} else {
+			fmt.Println("reinitializing would overwrite your configuration, Y/N?")
 
-		inputReader := bufio.NewReader(os.Stdin)
-		input, err := inputReader.ReadString('\n')
-		if err != nil {
-			return fmt.Errorf("read response: %w", err)
-		}
+			inputReader := bufio.NewReader(os.Stdin)
+			input, err := inputReader.ReadString('\n')
+			if err != nil {
+				return fmt.Errorf("read response: %w", err)
+			}
 
-		if input[0] != 'Y' && input[0] != 'y' {
-			color.Blue("[N] The selection is 'No', so it will work with the existing configuration files.\n")
-			return nil
-		}
+			if input[0] != 'Y' && input[0] != 'y' {
+				color.Blue("[N] The selection is 'No', so it will work with the existing configuration files.\n")
+				return nil
+			}
 
-		color.Blue("[Y] The selection is 'Yes', so the configuration files will be overridden.\n")
+			color.Blue("[Y] The selection is 'Yes', so the configuration files will be overridden.\n")
+		}
 
 		if err := p.CleanOldConfig(); err != nil {
 			return fmt.Errorf("clean old configuration: %w", err)
 		}
 	}
 
-	if _, err := os.Stat(p.target); os.IsNotExist(err) {
-		if err := os.MkdirAll(p.target, 0755); err != nil {
-			return err
-		}
+	if err := os.MkdirAll(p.opts.Target, 0755); err != nil {
+		return err
 	}
 
-	// If pierPath equals "", that means this is a call during remote deployment.
+	// If BinPath equals "", that means this is a call during remote deployment.
 	// Since the PIER binaries used for remote deployment may not be able to run
 	// locally, the step of generatePierKeyAndID is skipped here and it will be
-	// done remotely through the SSH command in deploy.go.
-	if p.pierPath != "" {
-		keys := []string{repo.KeyName}
-		if p.version >= "v1.4.0" {
-			keys = append(keys, repo.NodeKeyName)
-		}
-		id, err := generatePierKeyAndID(p.target, p.pierPath, keys)
+	// done remotely by deploy_pier.sh.
+	if p.opts.BinPath != "" {
+		id, err := p.generatePierKeyAndID()
 		if err != nil {
 			return fmt.Errorf("generate Pier's private key and id: %w", err)
 		}
@@ -591,71 +552,104 @@ func (p *PierConfigGenerator) InitConfig() error {
 		return err
 	}
 
-	fmt.Printf("Pier configuration at %s are initialized successfully\n", p.target)
+	fmt.Printf("Pier configuration at %s are initialized successfully\n", p.opts.Target)
 
 	return nil
 }
 
 func (p *PierConfigGenerator) ProcessParams() error {
-	if p.mode != types.PierModeDirect && p.mode != types.PierModeRelay {
-		if p.version < "v1.4.0" {
+	o := p.opts
+	if o.Mode != types.PierModeDirect && o.Mode != types.PierModeRelay {
+		if !capability.Has(o.Version, capability.PierUnionMode) {
 			return fmt.Errorf("invalid mode, choose one of direct or relay")
 		} else {
-			if p.mode != types.PierModeUnion {
+			if o.Mode != types.PierModeUnion {
 				return fmt.Errorf("invalid mode, choose one of direct, relay or union")
 			}
 		}
 
 	}
 
-	if p.mode == types.PierModeRelay && p.bitxhub == "" {
+	if o.Mode == types.PierModeRelay && len(o.BitXHubAddrs) == 0 {
 		return fmt.Errorf("BitXhub's address is needed in relay mode")
 	}
 
-	if p.mode == types.PierModeRelay && len(p.validators) == 0 {
+	if o.Mode == types.PierModeRelay && len(o.Validators) == 0 {
 		return fmt.Errorf("BitXhub validators' information is needed in relay mode")
 	}
 
-	if p.mode == types.PierModeDirect && len(p.peers) == 0 {
+	if o.Mode == types.PierModeDirect && len(o.Peers) == 0 {
 		fmt.Println("You have to add peers' information manually after the configuration files are generated")
 	}
 
-	if p.mode == types.PierModeUnion && len(p.connectors) == 0 {
+	if o.Mode == types.PierModeUnion && len(o.Connectors) == 0 {
 		fmt.Println("You have to add connectors' information manually after the configuration files are generated")
 	}
 
-	if p.appchainType != types.ChainTypeEther && p.appchainType != types.ChainTypeFabric {
-		return fmt.Errorf("invalid appchain type(%s), choose one of ethereum or fabric", p.appchainType)
+	appchain, err := GetPierAppchain(o.AppchainType)
+	if err != nil {
+		return err
+	}
+
+	if !fileutil.Exist(o.PluginPath) {
+		return fmt.Errorf("the appchain plugin %s does not exist", o.PluginPath)
 	}
 
-	if err := checkIPs([]string{p.appchainIP}); err != nil {
+	if ok, err := existDir(o.AppchainConfigPath); err != nil {
 		return err
+	} else if !ok {
+		return fmt.Errorf("the appchain config %s is not a directory", o.AppchainConfigPath)
+	}
+
+	// the validators are registered by the path in the plugin config
+	validators := filepath.Base(appchain.Register.Validators)
+	if !fileutil.Exist(filepath.Join(o.AppchainConfigPath, validators)) {
+		return fmt.Errorf("%s is needed in the appchain config %s", validators, o.AppchainConfigPath)
+	}
+
+	if appchain.validate != nil {
+		if err := appchain.validate(o); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func InitBitXHubConfig(typ, mode, target string, num int, ips []string, tls bool, version string) error {
-	bcg := NewBitXHubConfigGenerator(typ, mode, target, num, ips, tls, version)
+func InitBitXHubConfig(repoRoot, typ, mode, target string, num int, ips []string, tls bool, version string, spec *GenesisSpec, ca *BitXHubCA) error {
+	bcg := NewBitXHubConfigGenerator(repoRoot, typ, mode, target, num, ips, tls, version, spec, ca)
 	return bcg.InitConfig()
 }
 
-func InitPierConfig(mode, startType, bitxhub string, validators []string, port string, peers, connectors []string, providers, appchainType, appchainIP, appchainAddr string, appPorts []string, appchainContractAddr, target, tls, httpPort, pprofPort, apiPort, version, pierPath, cryptoPath, method string) error {
-	pcg := NewPierConfigGenerator(mode, startType, bitxhub, validators, port, peers, connectors, providers, appchainType, appchainIP, appchainAddr, appPorts, appchainContractAddr, target, tls, httpPort, pprofPort, apiPort, version, pierPath, cryptoPath, method)
+func InitPierConfig(opts *PierConfigOptions) error {
+	pcg := NewPierConfigGenerator(opts)
 	return pcg.InitConfig()
 }
 
+// allocatePorts allocates the ports of the nodes in the target, the ports
+// allocated before are kept
+func (b *BitXHubConfigGenerator) allocatePorts() error {
+	alloc, err := ports.Load(b.repoRoot)
+	if err != nil {
+		return err
+	}
+
+	env, err := ports.NewAllocator(nil).Allocate(alloc, b.target, b.num, 0)
+	if err != nil {
+		return err
+	}
+	b.ports = env.Nodes
+
+	return alloc.Save()
+}
+
 func (b *BitXHubConfigGenerator) generateNodesConfig(repoRoot, mode, agencyPrivKey, agencyCertPath string, ips []string) ([]string, []*NetworkNodes, error) {
 	count := len(ips)
-	ipToId := make(map[string]int)
 	addrs := make([]string, 0, count)
 	nodes := make([]*NetworkNodes, 0, count)
 
 	for i := 1; i <= count; i++ {
-		ip := ips[i-1]
-		ipToId[ip]++
-
-		addr, node, err := b.generateNodeConfig(repoRoot, mode, agencyPrivKey, agencyCertPath, ip, i, ipToId)
+		addr, node, err := b.generateNodeConfig(repoRoot, mode, agencyPrivKey, agencyCertPath, ips[i-1], i, b.ports[i-1])
 		if err != nil {
 			return nil, nil, err
 		}
@@ -667,7 +661,7 @@ func (b *BitXHubConfigGenerator) generateNodesConfig(repoRoot, mode, agencyPrivK
 	return addrs, nodes, nil
 }
 
-func (b *BitXHubConfigGenerator) generateNodeConfig(repoRoot, mode, agencyPrivKey, agencyCertPath, ip string, id int, ipToId map[string]int) (string, *NetworkNodes, error) {
+func (b *BitXHubConfigGenerator) generateNodeConfig(repoRoot, mode, agencyPrivKey, agencyCertPath, ip string, id int, nodePorts ports.Ports) (string, *NetworkNodes, error) {
 	name := "node"
 	addrKeyName := name
 	org := "Node" + strconv.Itoa(id)
@@ -699,11 +693,9 @@ func (b *BitXHubConfigGenerator) generateNodeConfig(repoRoot, mode, agencyPrivKe
 	}
 
 	// generate key.pri ===============================================
-	cryptoOpt := crypto.Secp256k1
-	if b.version < "v1.4.0" {
-		cryptoOpt = crypto.ECDSA_P521
-	}
-	if b.version >= "v1.4.0" {
+	cryptoOpt := crypto.ECDSA_P521
+	if capability.Has(b.version, capability.Secp256k1NodeKey) {
+		cryptoOpt = crypto.Secp256k1
 		if err := generatePrivKey(repo.KeyPriv, certRoot, crypto.Secp256k1); err != nil {
 			return "", nil, fmt.Errorf("generate priv key: %w", err)
 		}
@@ -733,7 +725,7 @@ func (b *BitXHubConfigGenerator) generateNodeConfig(repoRoot, mode, agencyPrivKe
 	}
 
 	// generate bitxhub.toml, order.toml, api... ======================
-	if err := b.copyConfigFiles(nodeRoot, ipToId[ip]); err != nil {
+	if err := b.copyConfigFiles(nodeRoot, id, nodePorts); err != nil {
 		return "", nil, fmt.Errorf("initialize configuration for node %d: %w", id, err)
 	}
 
@@ -754,43 +746,33 @@ func (b *BitXHubConfigGenerator) generateNodeConfig(repoRoot, mode, agencyPrivKe
 	node := &NetworkNodes{
 		ID:      uint64(id),
 		Pid:     pid,
-		Hosts:   []string{fmt.Sprintf("/ip4/%s/tcp/%d/p2p/", ip, 4000+ipToId[ip])},
+		Hosts:   []string{fmt.Sprintf("/ip4/%s/tcp/%d/p2p/", ip, nodePorts[ports.P2P])},
 		Account: addr,
 	}
 
 	return addr, node, nil
 }
 
-func (b *BitXHubConfigGenerator) copyConfigFiles(nodeRoot string, id int) error {
-	consensus := types.SoloMode
-	if b.mode == "cluster" {
-		consensus = "raft"
-	}
+func (b *BitXHubConfigGenerator) copyConfigFiles(nodeRoot string, id int, nodePorts ports.Ports) error {
+	consensus := b.spec.ConsensusType(b.mode)
 
 	data := struct {
 		Id        int
 		Solo      bool
 		Consensus string
 		Tls       bool
-	}{id, b.mode == "solo", consensus, b.tls}
+		Ports     ports.Ports
+	}{id, b.mode == "solo", consensus, b.tls, nodePorts}
 
 	files := []string{"bitxhub.toml", "api"}
 
-	srcPath := "bitxhub"
-
-	if b.version < "v1.4.0" {
-		srcPath = fmt.Sprintf("%s/v1.1.0", srcPath)
-	} else if b.version < "v1.6.0" {
-		srcPath = fmt.Sprintf("%s/v1.4.0", srcPath)
-	} else {
-		srcPath = fmt.Sprintf("%s/v1.6.0", srcPath)
-	}
+	srcPath := fmt.Sprintf("bitxhub/%s", capability.BitXHubTemplate(b.version))
 
 	return renderConfigFiles(nodeRoot, srcPath, files, data)
 }
 
 // write network and genesis info for BitXHub
-func writeNetworkAndGenesis(repoRoot, mode string, addrs []string, nodes []*NetworkNodes, version string) error {
+func writeNetworkAndGenesis(repoRoot, mode string, addrs []string, nodes []*NetworkNodes, version string, spec *GenesisSpec) error {
 	genesis := Genesis1_1_0{Addresses: addrs}
 	content, err := json.MarshalIndent(genesis, "", " ")
 	if err != nil {
@@ -805,7 +787,7 @@ func writeNetworkAndGenesis(repoRoot, mode string, addrs []string, nodes []*Netw
 			nodeRoot = filepath.Join(repoRoot, "nodeSolo")
 		}
 
-		if version == "v1.1.0-rc1" {
+		if capability.Has(version, capability.NetworkAddrList) {
 			var addrs [][]string
 			for _, node := range nodes {
 				addrs = append(addrs, []string{fmt.Sprintf("%s%s", node.Hosts[0], node.Pid)})
@@ -825,7 +807,7 @@ func writeNetworkAndGenesis(repoRoot, mode string, addrs []string, nodes []*Netw
 			}
 
 			continue
-		} else if version < "v1.1.0-rc1" {
+		} else if !capability.Has(version, capability.NetworkNodeList) {
 			nodes1_0_0 := make([]*NetworkNodes1_0_0, 0, len(nodes))
 
 			for _, n := range nodes {
@@ -858,7 +840,7 @@ func writeNetworkAndGenesis(repoRoot, mode string, addrs []string, nodes []*Netw
 			continue
 		}
 
-		// network.toml >= v1.4.0
+		// network.toml with the node list
 		netConfig := NetworkConfig{
 			ID:    uint64(i),
 			N:     uint64(count),
@@ -876,7 +858,7 @@ func writeNetworkAndGenesis(repoRoot, mode string, addrs []string, nodes []*Netw
 		}
 
 		// bitxhub.toml
-		if version < "v1.6.0" { // v1.4.0 v1.5.0
+		if !capability.Has(version, capability.GenesisAdmins) { // v1.4.0 v1.5.0
 			genesis1 := &Genesis1_1_0{}
 			for _, addr := range addrs {
 				genesis1.Addresses = append(genesis1.Addresses, addr)
@@ -885,22 +867,8 @@ func writeNetworkAndGenesis(repoRoot, mode string, addrs []string, nodes []*Netw
 			if err = ModifyConfig(nodeRoot, "genesis", genesis1); err != nil {
 				return fmt.Errorf("modify bitxhub.toml error: %w", err)
 			}
-		} else { // >= v1.6.0
-			genesis2 := &Genesis{
-				Strategy: map[string]string{
-					"AppchainMgr": "SimpleMajority",
-					"RuleMgr":     "SimpleMajority",
-					"NodeMgr":     "SimpleMajority",
-					"ServiceMgr":  "SimpleMajority"},
-			}
-			for _, addr := range addrs {
-				genesis2.Admins = append(genesis2.Admins, &Admin{
-					Address: addr,
-					Weight:  uint64(1),
-				})
-			}
-
-			if err = ModifyConfig(nodeRoot, "genesis", genesis2); err != nil {
+		} else {
+			if err = ModifyConfig(nodeRoot, "genesis", spec.Genesis(addrs)); err != nil {
 				return fmt.Errorf("modify bitxhub.toml error: %w", err)
 			}
 		}
@@ -936,7 +904,7 @@ func copyFile(dstFile, srcFile string) error {
 	}
 	defer src.Close()
 
-	dst, err := os.OpenFile(dstFile, os.O_WRONLY|os.O_CREATE, 0644)
+	dst, err := os.OpenFile(dstFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
 	}
@@ -986,40 +954,6 @@ func existDir(path string) (bool, error) {
 	return s.IsDir(), nil
 }
 
-func generatePierKeyAndID(target, pierPath string, keys []string) (string, error) {
-	// pier init key
-	// version >= v1.4.0 : key.json, node.priv
-	// version < v1.4.0- : key.json
-	tmpPath := fmt.Sprintf("%s_%d", types.TmpPath, time.Now().Unix())
-	libPath := filepath.Dir(pierPath)
-	err := sh.Command("/bin/bash", "-c", fmt.Sprintf("mkdir %s/%s && export LD_LIBRARY_PATH=$LD_LIBRARY_PATH:%s && %s --repo %s/%s init", target, tmpPath, libPath, pierPath, target, tmpPath)).Run()
-	if err != nil {
-		return "", fmt.Errorf("pier init key: %s", err)
-	}
-	for _, k := range keys {
-		err := sh.Command("/bin/bash", "-c", fmt.Sprintf("cp %s/%s/%s %s/%s", target, tmpPath, k, target, k)).Run()
-		if err != nil {
-			return "", fmt.Errorf("copy pier %s: %s", k, err)
-		}
-	}
-
-	// pier p2p id
-	keyPath := fmt.Sprintf("%s/%s", target, tmpPath)
-	out, err := sh.Command("/bin/bash", "-c", fmt.Sprintf("export LD_LIBRARY_PATH=$LD_LIBRARY_PATH:%s && %s --repo %s p2p id", libPath, pierPath, keyPath)).Output()
-	if err != nil {
-		return "", fmt.Errorf("get pier id: %s", err)
-	}
-	pid := strings.Replace(string(out), "\n", "", -1)
-
-	// delete tmp directory
-	err = sh.Command("/bin/bash", "-c", fmt.Sprintf("rm -r %s/%s", target, tmpPath)).Run()
-	if err != nil {
-		return "", fmt.Errorf("delete tmp directory: %s", err)
-	}
-
-	return pid, nil
-}
-
 // generate ca for bitxhub, return path of ca.priv and ca.cert
 func generateCA(dir string) (string, string, error) {
 	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
@@ -1272,8 +1206,10 @@ func renderConfigFiles(dstDir, srcDir string, filesToRender []string, data inter
 	return nil
 }
 
+// renderConfigFile renders the templates in the config to dstDir, the box is
+// the one goduck init copies the config by
 func renderConfigFile(dstDir string, srcFiles []string, data interface{}) error {
-	box := packr.New("box", PackPath)
+	box := packr.New("configBox", PackPath)
 
 	for _, srcFile := range srcFiles {
 		fileStr, err := box.FindString(srcFile)
@@ -1292,7 +1228,9 @@ func renderConfigFile(dstDir string, srcFiles []string, data interface{}) error
 			return err
 		}
 
-		if err := t.Execute(f, data); err != nil {
+		err = t.Execute(f, data)
+		f.Close()
+		if err != nil {
 			return err
 		}
 	}
@@ -1300,6 +1238,53 @@ func renderConfigFile(dstDir string, srcFiles []string, data interface{}) error
 	return nil
 }
 
+// renderDir copies the files in srcDir to dstDir, the files to render are
+// rendered as templates with the data
+func renderDir(dstDir, srcDir string, filesToRender []string, data interface{}) error {
+	filesM := make(map[string]struct{})
+	for _, file := range filesToRender {
+		filesM[file] = struct{}{}
+	}
+
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		p := filepath.Join(dstDir, rel)
+		if info.IsDir() {
+			return os.MkdirAll(p, 0755)
+		}
+
+		if _, ok := filesM[info.Name()]; ok {
+			return renderFile(p, path, data)
+		}
+		return copyFile(p, path)
+	})
+}
+
+func renderFile(dstFile, srcFile string, data interface{}) error {
+	fileStr, err := ioutil.ReadFile(srcFile)
+	if err != nil {
+		return err
+	}
+
+	t, err := template.New(filepath.Base(srcFile)).Parse(string(fileStr))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(dstFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return t.Execute(f, data)
+}
 func ModifyConfig(repoRoot string, modifyKey string, modifyValue interface{}) error {
 	data, _ := json.Marshal(&modifyValue)
 	m := make(map[string]interface{})
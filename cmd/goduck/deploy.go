@@ -1,11 +1,8 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"path/filepath"
-	"runtime"
 
 	"github.com/meshplus/bitxhub-kit/fileutil"
 	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
@@ -39,6 +36,11 @@ func deployCMD() *cli.Command {
 						Value:   "v1.11.0",
 						Usage:   "BitXHub version",
 					},
+					&cli.StringFlag{
+						Name:  "arch",
+						Value: types.AMD64Arch,
+						Usage: "Specify the architecture of the remote server, e.g. amd64 or arm64",
+					},
 				},
 				Action: deployBitXHub,
 			},
@@ -65,6 +67,11 @@ func deployCMD() *cli.Command {
 						Value:   "v1.11.0",
 						Usage:   "BitXHub version",
 					},
+					&cli.StringFlag{
+						Name:  "arch",
+						Value: types.AMD64Arch,
+						Usage: "Specify the architecture of the remote server, e.g. amd64 or arm64",
+					},
 				},
 				Action: deployPier,
 			},
@@ -76,6 +83,7 @@ func deployBitXHub(ctx *cli.Context) error {
 	target := ctx.String("target")
 	configPath := ctx.String("configPath")
 	version := ctx.String("version")
+	arch := ctx.String("arch")
 
 	// 1. check goduck init
 	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
@@ -86,24 +94,19 @@ func deployBitXHub(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	// 2. check versiojn
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, "release.json"))
+	// 2. check versiojn and platform
+	r, err := bxhRelease(repoRoot, version)
 	if err != nil {
 		return err
 	}
-
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
+	artifact, err := r.Artifact(types.LinuxSystem, arch)
+	if err != nil {
 		return err
 	}
 
-	if !AdjustVersion(version, release.Bitxhub) {
-		return fmt.Errorf("unsupport BitXHub verison")
-	}
-
 	// 3. get args and bin
 	if configPath == "" {
-		configPath = filepath.Join(repoRoot, fmt.Sprintf("bxh_config/%s/%s", bxhConfigMap[version], types.BxhModifyConfig))
+		configPath = filepath.Join(repoRoot, fmt.Sprintf("bxh_config/%s/%s", r.Config, types.BxhModifyConfig))
 	} else {
 		configPath, err = filepath.Abs(configPath)
 		if err != nil {
@@ -111,21 +114,28 @@ func deployBitXHub(ctx *cli.Context) error {
 		}
 	}
 
-	err = bitxhub.DownloadBitxhubBinary(repoRoot, version, runtime.GOOS)
-	if err != nil {
-		return fmt.Errorf("download %s binary error:%w", runtime.GOOS, err)
+	if _, err := bitxhubBinary(repoRoot, version); err != nil {
+		return err
 	}
 
 	// the remote system is usually Linux
-	err = bitxhub.DownloadBitxhubBinary(repoRoot, version, types.LinuxSystem)
+	binPath := repo.GetBinPath(repoRoot, "bitxhub", types.LinuxSystem, arch, version)
+	binFile, linked, err := packLinked(repoRoot, "bitxhub", types.LinuxSystem, arch, binPath)
 	if err != nil {
-		return fmt.Errorf("download %s binary error:%w", types.LinuxSystem, err)
+		return err
+	}
+	if !linked {
+		err = bitxhub.DownloadBitxhubBinary(repoRoot, version, types.LinuxSystem, arch)
+		if err != nil {
+			return fmt.Errorf("download %s/%s binary error:%w", types.LinuxSystem, arch, err)
+		}
+		binFile = artifact.Name
 	}
 
 	// 4. execute
 	args := make([]string, 0)
 	args = append(args, filepath.Join(repoRoot, types.DeployBxhScript), "up")
-	args = append(args, version, configPath, target)
+	args = append(args, version, configPath, target, binPath, binFile)
 	return utils.ExecuteShell(args, repoRoot)
 }
 
@@ -134,6 +144,7 @@ func deployPier(ctx *cli.Context) error {
 	target := ctx.String("target")
 	configPath := ctx.String("configPath")
 	version := ctx.String("version")
+	arch := ctx.String("arch")
 
 	// 1. check goduck init
 	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
@@ -144,24 +155,22 @@ func deployPier(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	// 2. check versiojn
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, "release.json"))
+	// 2. check versiojn and platform
+	r, err := pierRelease(repoRoot, version)
 	if err != nil {
 		return err
 	}
-
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
+	artifact, err := r.Artifact(types.LinuxSystem, arch)
+	if err != nil {
 		return err
 	}
-
-	if !AdjustVersion(version, release.Pier) {
-		return fmt.Errorf("unsupport Pier verison")
+	if _, err := r.Plugin(appchain, types.LinuxSystem, arch); err != nil {
+		return err
 	}
 
 	// 3. get args and bin
 	if configPath == "" {
-		configPath = filepath.Join(repoRoot, fmt.Sprintf("pier_config/%s/%s", pierConfigMap[version], types.PierModifyConfig))
+		configPath = filepath.Join(repoRoot, fmt.Sprintf("pier_config/%s/%s", r.Config, types.PierModifyConfig))
 	} else {
 		configPath, err = filepath.Abs(configPath)
 		if err != nil {
@@ -169,25 +178,28 @@ func deployPier(ctx *cli.Context) error {
 		}
 	}
 
-	err = pier.DownloadPierBinary(repoRoot, version, runtime.GOOS)
-	if err != nil {
-		return fmt.Errorf("download %s binary error:%w", runtime.GOOS, err)
-	}
-
 	// the remote system is usually Linux
-	err = pier.DownloadPierBinary(repoRoot, version, types.LinuxSystem)
+	binPath := repo.GetBinPath(repoRoot, "pier", types.LinuxSystem, arch, version)
+	binFile, linked, err := packLinked(repoRoot, "pier", types.LinuxSystem, arch, binPath)
 	if err != nil {
-		return fmt.Errorf("download %s binary error:%w", types.LinuxSystem, err)
+		return err
+	}
+	if !linked {
+		err = pier.DownloadPierBinary(repoRoot, version, types.LinuxSystem, arch)
+		if err != nil {
+			return fmt.Errorf("download %s/%s binary error:%w", types.LinuxSystem, arch, err)
+		}
+		binFile = artifact.Name
 	}
 
-	if err := pier.DownloadPierPlugin(repoRoot, appchain, version, types.LinuxSystem); err != nil {
+	if err := pier.DownloadPierPlugin(repoRoot, appchain, version, types.LinuxSystem, arch); err != nil {
 		return fmt.Errorf("download pier binary error:%w", err)
 	}
 
 	// 4. execute
 	args := make([]string, 0)
 	args = append(args, filepath.Join(repoRoot, types.DeployPierScript), "up")
-	args = append(args, appchain, version, configPath, target)
+	args = append(args, appchain, version, configPath, target, binPath, binFile)
 	return utils.ExecuteShell(args, repoRoot)
 }
 
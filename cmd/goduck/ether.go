@@ -12,20 +12,6 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
-var EthConfigMap = map[string]string{
-	"v1.6.1":  "1.2.0",
-	"v1.6.5":  "1.2.0",
-	"v1.7.0":  "1.2.0",
-	"v1.8.0":  "1.2.0",
-	"v1.9.0":  "1.2.0",
-	"v1.10.0": "1.2.0",
-	"v1.11.0": "1.3.0",
-	"v1.11.1": "1.3.0",
-	"v1.11.3": "1.3.0",
-	"v1.23.0": "1.2.0",
-	"v2.8.0":  "1.2.0",
-}
-
 func etherCMD() *cli.Command {
 	return &cli.Command{
 		Name:  "ether",
@@ -93,7 +79,10 @@ func startEther(ctx *cli.Context) error {
 	httpport := ctx.String("httpport")
 	wsport := ctx.String("wsport")
 	port := ctx.String("port")
-	version := EthConfigMap[ctx.String("bxh-version")]
+	version, err := ethContractVersion(repoRoot, ctx.String("bxh-version"))
+	if err != nil {
+		return err
+	}
 
 	if typ == types.TypeBinary {
 		err := ethereum.DownloadGethBinary(repoRoot)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/meshplus/bitxhub-kit/fileutil"
@@ -158,11 +159,20 @@ func downloadContract(ctx *cli.Context) error {
 		ethContract = append(ethContract, "broker_data")
 	}
 
+	// the contracts of all versions share the file names, so they are listed
+	// by the appchain and the version in the checksums
+	checksums, err := download.LoadManifest(filepath.Join(repoPath, types.ChecksumManifest))
+	if err != nil {
+		return err
+	}
+
 	for _, contract := range ethContract {
-		path := filepath.Join(repoPath, types.ChainTypeEther, "contract", version, fmt.Sprintf("%s.sol", contract))
+		name := fmt.Sprintf("%s.sol", contract)
+		path := filepath.Join(repoPath, types.ChainTypeEther, "contract", version, name)
 		if !fileutil.Exist(path) {
 			url := fmt.Sprintf(types.EthereumContractUrl, version, contract)
-			err := download.Download(path, url)
+			sum := checksums.Checksum(strings.Join([]string{types.ChainTypeEther, version, name}, "/"))
+			err := download.Download(path, url, download.WithSHA256(sum))
 			if err != nil {
 				return err
 			}
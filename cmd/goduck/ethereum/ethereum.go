@@ -14,6 +14,7 @@ import (
 	"github.com/codeskyblue/go-sh"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/fatih/color"
 	"github.com/meshplus/bitxhub-kit/fileutil"
 	"github.com/meshplus/goduck/internal/download"
 	"github.com/meshplus/goduck/internal/types"
@@ -83,8 +84,28 @@ func New(config Config, repoRoot string) (*Ethereum, error) {
 	}, nil
 }
 
+// gethPlatforms are the platforms geth is released for
+var gethPlatforms = []string{"linux/amd64", "linux/arm64", "darwin/amd64"}
+
 func DownloadGethBinary(repoPath string) error {
-	path := filepath.Join(repoPath, "bin", fmt.Sprintf("geth_%s_1.9.6", runtime.GOOS))
+	arch := runtime.GOARCH
+	if runtime.GOOS == types.DarwinSystem && arch == types.ARM64Arch {
+		// Apple silicon runs the darwin/amd64 release by Rosetta
+		color.Yellow("WARNING: geth %s has no release for darwin/arm64, the darwin/amd64 one is used, which runs by Rosetta", types.GethVersion)
+		arch = types.AMD64Arch
+	}
+	platform := runtime.GOOS + "/" + arch
+	supported := false
+	for _, p := range gethPlatforms {
+		if p == platform {
+			supported = true
+		}
+	}
+	if !supported {
+		return fmt.Errorf("geth %s has no release for %s, one of %s", types.GethVersion, platform, strings.Join(gethPlatforms, ", "))
+	}
+
+	path := filepath.Join(repoPath, "bin", fmt.Sprintf("geth_%s_%s", runtime.GOOS, types.GethVersion))
 	if !fileutil.Exist(path) {
 		err := os.MkdirAll(path, 0755)
 		if err != nil {
@@ -92,13 +113,20 @@ func DownloadGethBinary(repoPath string) error {
 		}
 	}
 
-	if !fileutil.Exist(filepath.Join(path, "geth")) {
-		err := download.Download(path, fmt.Sprintf(types.GethUrl, runtime.GOOS))
+	manifest, err := download.LoadManifest(filepath.Join(repoPath, types.ChecksumManifest))
+	if err != nil {
+		return err
+	}
+
+	tarName := fmt.Sprintf(types.GethTarName, runtime.GOOS, arch)
+	tarPath := filepath.Join(path, tarName)
+	if !fileutil.Exist(filepath.Join(path, "geth")) || !manifest.Complete(tarPath) {
+		err := download.Download(tarPath, fmt.Sprintf(types.GethUrl, runtime.GOOS, arch), download.WithManifest(manifest))
 		if err != nil {
 			return err
 		}
 
-		err = sh.Command("/bin/bash", "-c", fmt.Sprintf("cd %s && tar xf %s --strip-components 1 ", path, fmt.Sprintf(types.GethTarName, runtime.GOOS))).Run()
+		err = sh.Command("/bin/bash", "-c", fmt.Sprintf("cd %s && tar xf %s --strip-components 1 ", path, tarName)).Run()
 		if err != nil {
 			return err
 		}
@@ -3,6 +3,7 @@ package fabric
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/meshplus/bitxhub-kit/fileutil"
 	"github.com/meshplus/goduck/internal/download"
@@ -239,8 +240,15 @@ func downloadContract(ctx *cli.Context) error {
 
 	path := filepath.Join(repoRoot, types.ChainTypeFabric, "contract", version, "contracts.zip")
 	if !fileutil.Exist(path) {
+		// the contracts of all versions share the file name, so they are
+		// listed by the appchain and the version in the checksums
+		checksums, err := download.LoadManifest(filepath.Join(repoRoot, types.ChecksumManifest))
+		if err != nil {
+			return err
+		}
 		url := fmt.Sprintf(types.FabricContractUrl, version)
-		err := download.Download(path, url)
+		sum := checksums.Checksum(strings.Join([]string{types.ChainTypeFabric, version, "contracts.zip"}, "/"))
+		err = download.Download(path, url, download.WithSHA256(sum))
 		if err != nil {
 			return err
 		}
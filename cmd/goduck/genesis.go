@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/meshplus/goduck/internal/capability"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/spf13/viper"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// governanceMgrs are the managers whose proposals are voted by the admins
+	governanceMgrs = []string{"AppchainMgr", "RuleMgr", "NodeMgr", "ServiceMgr"}
+	// strategies are the proposal strategies of the managers
+	strategies = []string{repo.SimpleMajority, repo.ZeroPermission}
+)
+
+// GenesisSpec selects the consensus and the governance of BitXHub nodes, e.g.
+//
+//	consensus: rbft
+//	strategy:
+//	  NodeMgr: ZeroPermission
+//	node_weights: [2, 1, 1, 1]
+//	admins:
+//	  - address: "0x..."
+//	    weight: 1
+type GenesisSpec struct {
+	// Consensus is raft or rbft for cluster mode, solo mode always uses solo
+	Consensus string `yaml:"consensus"`
+	// Strategy is the proposal strategy of each manager, SimpleMajority is
+	// used for the missing ones
+	Strategy map[string]string `yaml:"strategy"`
+	// NodeWeights are the weights of the node admins in node order, 1 is used
+	// for the missing ones
+	NodeWeights []uint64 `yaml:"node_weights"`
+	// Admins are the admins besides the nodes
+	Admins []*Admin `yaml:"admins"`
+}
+
+func genesisFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "genesis",
+		Usage: "Specify the genesis spec file which selects the consensus, the proposal strategies and the admins",
+	}
+}
+
+// LoadGenesisSpec reads the spec file, the default spec is returned if path is empty
+func LoadGenesisSpec(path string) (*GenesisSpec, error) {
+	spec := &GenesisSpec{}
+	if path == "" {
+		return spec, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read genesis spec: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(data, spec); err != nil {
+		return nil, fmt.Errorf("unmarshal genesis spec %s: %w", path, err)
+	}
+
+	return spec, nil
+}
+
+// Validate checks whether the spec works for the nodes of the version and mode
+func (s *GenesisSpec) Validate(version, mode string, num int) error {
+	switch s.Consensus {
+	case "":
+	case types.RaftConsensus:
+		if mode == types.SoloMode {
+			return fmt.Errorf("solo mode does not support %s consensus", s.Consensus)
+		}
+	case types.RBFTConsensus:
+		if mode == types.SoloMode {
+			return fmt.Errorf("solo mode does not support %s consensus", s.Consensus)
+		}
+		if !capability.Has(version, capability.RBFTConsensus) {
+			return fmt.Errorf("BitXHub %s does not support %s consensus", version, s.Consensus)
+		}
+	default:
+		return fmt.Errorf("invalid consensus %s, choose one of %s or %s", s.Consensus, types.RaftConsensus, types.RBFTConsensus)
+	}
+
+	if s.customGovernance() && !capability.Has(version, capability.GenesisAdmins) {
+		return fmt.Errorf("BitXHub %s does not support governance strategies and admin weights", version)
+	}
+
+	for mgr, strategy := range s.Strategy {
+		if !containsString(governanceMgrs, mgr) {
+			return fmt.Errorf("invalid manager %s, choose one of %s", mgr, strings.Join(governanceMgrs, ", "))
+		}
+		if !containsString(strategies, strategy) {
+			return fmt.Errorf("invalid strategy %s of %s, choose one of %s", strategy, mgr, strings.Join(strategies, ", "))
+		}
+	}
+
+	if len(s.NodeWeights) > num {
+		return fmt.Errorf("%d node weights are given, but there are %d nodes", len(s.NodeWeights), num)
+	}
+	for i, weight := range s.NodeWeights {
+		if weight == 0 {
+			return fmt.Errorf("weight of node%d should be positive", i+1)
+		}
+	}
+
+	exist := make(map[string]bool)
+	for _, admin := range s.Admins {
+		if !strings.HasPrefix(admin.Address, "0x") || len(admin.Address) != 42 {
+			return fmt.Errorf("invalid admin address %s", admin.Address)
+		}
+		if exist[admin.Address] {
+			return fmt.Errorf("duplicate admin %s", admin.Address)
+		}
+		if admin.Weight == 0 {
+			return fmt.Errorf("weight of admin %s should be positive", admin.Address)
+		}
+		exist[admin.Address] = true
+	}
+
+	return nil
+}
+
+// ConsensusType returns the order type of the nodes in the mode
+func (s *GenesisSpec) ConsensusType(mode string) string {
+	if mode == types.SoloMode {
+		return types.SoloMode
+	}
+	if s.Consensus == "" {
+		return types.RaftConsensus
+	}
+
+	return s.Consensus
+}
+
+// Genesis returns the genesis whose first admins are the nodes
+func (s *GenesisSpec) Genesis(addrs []string) *Genesis {
+	genesis := &Genesis{Strategy: make(map[string]string)}
+	for _, mgr := range governanceMgrs {
+		genesis.Strategy[mgr] = repo.SimpleMajority
+	}
+	for mgr, strategy := range s.Strategy {
+		genesis.Strategy[mgr] = strategy
+	}
+
+	for i, addr := range addrs {
+		genesis.Admins = append(genesis.Admins, &Admin{
+			Address: addr,
+			Weight:  s.nodeWeight(i, 1),
+		})
+	}
+	genesis.Admins = append(genesis.Admins, s.Admins...)
+
+	return genesis
+}
+
+func (s *GenesisSpec) customGovernance() bool {
+	return len(s.Strategy) != 0 || len(s.NodeWeights) != 0 || len(s.Admins) != 0
+}
+
+// nodeWeight returns the weight of the ith node, or def if it is not given
+func (s *GenesisSpec) nodeWeight(i int, def uint64) uint64 {
+	if i < len(s.NodeWeights) {
+		return s.NodeWeights[i]
+	}
+
+	return def
+}
+
+// applyGenesisSpec rewrites the consensus and the genesis of the nodes
+// generated by the config scripts in target. The other genesis fields, e.g.
+// the dider, and the weights of the nodes not given are kept.
+func applyGenesisSpec(target, version, mode string, spec *GenesisSpec) error {
+	nodeRoots, err := filepath.Glob(filepath.Join(target, "node*"))
+	if err != nil {
+		return err
+	}
+	sort.Slice(nodeRoots, func(i, j int) bool {
+		// node10 follows node9
+		if len(nodeRoots[i]) != len(nodeRoots[j]) {
+			return len(nodeRoots[i]) < len(nodeRoots[j])
+		}
+		return nodeRoots[i] < nodeRoots[j]
+	})
+	if err := spec.Validate(version, mode, len(nodeRoots)); err != nil {
+		return err
+	}
+
+	for _, nodeRoot := range nodeRoots {
+		v := viper.New()
+		v.SetConfigFile(filepath.Join(nodeRoot, repo.BitXHubConfigName))
+		v.SetConfigType("toml")
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("read %s: %w", v.ConfigFileUsed(), err)
+		}
+
+		v.Set("order.type", spec.ConsensusType(mode))
+
+		if spec.customGovernance() {
+			if err := applyGovernance(v, spec); err != nil {
+				return fmt.Errorf("rewrite genesis of %s: %w", nodeRoot, err)
+			}
+		}
+
+		if err := v.WriteConfig(); err != nil {
+			return fmt.Errorf("write %s: %w", v.ConfigFileUsed(), err)
+		}
+	}
+
+	return nil
+}
+
+// applyGovernance rewrites the admins and the strategies of the genesis. The
+// strategies are either a table of manager and strategy, or a list of
+// tables with the module and the typ of the newer versions.
+func applyGovernance(v *viper.Viper, spec *GenesisSpec) error {
+	var admins []interface{}
+	if raw := v.Get("genesis.admins"); raw != nil {
+		list, ok := raw.([]interface{})
+		if !ok {
+			return fmt.Errorf("invalid admins %v", raw)
+		}
+		admins = list
+	}
+	for i, raw := range admins {
+		admin, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid admin %v", raw)
+		}
+		if i < len(spec.NodeWeights) {
+			admin["weight"] = spec.NodeWeights[i]
+		}
+	}
+	for _, admin := range spec.Admins {
+		admins = append(admins, map[string]interface{}{
+			"address": admin.Address,
+			"weight":  admin.Weight,
+		})
+	}
+	v.Set("genesis.admins", admins)
+
+	if len(spec.Strategy) == 0 {
+		return nil
+	}
+	switch strategy := v.Get("genesis.strategy").(type) {
+	case []interface{}:
+		for _, raw := range strategy {
+			module, ok := raw.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("invalid strategy %v", raw)
+			}
+			for mgr, typ := range spec.Strategy {
+				if module["module"] == strategyModule(mgr) {
+					module["typ"] = typ
+				}
+			}
+		}
+		v.Set("genesis.strategy", strategy)
+	default:
+		m := make(map[string]interface{})
+		for mgr, typ := range v.GetStringMapString("genesis.strategy") {
+			m[mgr] = typ
+		}
+		// the keys are lower cased by viper
+		for mgr, typ := range spec.Strategy {
+			m[strings.ToLower(mgr)] = typ
+		}
+		v.Set("genesis.strategy", m)
+	}
+
+	return nil
+}
+
+// strategyModule returns the module name of the manager in the strategy list,
+// e.g. appchain_mgr of AppchainMgr
+func strategyModule(mgr string) string {
+	return strings.ToLower(strings.TrimSuffix(mgr, "Mgr")) + "_mgr"
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+const extraAdmin = "0x1234567890123456789012345678901234567890"
+
+func TestGenesisSpecValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    *GenesisSpec
+		version string
+		mode    string
+		err     string
+	}{
+		{name: "default", spec: &GenesisSpec{}, version: "v1.0.0", mode: types.SoloMode},
+		{name: "raft in solo", spec: &GenesisSpec{Consensus: types.RaftConsensus}, version: "v2.8.0", mode: types.SoloMode, err: "solo mode does not support"},
+		{name: "rbft in solo", spec: &GenesisSpec{Consensus: types.RBFTConsensus}, version: "v2.8.0", mode: types.SoloMode, err: "solo mode does not support"},
+		{name: "rbft", spec: &GenesisSpec{Consensus: types.RBFTConsensus}, version: "v1.11.0", mode: types.ClusterMode},
+		{name: "rbft of old version", spec: &GenesisSpec{Consensus: types.RBFTConsensus}, version: "v1.9.0", mode: types.ClusterMode, err: "does not support rbft"},
+		{name: "invalid consensus", spec: &GenesisSpec{Consensus: "pbft"}, version: "v2.8.0", mode: types.ClusterMode, err: "invalid consensus"},
+		{name: "governance of old version", spec: &GenesisSpec{NodeWeights: []uint64{1}}, version: "v1.5.0", mode: types.ClusterMode, err: "does not support governance"},
+		{name: "invalid manager", spec: &GenesisSpec{Strategy: map[string]string{"UserMgr": repo.ZeroPermission}}, version: "v2.8.0", mode: types.ClusterMode, err: "invalid manager"},
+		{name: "invalid strategy", spec: &GenesisSpec{Strategy: map[string]string{"NodeMgr": "AllAgree"}}, version: "v2.8.0", mode: types.ClusterMode, err: "invalid strategy"},
+		{name: "too many weights", spec: &GenesisSpec{NodeWeights: []uint64{1, 1, 1, 1, 1}}, version: "v2.8.0", mode: types.ClusterMode, err: "5 node weights"},
+		{name: "zero weight", spec: &GenesisSpec{NodeWeights: []uint64{1, 0}}, version: "v2.8.0", mode: types.ClusterMode, err: "weight of node2"},
+		{name: "invalid admin", spec: &GenesisSpec{Admins: []*Admin{{Address: "0x1234", Weight: 1}}}, version: "v2.8.0", mode: types.ClusterMode, err: "invalid admin address"},
+		{name: "duplicate admin", spec: &GenesisSpec{Admins: []*Admin{{Address: extraAdmin, Weight: 1}, {Address: extraAdmin, Weight: 1}}}, version: "v2.8.0", mode: types.ClusterMode, err: "duplicate admin"},
+		{name: "zero admin weight", spec: &GenesisSpec{Admins: []*Admin{{Address: extraAdmin}}}, version: "v2.8.0", mode: types.ClusterMode, err: "should be positive"},
+		{
+			name: "governance",
+			spec: &GenesisSpec{
+				Consensus:   types.RBFTConsensus,
+				Strategy:    map[string]string{"NodeMgr": repo.ZeroPermission},
+				NodeWeights: []uint64{3, 1},
+				Admins:      []*Admin{{Address: extraAdmin, Weight: 1}},
+			},
+			version: "v2.8.0",
+			mode:    types.ClusterMode,
+		},
+	}
+
+	for _, test := range tests {
+		err := test.spec.Validate(test.version, test.mode, 4)
+		if test.err == "" {
+			require.Nil(t, err, test.name)
+			continue
+		}
+		require.NotNil(t, err, test.name)
+		require.Contains(t, err.Error(), test.err, test.name)
+	}
+}
+
+func TestApplyGenesisSpec(t *testing.T) {
+	spec := &GenesisSpec{
+		Consensus:   types.RBFTConsensus,
+		Strategy:    map[string]string{"NodeMgr": repo.ZeroPermission, "AppchainMgr": repo.ZeroPermission},
+		NodeWeights: []uint64{3, 1},
+		Admins:      []*Admin{{Address: extraAdmin, Weight: 1}},
+	}
+
+	t.Run("strategy table of v1.9.0", func(t *testing.T) {
+		spec := *spec
+		// v1.9.0 orders only with raft
+		spec.Consensus = types.RaftConsensus
+		v := applyGenesisSpecTo(t, "testdata/bitxhub_v1.9.0.toml", "v1.9.0", &spec)
+
+		require.Equal(t, types.RaftConsensus, v.GetString("order.type"))
+		requireAdmins(t, v, []uint64{3, 1, 2, 2, 1})
+		// the other fields of the genesis are kept
+		require.Equal(t, "0xc7F999b83Af6DF9e67d0a37Ee7e900bF38b3D013", v.GetString("genesis.dider"))
+
+		strategy := v.GetStringMapString("genesis.strategy")
+		require.Equal(t, map[string]string{
+			"appchainmgr": repo.ZeroPermission,
+			"rulemgr":     repo.SimpleMajority,
+			"nodemgr":     repo.ZeroPermission,
+			"servicemgr":  repo.SimpleMajority,
+		}, strategy)
+	})
+
+	t.Run("strategy list of v2.8.0", func(t *testing.T) {
+		v := applyGenesisSpecTo(t, "../../scripts/docker/quick_start/bitxhub.toml", "v2.8.0", spec)
+
+		require.Equal(t, types.RBFTConsensus, v.GetString("order.type"))
+		requireAdmins(t, v, []uint64{3, 1, 2, 2, 1})
+		require.Equal(t, 1356, v.GetInt("genesis.chainid"))
+
+		modules, ok := v.Get("genesis.strategy").([]interface{})
+		require.True(t, ok)
+		typs := make(map[string]string)
+		extras := make(map[string]string)
+		for _, raw := range modules {
+			module := raw.(map[string]interface{})
+			typs[module["module"].(string)] = module["typ"].(string)
+			extras[module["module"].(string)] = module["extra"].(string)
+		}
+		require.Equal(t, repo.ZeroPermission, typs["appchain_mgr"])
+		require.Equal(t, repo.ZeroPermission, typs["node_mgr"])
+		require.Equal(t, repo.SimpleMajority, typs["rule_mgr"])
+		require.Equal(t, repo.SimpleMajority, typs["proposal_strategy_mgr"])
+		// the thresholds are kept
+		require.Equal(t, "a > 0.5 * t", extras["node_mgr"])
+	})
+}
+
+// applyGenesisSpecTo applies the spec to four nodes generated with the
+// bitxhub.toml, and returns the rewritten config of the first node.
+func applyGenesisSpecTo(t *testing.T, configPath, version string, spec *GenesisSpec) *viper.Viper {
+	target, err := ioutil.TempDir("", "genesis")
+	require.Nil(t, err)
+	t.Cleanup(func() { os.RemoveAll(target) })
+
+	data, err := ioutil.ReadFile(configPath)
+	require.Nil(t, err)
+	for _, name := range []string{"node1", "node2", "node3", "node4"} {
+		require.Nil(t, os.MkdirAll(filepath.Join(target, name), 0755))
+		require.Nil(t, ioutil.WriteFile(filepath.Join(target, name, repo.BitXHubConfigName), data, 0644))
+	}
+
+	require.Nil(t, applyGenesisSpec(target, version, types.ClusterMode, spec))
+
+	v := viper.New()
+	v.SetConfigFile(filepath.Join(target, "node1", repo.BitXHubConfigName))
+	v.SetConfigType("toml")
+	require.Nil(t, v.ReadInConfig())
+
+	return v
+}
+
+// requireAdmins checks the weights of the admins, the last one is extraAdmin
+func requireAdmins(t *testing.T, v *viper.Viper, weights []uint64) {
+	admins, ok := v.Get("genesis.admins").([]interface{})
+	require.True(t, ok)
+	require.Equal(t, len(weights), len(admins))
+	for i, raw := range admins {
+		admin := raw.(map[string]interface{})
+		require.EqualValues(t, weights[i], admin["weight"], "weight of admin %d", i)
+	}
+	last := admins[len(admins)-1].(map[string]interface{})
+	require.True(t, strings.EqualFold(extraAdmin, last["address"].(string)))
+}
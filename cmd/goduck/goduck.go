@@ -1,10 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"time"
 
+	"github.com/meshplus/goduck/internal/download"
+	"github.com/meshplus/goduck/internal/utils"
 	"github.com/urfave/cli/v2"
 )
 
@@ -24,14 +27,58 @@ func main() {
 			Name:  "repo",
 			Usage: "GoDuck storage repo path",
 		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "Timeout of each script or external command, e.g. 30m, there is no timeout if it is not set",
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Value:   OutputTable,
+			Usage:   "Output format of status, info and version, one of table, json or yaml",
+		},
+		&cli.StringFlag{
+			Name:    "mirror",
+			EnvVars: []string{"GODUCK_MIRROR"},
+			Usage:   "Base url or local directory mirroring the artifacts, e.g. https://github.com/a/b is looked up at <mirror>/github.com/a/b",
+		},
+		&cli.StringFlag{
+			Name:    "cache-dir",
+			EnvVars: []string{"GODUCK_CACHE_DIR"},
+			Usage:   "Directory shared by repos to cache the downloaded artifacts, in the same layout as the mirror",
+		},
+		&cli.BoolFlag{
+			Name:    "offline",
+			EnvVars: []string{"GODUCK_OFFLINE"},
+			Usage:   "Download artifacts only from the cache and the mirror",
+		},
+		&cli.BoolFlag{
+			Name:    "require-checksum",
+			EnvVars: []string{"GODUCK_REQUIRE_CHECKSUM"},
+			Usage:   "Refuse to download artifacts without a sha256 checksum in release.json or checksums.sha256",
+		},
+	}
+
+	app.Before = func(ctx *cli.Context) error {
+		if ctx.IsSet("timeout") {
+			utils.DefaultTimeout = ctx.Duration("timeout")
+		}
+		download.Mirror = ctx.String("mirror")
+		download.CacheDir = ctx.String("cache-dir")
+		download.Offline = ctx.Bool("offline")
+		download.RequireChecksum = ctx.Bool("require-checksum")
+		return checkOutputFormat(ctx.String("output"))
 	}
 
 	app.Commands = []*cli.Command{
 		GetInitCMD(),
 		playgroundCMD(),
+		upCMD(),
+		downCMD(),
 		bitxhubCMD(),
 		pierCMD,
 		deployCMD(),
+		binCMD(),
 		etherCMD(),
 		fabricCMD(),
 		hpcCMD,
@@ -39,6 +86,7 @@ func main() {
 		GetStatusCMD(),
 		infoCMD(),
 		keyCMD(),
+		certCMD(),
 		prometheusCMD(),
 		logCMD(),
 		getVersionCMD(),
@@ -47,5 +95,10 @@ func main() {
 	err := app.Run(os.Args)
 	if err != nil {
 		fmt.Println(err)
+
+		var execErr *utils.ExecError
+		if errors.As(err, &execErr) && execErr.ExitCode > 0 {
+			os.Exit(execErr.ExitCode)
+		}
 	}
 }
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/pelletier/go-toml"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	Healthy   = "healthy"
+	Unhealthy = "unhealthy"
+
+	dockerHostName = "host.docker.internal"
+)
+
+// HealthCheck is the result of probing an endpoint of a component.
+type HealthCheck struct {
+	Name      string  `json:"name" yaml:"name"`
+	Component string  `json:"component" yaml:"component"`
+	Endpoint  string  `json:"endpoint" yaml:"endpoint"`
+	Status    string  `json:"status" yaml:"status"`
+	Height    *uint64 `json:"height,omitempty" yaml:"height,omitempty"`
+	Advancing *bool   `json:"advancing,omitempty" yaml:"advancing,omitempty"`
+	Peers     *int    `json:"peers,omitempty" yaml:"peers,omitempty"`
+	Message   string  `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+func (h *HealthCheck) fail(format string, a ...interface{}) {
+	h.Status = Unhealthy
+	msg := fmt.Sprintf(format, a...)
+	if h.Message != "" {
+		msg = h.Message + "; " + msg
+	}
+	h.Message = msg
+}
+
+// note adds the message without changing the status
+func (h *HealthCheck) note(format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	if h.Message != "" {
+		msg = h.Message + "; " + msg
+	}
+	h.Message = msg
+}
+
+func (h *HealthCheck) row() []string {
+	height, advancing, peers := "-", "-", "-"
+	if h.Height != nil {
+		height = strconv.FormatUint(*h.Height, 10)
+	}
+	if h.Advancing != nil {
+		advancing = strconv.FormatBool(*h.Advancing)
+	}
+	if h.Peers != nil {
+		peers = strconv.Itoa(*h.Peers)
+	}
+
+	return []string{h.Name, h.Component, h.Endpoint, h.Status, height, advancing, peers, h.Message}
+}
+
+// nodeProbe holds the endpoints of a bitxhub node read from its config
+type nodeProbe struct {
+	check   *HealthCheck
+	jsonrpc string
+	gateway string
+	// expected number of peers, -1 if unknown
+	expected int
+}
+
+type prober struct {
+	timeout    time.Duration
+	client     *http.Client
+	components []*ComponentStatus
+}
+
+func showHealth(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+
+	if !fileutil.Exist(repoRoot) {
+		return fmt.Errorf("please `goduck init` first")
+	}
+
+	components, err := listComponents(ctx.Context)
+	if err != nil {
+		return err
+	}
+
+	p := &prober{
+		timeout:    ctx.Duration("probe-timeout"),
+		client:     &http.Client{Timeout: ctx.Duration("probe-timeout")},
+		components: components,
+	}
+
+	var (
+		checks []*HealthCheck
+		nodes  []*nodeProbe
+	)
+	for _, c := range components {
+		switch c.Component {
+		case "bitxhub":
+			node := p.nodeProbe(c)
+			nodes = append(nodes, node)
+			checks = append(checks, node.check)
+		case "pier":
+			checks = append(checks, p.pierChecks(c)...)
+		}
+	}
+
+	if len(nodes) == 0 {
+		checks = append([]*HealthCheck{{
+			Name:      "bitxhub",
+			Component: "bitxhub",
+			Status:    Unhealthy,
+			Message:   "no bitxhub node is running",
+		}}, checks...)
+	}
+
+	p.probeNodes(nodes, ctx.Duration("interval"))
+
+	table := [][]string{{"Name", "Component", "Endpoint", "Status", "Height", "Advancing", "Peers", "Message"}}
+	unhealthy := 0
+	for _, check := range checks {
+		table = append(table, check.row())
+		if check.Status != Healthy {
+			unhealthy++
+		}
+	}
+
+	if err := printOutput(ctx, checks, table); err != nil {
+		return err
+	}
+
+	if unhealthy != 0 {
+		return cli.Exit(fmt.Sprintf("%d of %d checks are unhealthy", unhealthy, len(checks)), 1)
+	}
+
+	return nil
+}
+
+// nodeProbe reads the ports of the node from bitxhub.toml and network.toml
+func (p *prober) nodeProbe(c *ComponentStatus) *nodeProbe {
+	node := &nodeProbe{
+		check: &HealthCheck{
+			Name:      c.Name,
+			Component: c.Component,
+			Status:    Healthy,
+		},
+		expected: -1,
+	}
+
+	if c.Status != "running" {
+		node.check.fail("%s is %s", c.Name, c.Status)
+		return node
+	}
+	if c.Repo == "" {
+		node.check.fail("config directory is not found")
+		return node
+	}
+
+	tree, err := toml.LoadFile(filepath.Join(c.Repo, repo.BitXHubConfigName))
+	if err != nil {
+		node.check.fail("load %s: %s", repo.BitXHubConfigName, err)
+		return node
+	}
+
+	grpc := p.hostAddr(c, "127.0.0.1", tomlInt(tree, "port.grpc"))
+	node.check.Endpoint = grpc
+	if err := p.dial(grpc); err != nil {
+		node.check.fail("grpc unreachable: %s", err)
+	}
+	if port := tomlInt(tree, "port.jsonrpc"); port != 0 {
+		node.jsonrpc = fmt.Sprintf("http://%s", p.hostAddr(c, "127.0.0.1", port))
+	}
+	if port := tomlInt(tree, "port.gateway"); port != 0 {
+		node.gateway = fmt.Sprintf("http://%s", p.hostAddr(c, "127.0.0.1", port))
+	}
+
+	var network NetworkConfig
+	data, err := ioutil.ReadFile(filepath.Join(c.Repo, repo.NetworkConfigName))
+	if err == nil && toml.Unmarshal(data, &network) == nil {
+		if len(network.Nodes) != 0 {
+			node.expected = len(network.Nodes) - 1
+		} else if network.N != 0 {
+			node.expected = int(network.N) - 1
+		}
+	}
+
+	return node
+}
+
+// probeNodes samples the height of all nodes twice to check whether it is advancing
+func (p *prober) probeNodes(nodes []*nodeProbe, interval time.Duration) {
+	first := make([]*uint64, len(nodes))
+	sampled := false
+	for i, node := range nodes {
+		if node.check.Status != Healthy {
+			continue
+		}
+
+		height, err := p.height(node)
+		if err != nil {
+			node.check.fail("get height: %s", err)
+			continue
+		}
+		first[i] = &height
+		sampled = true
+
+		if node.jsonrpc != "" {
+			var peers string
+			if err := p.jsonrpc(node.jsonrpc, "net_peerCount", &peers); err == nil {
+				if count, err := strconv.ParseUint(strings.TrimPrefix(peers, "0x"), 16, 64); err == nil {
+					n := int(count)
+					node.check.Peers = &n
+					if node.expected > 0 && n < node.expected {
+						node.check.fail("%d of %d peers connected", n, node.expected)
+					}
+				}
+			}
+		}
+	}
+
+	if !sampled {
+		return
+	}
+	time.Sleep(interval)
+
+	for i, node := range nodes {
+		if first[i] == nil {
+			continue
+		}
+
+		height, err := p.height(node)
+		if err != nil {
+			node.check.fail("get height: %s", err)
+			continue
+		}
+		advancing := height > *first[i]
+		node.check.Height = &height
+		node.check.Advancing = &advancing
+	}
+}
+
+// height gets the chain height from json-rpc, or from the gateway for the versions without json-rpc
+func (p *prober) height(node *nodeProbe) (uint64, error) {
+	var errs []string
+	if node.jsonrpc != "" {
+		var number string
+		err := p.jsonrpc(node.jsonrpc, "eth_blockNumber", &number)
+		if err == nil {
+			return strconv.ParseUint(strings.TrimPrefix(number, "0x"), 16, 64)
+		}
+		errs = append(errs, err.Error())
+	}
+
+	if node.gateway != "" {
+		resp, err := p.client.Get(node.gateway + "/v1/chain_meta")
+		if err == nil {
+			defer resp.Body.Close()
+			var meta struct {
+				Height json.Number `json:"height"`
+			}
+			if err = json.NewDecoder(resp.Body).Decode(&meta); err == nil {
+				return strconv.ParseUint(meta.Height.String(), 10, 64)
+			}
+		}
+		errs = append(errs, err.Error())
+	}
+
+	if len(errs) == 0 {
+		return 0, fmt.Errorf("neither jsonrpc nor gateway port is configured")
+	}
+
+	return 0, fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+func (p *prober) jsonrpc(endpoint, method string, result interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  []interface{}{},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var res struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("decode %s response: %w", method, err)
+	}
+	if res.Error != nil {
+		return fmt.Errorf("%s: %s", method, res.Error.Message)
+	}
+
+	return json.Unmarshal(res.Result, result)
+}
+
+// pierChecks checks the ports in pier.toml, the api port in the api file and
+// the appchain endpoint the pier connects to
+func (p *prober) pierChecks(c *ComponentStatus) []*HealthCheck {
+	check := &HealthCheck{
+		Name:      c.Name,
+		Component: c.Component,
+		Status:    Healthy,
+	}
+
+	if c.Status != "running" {
+		check.fail("%s is %s", c.Name, c.Status)
+		return []*HealthCheck{check}
+	}
+	if c.Repo == "" {
+		check.fail("config directory is not found")
+		return []*HealthCheck{check}
+	}
+
+	tree, err := toml.LoadFile(filepath.Join(c.Repo, repo.PierConfigName))
+	if err != nil {
+		check.fail("load %s: %s", repo.PierConfigName, err)
+		return []*HealthCheck{check}
+	}
+
+	ports := []struct {
+		name string
+		port int
+	}{
+		{"http", tomlInt(tree, "port.http")},
+		{"pprof", tomlInt(tree, "port.pprof")},
+		{"api", pierAPIPort(c.Repo)},
+	}
+
+	var endpoints []string
+	for _, port := range ports {
+		if port.port == 0 {
+			check.note("%s port not configured", port.name)
+			continue
+		}
+		addr := p.hostAddr(c, "127.0.0.1", port.port)
+		endpoints = append(endpoints, addr)
+		if err := p.dial(addr); err != nil {
+			check.fail("%s port unreachable: %s", port.name, err)
+		}
+	}
+	check.Endpoint = strings.Join(endpoints, ",")
+	checks := []*HealthCheck{check}
+
+	appchain, _ := tree.Get("appchain.config").(string)
+	if appchain == "" {
+		return checks
+	}
+	chainTree, err := toml.LoadFile(filepath.Join(c.Repo, appchain, appchain+".toml"))
+	if err != nil {
+		return checks
+	}
+	addr, _ := chainTree.Get("addr").(string)
+	host, port, ok := splitEndpoint(addr)
+	if !ok {
+		return checks
+	}
+
+	chainCheck := &HealthCheck{
+		Name:      c.Name,
+		Component: appchain,
+		Endpoint:  addr,
+		Status:    Healthy,
+	}
+	if err := p.dial(p.appchainAddr(c, host, port)); err != nil {
+		chainCheck.fail("appchain unreachable: %s", err)
+	}
+
+	return append(checks, chainCheck)
+}
+
+// pierAPIPort returns the port in the api file of the pier repo, which is like
+// http://localhost:8080/v1/, or 0 if it is not configured
+func pierAPIPort(pierRepo string) int {
+	data, err := ioutil.ReadFile(filepath.Join(pierRepo, "api"))
+	if err != nil {
+		return 0
+	}
+	_, port, ok := splitEndpoint(strings.TrimSpace(string(data)))
+	if !ok {
+		return 0
+	}
+
+	return port
+}
+
+// appchainAddr returns the address of the appchain reachable from the host, the
+// address used by a pier in docker may be the docker host or another container.
+func (p *prober) appchainAddr(pier *ComponentStatus, host string, port int) string {
+	if pier.Mode == modes[1] {
+		if host == dockerHostName {
+			return net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+		}
+		for _, c := range p.components {
+			if c.Mode == modes[1] && c.Name == host {
+				return p.hostAddr(c, "127.0.0.1", port)
+			}
+		}
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func (p *prober) hostAddr(c *ComponentStatus, host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(c.hostPort(port)))
+}
+
+func (p *prober) dial(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, p.timeout)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+// splitEndpoint splits the address like ws://127.0.0.1:8546 or 127.0.0.1:8546
+func splitEndpoint(addr string) (string, int, bool) {
+	if strings.Contains(addr, "://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return "", 0, false
+		}
+		addr = u.Host
+	}
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, false
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return host, port, true
+}
+
+func tomlInt(tree *toml.Tree, key string) int {
+	switch v := tree.Get(key).(type) {
+	case int64:
+		return int(v)
+	case string:
+		i, _ := strconv.Atoi(v)
+		return i
+	default:
+		return 0
+	}
+}
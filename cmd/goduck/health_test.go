@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPierChecks(t *testing.T) {
+	pierRepo, err := ioutil.TempDir("", "health")
+	require.Nil(t, err)
+	defer os.RemoveAll(pierRepo)
+
+	httpLn, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer httpLn.Close()
+	apiLn, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	apiPort := apiLn.Addr().(*net.TCPAddr).Port
+
+	// pprof port is missing
+	config := fmt.Sprintf("[port]\nhttp = %d\n", httpLn.Addr().(*net.TCPAddr).Port)
+	require.Nil(t, ioutil.WriteFile(filepath.Join(pierRepo, repo.PierConfigName), []byte(config), 0644))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(pierRepo, "api"), []byte(fmt.Sprintf("http://localhost:%d/v1/\n", apiPort)), 0644))
+	require.Equal(t, apiPort, pierAPIPort(pierRepo))
+
+	p := &prober{timeout: time.Second}
+	c := &ComponentStatus{Name: "pier-ethereum", Component: "pier", Status: "running", Repo: pierRepo}
+	checks := p.pierChecks(c)
+	require.Len(t, checks, 1)
+	require.Equal(t, Healthy, checks[0].Status)
+	require.Contains(t, checks[0].Endpoint, fmt.Sprintf("127.0.0.1:%d", apiPort))
+	require.Contains(t, checks[0].Message, "pprof port not configured")
+	require.NotContains(t, checks[0].Endpoint, ":0")
+
+	// the api port is probed
+	require.Nil(t, apiLn.Close())
+	checks = p.pierChecks(c)
+	require.Equal(t, Unhealthy, checks[0].Status)
+	require.Contains(t, checks[0].Message, "api port unreachable")
+
+	// the api port is not configured without the api file
+	require.Nil(t, os.Remove(filepath.Join(pierRepo, "api")))
+	require.Equal(t, 0, pierAPIPort(pierRepo))
+	checks = p.pierChecks(c)
+	require.Equal(t, Healthy, checks[0].Status)
+	require.Contains(t, checks[0].Message, "api port not configured")
+}
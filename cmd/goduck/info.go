@@ -2,14 +2,40 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
 	"github.com/meshplus/goduck/internal/repo"
 	"github.com/meshplus/goduck/internal/types"
 	"github.com/meshplus/goduck/internal/utils"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/semver"
 )
 
+// Info is the basic info about the interchain system.
+type Info struct {
+	BitXHub []*NodeInfo `json:"bitxhub" yaml:"bitxhub"`
+	Piers   []*PierInfo `json:"piers" yaml:"piers"`
+}
+
+// NodeInfo is the basic info about a bitxhub node.
+type NodeInfo struct {
+	Name    string `json:"name" yaml:"name"`
+	Mode    string `json:"mode" yaml:"mode"`
+	Address string `json:"address" yaml:"address"`
+}
+
+// PierInfo is the basic info about a pier.
+type PierInfo struct {
+	Appchain string `json:"appchain" yaml:"appchain"`
+	Mode     string `json:"mode" yaml:"mode"`
+	ID       string `json:"id" yaml:"id"`
+}
+
 func infoCMD() *cli.Command {
 	return &cli.Command{
 		Name:  "info",
@@ -31,7 +57,13 @@ func infoCMD() *cli.Command {
 					if !fileutil.Exist(repoRoot) {
 						return fmt.Errorf("please `goduck init` first")
 					}
-					return showBxhInfo(repoRoot)
+
+					nodes, err := bxhInfo(repoRoot)
+					if err != nil {
+						return err
+					}
+
+					return printOutput(ctx, nodes, nodeInfoTable(nodes))
 				},
 			},
 			{
@@ -45,7 +77,13 @@ func infoCMD() *cli.Command {
 					if !fileutil.Exist(repoRoot) {
 						return fmt.Errorf("please `goduck init` first")
 					}
-					return showPierInfo(repoRoot)
+
+					piers, err := pierInfo(repoRoot)
+					if err != nil {
+						return err
+					}
+
+					return printOutput(ctx, piers, pierInfoTable(piers))
 				},
 			},
 		},
@@ -61,30 +99,189 @@ func showInfo(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	if err := showBxhInfo(repoRoot); err != nil {
+	nodes, err := bxhInfo(repoRoot)
+	if err != nil {
 		return err
 	}
 
-	if err := showPierInfo(repoRoot); err != nil {
+	piers, err := pierInfo(repoRoot)
+	if err != nil {
 		return err
 	}
-	return nil
+
+	if ctx.String("output") == OutputTable {
+		PrintTable(nodeInfoTable(nodes), true)
+		fmt.Println()
+		PrintTable(pierInfoTable(piers), true)
+		return nil
+	}
+
+	return printOutput(ctx, &Info{BitXHub: nodes, Piers: piers}, nil)
 }
 
-func showPierInfo(repoPath string) error {
-	args := []string{types.InfoScript, "pier"}
-	if err := utils.ExecuteShell(args, repoPath); err != nil {
-		return err
+func nodeInfoTable(nodes []*NodeInfo) [][]string {
+	table := [][]string{{"Name", "Mode", "Address"}}
+	for _, node := range nodes {
+		table = append(table, []string{node.Name, node.Mode, node.Address})
 	}
 
-	return nil
+	return table
 }
 
-func showBxhInfo(repoPath string) error {
-	args := []string{types.InfoScript, "bitxhub"}
-	if err := utils.ExecuteShell(args, repoPath); err != nil {
-		return err
+func pierInfoTable(piers []*PierInfo) [][]string {
+	table := [][]string{{"Appchain", "Mode", "ID"}}
+	for _, pier := range piers {
+		table = append(table, []string{pier.Appchain, pier.Mode, pier.ID})
+	}
+
+	return table
+}
+
+func bxhInfo(repoRoot string) ([]*NodeInfo, error) {
+	nodes := []*NodeInfo{}
+
+	version := ""
+	versionPath := filepath.Join(repoRoot, "bitxhub", types.BitxhubVersionFile)
+	if fileutil.Exist(versionPath) {
+		data, err := ioutil.ReadFile(versionPath)
+		if err != nil {
+			return nil, err
+		}
+		version = strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+	}
+	keyName := bxhKeyName(version)
+
+	sup, err := bitxhub.NewSupervisor(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	sup.Refresh()
+	state := sup.State()
+	for _, node := range state.Nodes {
+		if node.Status != bitxhub.NodeRunning {
+			continue
+		}
+
+		addr, err := execOutput(filepath.Join(state.BinPath, "bitxhub"), "key", "address", "--path", filepath.Join(node.Repo, keyName))
+		if err != nil {
+			return nil, fmt.Errorf("get address of %s: %w", node.Name, err)
+		}
+		nodes = append(nodes, &NodeInfo{Name: node.Name, Mode: types.TypeBinary, Address: addr})
+	}
+
+	for _, name := range []string{"bitxhub_solo", "bitxhub_node"} {
+		cids, err := dockerContainers(name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cid := range cids {
+			containerName, err := execOutput("docker", "inspect", "-f", "{{.Name}}", cid)
+			if err != nil {
+				return nil, err
+			}
+			addr, err := execOutput("docker", "exec", cid, "bitxhub", "key", "address", "--path", "/root/.bitxhub/"+keyName)
+			if err != nil {
+				return nil, fmt.Errorf("get address of %s: %w", cid, err)
+			}
+			nodes = append(nodes, &NodeInfo{Name: strings.TrimPrefix(containerName, "/"), Mode: types.TypeDocker, Address: addr})
+		}
+	}
+
+	return nodes, nil
+}
+
+// bxhKeyName returns the node key path relative to the node repo of the given bitxhub version
+func bxhKeyName(version string) string {
+	switch {
+	case version == "v1.0.0" || version == "v1.0.0-rc1" || version == "v1.1.0-rc1":
+		return "certs/node.priv"
+	case semver.IsValid(version) && semver.Compare(version, "v1.11.0") >= 0:
+		return "key.json"
+	default:
+		return "certs/key.priv"
+	}
+}
+
+func pierInfo(repoRoot string) ([]*PierInfo, error) {
+	piers := []*PierInfo{}
+
+	for _, appchain := range pierAppchainTypes() {
+		cids, err := dockerContainers("pier-" + appchain)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cid := range cids {
+			id, err := execOutput("docker", "exec", cid, "pier", "--repo=/root/.pier", "id")
+			if err != nil {
+				return nil, fmt.Errorf("get id of pier %s: %w", cid, err)
+			}
+			piers = append(piers, &PierInfo{Appchain: appchain, Mode: types.TypeDocker, ID: id})
+		}
+	}
+
+	for _, appchain := range pierAppchainTypes() {
+		pidPath := filepath.Join(repoRoot, "pier", fmt.Sprintf("pier-%s.pid", appchain))
+		addrPath := filepath.Join(repoRoot, "pier", fmt.Sprintf("pier-%s-binary.addr", appchain))
+		if !fileutil.Exist(pidPath) || !fileutil.Exist(addrPath) {
+			continue
+		}
+
+		pids, err := ioutil.ReadFile(pidPath)
+		if err != nil {
+			return nil, err
+		}
+		if !pidsAlive(string(pids)) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(addrPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range strings.Fields(string(data)) {
+			piers = append(piers, &PierInfo{Appchain: appchain, Mode: types.TypeBinary, ID: id})
+		}
+	}
+
+	return piers, nil
+}
+
+func pidsAlive(pids string) bool {
+	for _, field := range strings.Fields(pids) {
+		var pid int
+		if _, err := fmt.Sscanf(field, "%d", &pid); err == nil && bitxhub.ProcessExists(pid) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func dockerContainers(name string) ([]string, error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return nil, nil
+	}
+
+	out, err := execOutput("docker", "ps", "-q", "-f", "name="+name)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(out), nil
+}
+
+// execOutput runs the command and returns its trimmed stdout
+func execOutput(command string, args ...string) (string, error) {
+	task := utils.ExecTask{
+		Command: command,
+		Args:    args,
+	}
+	result, err := task.Execute()
+	if err != nil {
+		return "", err
 	}
 
-	return nil
+	return strings.TrimSpace(result.Stdout), nil
 }
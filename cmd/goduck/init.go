@@ -14,6 +14,7 @@ import (
 	"github.com/fatih/color"
 	"github.com/gobuffalo/packd"
 	"github.com/gobuffalo/packr/v2"
+	"github.com/meshplus/goduck/internal/release"
 	"github.com/meshplus/goduck/internal/repo"
 	"github.com/meshplus/goduck/internal/types"
 	"github.com/urfave/cli/v2"
@@ -96,8 +97,21 @@ func ModifyConfigInit(repo string) error {
 		}
 	}
 
+	m, err := release.Load(repo)
+	if err != nil {
+		return err
+	}
+	bxhConfigs := make(map[string]bool)
+	for _, r := range m.BitXHub {
+		bxhConfigs[r.Config] = true
+	}
+	pierConfigs := make(map[string]bool)
+	for _, r := range m.Pier {
+		pierConfigs[r.Config] = true
+	}
+
 	if runtime.GOOS == types.DarwinSystem {
-		for _, v := range bxhConfigMap {
+		for v := range bxhConfigs {
 			bxhConfigPath := filepath.Join(repo, types.BxhConfigRepo, v, types.BxhModifyConfig)
 			err := sh.Command("/bin/bash", "-c", fmt.Sprintf("sed -i '' \"s/REPO/%s/g\" %s", repoTmp, bxhConfigPath)).Run()
 			if err != nil {
@@ -105,7 +119,7 @@ func ModifyConfigInit(repo string) error {
 			}
 		}
 
-		for _, v := range pierConfigMap {
+		for v := range pierConfigs {
 			pierConfigPath := filepath.Join(repo, types.PierConfigRepo, v, types.PierModifyConfig)
 			err := sh.Command("/bin/bash", "-c", fmt.Sprintf("sed -i '' \"s/REPO/%s/g\" %s", repoTmp, pierConfigPath)).Run()
 			if err != nil {
@@ -113,7 +127,7 @@ func ModifyConfigInit(repo string) error {
 			}
 		}
 	} else if runtime.GOOS == types.LinuxSystem {
-		for _, v := range bxhConfigMap {
+		for v := range bxhConfigs {
 			bxhConfigPath := filepath.Join(repo, types.BxhConfigRepo, v, types.BxhModifyConfig)
 			err := sh.Command("/bin/bash", "-c", fmt.Sprintf("sed -i \"s/REPO/%s/g\" %s", repoTmp, bxhConfigPath)).Run()
 			if err != nil {
@@ -121,7 +135,7 @@ func ModifyConfigInit(repo string) error {
 			}
 		}
 
-		for _, v := range pierConfigMap {
+		for v := range pierConfigs {
 			pierConfigPath := filepath.Join(repo, types.PierConfigRepo, v, types.PierModifyConfig)
 			err := sh.Command("/bin/bash", "-c", fmt.Sprintf("sed -i \"s/REPO/%s/g\" %s", repoTmp, pierConfigPath)).Run()
 			if err != nil {
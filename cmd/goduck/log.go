@@ -1,21 +1,67 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
 	"path/filepath"
-	"strconv"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/fatih/color"
 	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
+	"github.com/meshplus/goduck/internal/logs"
 	"github.com/meshplus/goduck/internal/repo"
 	"github.com/meshplus/goduck/internal/types"
-	"github.com/meshplus/goduck/internal/utils"
+	"github.com/pelletier/go-toml"
 	"github.com/urfave/cli/v2"
 )
 
+var logFilterFlags = []cli.Flag{
+	&cli.BoolFlag{
+		Name:    "follow",
+		Aliases: []string{"f"},
+		Usage:   "Keep printing new lines of the log",
+	},
+	&cli.StringFlag{
+		Name:  "since",
+		Usage: "Only print the log since a relative duration (e.g. 10m) or a time (e.g. 2021-09-22T11:16:02)",
+	},
+	&cli.StringFlag{
+		Name:  "level",
+		Usage: "Only print the log of the level or above, one of trace, debug, info, warning, error, fatal or panic",
+	},
+	&cli.StringSliceFlag{
+		Name:  "module",
+		Usage: "Only print the log of the modules, e.g. p2p,order",
+	},
+	&cli.StringFlag{
+		Name:  "grep",
+		Usage: "Only print the lines matching the regular expression",
+	},
+}
+
+var logColors = []*color.Color{
+	color.New(color.FgCyan),
+	color.New(color.FgGreen),
+	color.New(color.FgYellow),
+	color.New(color.FgMagenta),
+	color.New(color.FgBlue),
+	color.New(color.FgRed),
+}
+
 func logCMD() *cli.Command {
 	return &cli.Command{
 		Name:  "log",
-		Usage: "Print log of BitXHub or Pier",
+		Usage: "Print or collect log of BitXHub or Pier",
 		Subcommands: []*cli.Command{
 			{
 				Name:  "bitxhub",
@@ -24,7 +70,7 @@ func logCMD() *cli.Command {
 					{
 						Name:  "all",
 						Usage: "Print all log of BitXHub node",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name:  "type",
 								Value: types.TypeBinary,
@@ -32,28 +78,28 @@ func logCMD() *cli.Command {
 							},
 							&cli.StringFlag{
 								Name:     "bxh-repo",
-								Usage:    "Specify BitXHub node repo, e.g. $repo/bitxhub/.bitxhub/node1, only for binary",
+								Usage:    "Specify BitXHub node repo, e.g. $repo/bitxhub/.bitxhub/node1, only for binary, the logs of all nodes are merged if not set",
 								Required: false,
 							},
 							&cli.IntFlag{
 								Name:     "num",
-								Usage:    "Specify the sequence num of the node, only for docker",
+								Usage:    "Specify the sequence num of the node, only for docker, the logs of all nodes are merged if not set",
 								Value:    1,
 								Required: false,
 							},
 							&cli.IntFlag{
 								Name:     "n",
-								Usage:    "Specify the number of lines to print the latest log, -1 indicates that all logs are printed",
+								Usage:    "Specify the number of the latest lines of each node to print, -1 indicates that all logs are printed",
 								Value:    -1,
 								Required: false,
 							},
-						},
+						}, logFilterFlags...),
 						Action: bxhAll,
 					},
 					{
 						Name:  "net",
 						Usage: "Print all log of BitXHub node",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name:  "type",
 								Value: types.TypeBinary,
@@ -61,12 +107,12 @@ func logCMD() *cli.Command {
 							},
 							&cli.StringFlag{
 								Name:     "bxh-repo",
-								Usage:    "Specify BitXHub node repo, e.g. $repo/bitxhub/.bitxhub/node1, only for binary",
+								Usage:    "Specify BitXHub node repo, e.g. $repo/bitxhub/.bitxhub/node1, only for binary, the logs of all nodes are merged if not set",
 								Required: false,
 							},
 							&cli.IntFlag{
 								Name:     "num",
-								Usage:    "Specify the sequence num of the node, only for docker",
+								Usage:    "Specify the sequence num of the node, only for docker, the logs of all nodes are merged if not set",
 								Value:    1,
 								Required: false,
 							},
@@ -76,13 +122,13 @@ func logCMD() *cli.Command {
 								Value:    -1,
 								Required: false,
 							},
-						},
+						}, logFilterFlags...),
 						Action: bxhNet,
 					},
 					{
 						Name:  "order",
 						Usage: "Print all log of BitXHub node",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name:  "type",
 								Value: types.TypeBinary,
@@ -90,12 +136,12 @@ func logCMD() *cli.Command {
 							},
 							&cli.StringFlag{
 								Name:     "bxh-repo",
-								Usage:    "Specify BitXHub node repo, e.g. $repo/bitxhub/.bitxhub/node1, only for binary",
+								Usage:    "Specify BitXHub node repo, e.g. $repo/bitxhub/.bitxhub/node1, only for binary, the logs of all nodes are merged if not set",
 								Required: false,
 							},
 							&cli.IntFlag{
 								Name:     "num",
-								Usage:    "Specify the sequence num of the node, only for docker",
+								Usage:    "Specify the sequence num of the node, only for docker, the logs of all nodes are merged if not set",
 								Value:    1,
 								Required: false,
 							},
@@ -105,7 +151,7 @@ func logCMD() *cli.Command {
 								Value:    -1,
 								Required: false,
 							},
-						},
+						}, logFilterFlags...),
 						Action: bxhOrder,
 					},
 				},
@@ -113,7 +159,7 @@ func logCMD() *cli.Command {
 			{
 				Name:  "pier",
 				Usage: "Print log of Pier",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:  "type",
 						Value: types.TypeBinary,
@@ -121,12 +167,12 @@ func logCMD() *cli.Command {
 					},
 					&cli.StringFlag{
 						Name:     "pier-repo",
-						Usage:    "Specify Pier repo, e.g. $repo/pier/.pier_ethereum, only for binary",
+						Usage:    "Specify Pier repo, e.g. $repo/pier/.pier_ethereum, only for binary, the logs of all piers are merged if not set",
 						Required: false,
 					},
 					&cli.IntFlag{
 						Name:     "num",
-						Usage:    "Specify the sequence num of the pier, only for docker",
+						Usage:    "Specify the sequence num of the pier, only for docker, the logs of all piers are merged if not set",
 						Value:    1,
 						Required: false,
 					},
@@ -137,18 +183,41 @@ func logCMD() *cli.Command {
 					},
 					&cli.IntFlag{
 						Name:     "n",
-						Usage:    "Specify the number of lines to print the latest log, -1 indicates that all logs are printed",
+						Usage:    "Specify the number of the latest lines of each node to print, -1 indicates that all logs are printed",
 						Value:    -1,
 						Required: false,
 					},
-				},
+				}, logFilterFlags...),
 				Action: pierLog,
 			},
+			{
+				Name:  "collect",
+				Usage: "Collect logs, configs, status and versions into a tar.gz to diagnose problems, keys and passwords are excluded or redacted",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "dest",
+						Usage: "Path of the tar.gz, default: ./goduck-diagnostics-<time>.tar.gz",
+					},
+				},
+				Action: collectLog,
+			},
 		},
 	}
 }
 
 func bxhAll(ctx *cli.Context) error {
+	return showBxhLog(ctx, nil)
+}
+
+func bxhNet(ctx *cli.Context) error {
+	return showBxhLog(ctx, []string{"p2p"})
+}
+
+func bxhOrder(ctx *cli.Context) error {
+	return showBxhLog(ctx, []string{"order"})
+}
+
+func showBxhLog(ctx *cli.Context, modules []string) error {
 	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
 	if err != nil {
 		return err
@@ -157,29 +226,23 @@ func bxhAll(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	typ := ctx.String("type")
-	target := ctx.String("bxh-repo")
-	num := ctx.Int("num")
-	n := ctx.Int("n")
-
-	if typ == types.TypeBinary && target == "" {
-		return fmt.Errorf("repo can not be nil")
+	var sources []logs.Source
+	switch typ := ctx.String("type"); typ {
+	case types.TypeBinary:
+		sources, err = bxhFileSources(repoRoot, ctx.String("bxh-repo"))
+	case types.TypeDocker:
+		sources, err = dockerSources(ctx.Context, "bitxhub", "", ctx.IsSet("num"), ctx.Int("num"))
+	default:
+		return fmt.Errorf("unsupported type %s, one of binary or docker", typ)
 	}
-
-	path, err := filepath.Abs(target)
 	if err != nil {
-		return fmt.Errorf("get absolute target path: %w", err)
-	}
-
-	args := []string{types.LogScript, "bxhAll", typ, path, strconv.Itoa(n), strconv.Itoa(num)}
-	if err := utils.ExecuteShell(args, repoRoot); err != nil {
 		return err
 	}
 
-	return nil
+	return streamLogs(ctx, sources, modules)
 }
 
-func bxhNet(ctx *cli.Context) error {
+func pierLog(ctx *cli.Context) error {
 	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
 	if err != nil {
 		return err
@@ -188,87 +251,245 @@ func bxhNet(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	typ := ctx.String("type")
-	target := ctx.String("bxh-repo")
-	num := ctx.Int("num")
-	n := ctx.Int("n")
+	var sources []logs.Source
+	switch typ := ctx.String("type"); typ {
+	case types.TypeBinary:
+		sources, err = pierFileSources(repoRoot, ctx.String("pier-repo"))
+	case types.TypeDocker:
+		appchain := ""
+		if ctx.IsSet("appchain") {
+			appchain = ctx.String("appchain")
+		}
+		sources, err = dockerSources(ctx.Context, "pier", appchain, ctx.IsSet("num"), ctx.Int("num"))
+	default:
+		return fmt.Errorf("unsupported type %s, one of binary or docker", typ)
+	}
+	if err != nil {
+		return err
+	}
 
-	if typ == types.TypeBinary && target == "" {
-		return fmt.Errorf("repo can not be nil")
+	return streamLogs(ctx, sources, nil)
+}
+
+// streamLogs prints the logs of the sources, the lines are prefixed with the
+// node name if there are more than one source.
+func streamLogs(ctx *cli.Context, sources []logs.Source, modules []string) error {
+	if len(sources) == 0 {
+		return fmt.Errorf("no log is found")
 	}
 
-	path, err := filepath.Abs(target)
+	since, err := parseSince(ctx.String("since"))
 	if err != nil {
-		return fmt.Errorf("get absolute target path: %w", err)
+		return err
 	}
 
-	args := []string{types.LogScript, "bxhNet", typ, path, strconv.Itoa(n), strconv.Itoa(num)}
-	if err := utils.ExecuteShell(args, repoRoot); err != nil {
+	filter, err := logs.NewFilter(ctx.String("level"), append(modules, ctx.StringSlice("module")...), ctx.String("grep"), since)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	width := 0
+	prefixes := make(map[string]string, len(sources))
+	for _, source := range sources {
+		if len(source.Name()) > width {
+			width = len(source.Name())
+		}
+	}
+	for i, source := range sources {
+		prefixes[source.Name()] = logColors[i%len(logColors)].Sprintf("%-*s |", width, source.Name())
+	}
+
+	return logs.Stream(c, sources, logs.Options{
+		Tail:   ctx.Int("n"),
+		Follow: ctx.Bool("follow"),
+		Filter: filter,
+	}, func(entry *logs.Entry) {
+		if len(sources) == 1 {
+			fmt.Println(entry.Line)
+			return
+		}
+		fmt.Println(prefixes[entry.Source], entry.Line)
+	})
 }
 
-func bxhOrder(ctx *cli.Context) error {
-	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+// parseSince parses a duration relative to now or an absolute time
+func parseSince(since string) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(since); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, since, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid since %s, it should be a duration like 10m or a time like 2021-09-22T11:16:02", since)
+}
+
+// bxhFileSources returns the log of the node repo, or the logs of all nodes
+// in the target of the started bitxhub if nodeRepo is empty.
+func bxhFileSources(repoRoot, nodeRepo string) ([]logs.Source, error) {
+	if nodeRepo != "" {
+		path, err := filepath.Abs(nodeRepo)
+		if err != nil {
+			return nil, fmt.Errorf("get absolute target path: %w", err)
+		}
+		return []logs.Source{logs.NewFileSource(filepath.Base(path), logPath(path, repo.BitXHubConfigName, "bitxhub.log"))}, nil
+	}
+
+	dirs, err := bxhNodeRepos(repoRoot)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if !fileutil.Exist(repoRoot) {
-		return fmt.Errorf("please `goduck init` first")
+
+	var sources []logs.Source
+	for _, dir := range dirs {
+		sources = append(sources, logs.NewFileSource(filepath.Base(dir), logPath(dir, repo.BitXHubConfigName, "bitxhub.log")))
 	}
 
-	typ := ctx.String("type")
-	target := ctx.String("bxh-repo")
-	num := ctx.Int("num")
-	n := ctx.Int("n")
+	return sources, nil
+}
 
-	if typ == types.TypeBinary && target == "" {
-		return fmt.Errorf("repo can not be nil")
+// bxhNodeRepos returns the repos of the nodes in the target of the started bitxhub
+func bxhNodeRepos(repoRoot string) ([]string, error) {
+	target := filepath.Join(repoRoot, "bitxhub", ".bitxhub")
+	if sup, err := bitxhub.NewSupervisor(repoRoot); err == nil && sup.State().Target != "" {
+		target = sup.State().Target
 	}
 
-	path, err := filepath.Abs(target)
+	dirs, err := subDirs(target, "node")
 	if err != nil {
-		return fmt.Errorf("get absolute target path: %w", err)
+		return nil, fmt.Errorf("list bitxhub nodes in %s: %w", target, err)
 	}
 
-	args := []string{types.LogScript, "bxhOrder", typ, path, strconv.Itoa(n), strconv.Itoa(num)}
-	if err := utils.ExecuteShell(args, repoRoot); err != nil {
-		return err
+	return dirs, nil
+}
+
+// pierFileSources returns the log of the pier repo, or the logs of all piers in $repo/pier
+func pierFileSources(repoRoot, pierRepo string) ([]logs.Source, error) {
+	if pierRepo != "" {
+		path, err := filepath.Abs(pierRepo)
+		if err != nil {
+			return nil, fmt.Errorf("get absolute target path: %w", err)
+		}
+		return []logs.Source{logs.NewFileSource(repoName(path, "pier"), logPath(path, repo.PierConfigName, "pier.log"))}, nil
 	}
 
-	return nil
+	dirs, err := pierRepos(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []logs.Source
+	for _, dir := range dirs {
+		sources = append(sources, logs.NewFileSource(repoName(dir, "pier"), logPath(dir, repo.PierConfigName, "pier.log")))
+	}
+
+	return sources, nil
 }
 
-func pierLog(ctx *cli.Context) error {
-	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+// pierRepos returns the repos of the piers in $repo/pier
+func pierRepos(repoRoot string) ([]string, error) {
+	dirs, err := subDirs(filepath.Join(repoRoot, "pier"), ".pier")
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("list piers: %w", err)
 	}
-	if !fileutil.Exist(repoRoot) {
-		return fmt.Errorf("please `goduck init` first")
+
+	return dirs, nil
+}
+
+// logPath returns the log file configured by the [log] section of the config,
+// or logs/<defaultName> if it is not configured.
+func logPath(nodeRepo, configName, defaultName string) string {
+	dir, filename := "logs", defaultName
+	if tree, err := toml.LoadFile(filepath.Join(nodeRepo, configName)); err == nil {
+		if d, ok := tree.Get("log.dir").(string); ok && d != "" {
+			dir = d
+		}
+		if f, ok := tree.Get("log.filename").(string); ok && f != "" {
+			filename = f
+		}
 	}
 
-	typ := ctx.String("type")
-	target := ctx.String("pier-repo")
-	num := ctx.Int("num")
-	appchain := ctx.String("appchain")
-	n := ctx.Int("n")
+	if filepath.IsAbs(dir) {
+		return filepath.Join(dir, filename)
+	}
+	return filepath.Join(nodeRepo, dir, filename)
+}
+
+func subDirs(dir, prefix string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
 
-	if typ == types.TypeBinary && target == "" {
-		return fmt.Errorf("repo can not be nil")
+	var dirs []string
+	for _, info := range infos {
+		if info.IsDir() && strings.HasPrefix(info.Name(), prefix) {
+			dirs = append(dirs, filepath.Join(dir, info.Name()))
+		}
 	}
 
-	path, err := filepath.Abs(target)
+	return dirs, nil
+}
+
+// dockerSources returns the logs of the containers of the component started by goduck,
+// only the num-th one is returned if byNum is true.
+func dockerSources(ctx context.Context, component, appchain string, byNum bool, num int) ([]logs.Source, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv)
 	if err != nil {
-		return fmt.Errorf("get absolute target path: %w", err)
+		return nil, err
 	}
 
-	args := []string{types.LogScript, "pierLog", typ, path, strconv.Itoa(n), strconv.Itoa(num), appchain}
-	if err := utils.ExecuteShell(args, repoRoot); err != nil {
-		return err
+	containers, err := cli.ContainerList(ctx, dockertypes.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", fmt.Sprintf("%s=%s", ComponentLabel, component))),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list containers: %w", err)
+	}
+
+	var sources []logs.Source
+	for _, container := range containers {
+		name := container.ID[:12]
+		if len(container.Names) != 0 {
+			name = strings.TrimPrefix(container.Names[0], "/")
+		}
+		if appchain != "" && !strings.HasPrefix(name, "pier-"+appchain) {
+			continue
+		}
+		sources = append(sources, logs.NewDockerSource(cli, name, container.ID))
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Name() < sources[j].Name()
+	})
+
+	if byNum {
+		if num < 1 || num > len(sources) {
+			return nil, fmt.Errorf("there are %d %s containers, num %d is out of range", len(sources), component, num)
+		}
+		sources = sources[num-1 : num]
 	}
 
-	return nil
+	return sources, nil
 }
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+func checkOutputFormat(format string) error {
+	switch format {
+	case OutputTable, OutputJSON, OutputYAML:
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %s, one of table, json or yaml", format)
+	}
+}
+
+// printOutput prints v in the format specified by the global --output flag,
+// table is the header and rows used by the table format.
+func printOutput(ctx *cli.Context, v interface{}, table [][]string) error {
+	switch ctx.String("output") {
+	case OutputJSON:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal json: %w", err)
+		}
+		fmt.Println(string(data))
+	case OutputYAML:
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("marshal yaml: %w", err)
+		}
+		fmt.Print(string(data))
+	default:
+		PrintTable(table, true)
+	}
+
+	return nil
+}
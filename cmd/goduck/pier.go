@@ -1,9 +1,7 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -17,19 +15,6 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
-var pierConfigMap = map[string]string{
-	"v1.6.1":  "v1.6.1",
-	"v1.6.5":  "v1.6.5", // v1.6.1
-	"v1.7.0":  "v1.7.0", // v1.6.1
-	"v1.8.0":  "v1.8.0",
-	"v1.9.0":  "v1.9.0", // v1.8.0
-	"v1.11.0": "v1.11.0",
-	"v1.11.1": "v1.11.0",
-	"v1.11.3": "v1.11.3",
-	"v1.23.0": "v1.23.0",
-	"v2.8.0":  "v2.8.0",
-}
-
 var pierCMD = &cli.Command{
 	Name:  "pier",
 	Usage: "Operation about pier",
@@ -40,7 +25,7 @@ var pierCMD = &cli.Command{
 			Flags: []cli.Flag{
 				&cli.StringFlag{
 					Name:  "appchain",
-					Usage: "Specify appchain type, one of ethereum or fabric",
+					Usage: "Specify appchain type, one of ethereum, fabric, hyperchain or custom",
 					Value: types.ChainTypeEther,
 				},
 				&cli.StringFlag{
@@ -61,6 +46,9 @@ var pierCMD = &cli.Command{
 					Value:   "v2.8.0",
 					Usage:   "Pier version",
 				},
+				basePortFlag(),
+				pluginFlag(),
+				pluginConfigFlag(),
 			},
 			Action: pierStart,
 		},
@@ -70,7 +58,7 @@ var pierCMD = &cli.Command{
 			Flags: []cli.Flag{
 				&cli.StringFlag{
 					Name:  "appchain",
-					Usage: "Specify appchain type, one of ethereum or fabric",
+					Usage: "Specify appchain type, one of ethereum, fabric, hyperchain or custom",
 					Value: types.ChainTypeEther,
 				},
 				&cli.StringFlag{
@@ -105,7 +93,7 @@ var pierCMD = &cli.Command{
 			Flags: []cli.Flag{
 				&cli.StringFlag{
 					Name:  "appchain",
-					Usage: "Specify appchain type, one of ethereum or fabric",
+					Usage: "Specify appchain type, one of ethereum, fabric, hyperchain or custom",
 					Value: types.ChainTypeEther,
 				},
 				&cli.StringFlag{
@@ -144,7 +132,7 @@ var pierCMD = &cli.Command{
 			Flags: []cli.Flag{
 				&cli.StringFlag{
 					Name:  "appchain",
-					Usage: "Specify appchain type, one of ethereum or fabric",
+					Usage: "Specify appchain type, one of ethereum, fabric, hyperchain or custom",
 					Value: types.ChainTypeEther,
 				},
 			},
@@ -156,7 +144,7 @@ var pierCMD = &cli.Command{
 			Flags: []cli.Flag{
 				&cli.StringFlag{
 					Name:  "appchain",
-					Usage: "Specify appchain type, one of ethereum or fabric",
+					Usage: "Specify appchain type, one of ethereum, fabric, hyperchain or custom",
 					Value: types.ChainTypeEther,
 				},
 			},
@@ -168,7 +156,7 @@ var pierCMD = &cli.Command{
 			Flags: []cli.Flag{
 				&cli.StringFlag{
 					Name:  "appchain",
-					Usage: "Specify appchain type, one of ethereum or fabric",
+					Usage: "Specify appchain type, one of ethereum, fabric, hyperchain or custom",
 					Value: types.ChainTypeEther,
 				},
 				&cli.StringFlag{
@@ -189,6 +177,13 @@ var pierCMD = &cli.Command{
 					Value:   "v1.6.5",
 					Usage:   "Pier version",
 				},
+				&cli.BoolFlag{
+					Name:  "remote",
+					Usage: "Generate the configuration for a pier deployed to another host, whose keys are generated on that host instead of by the local pier binary",
+				},
+				basePortFlag(),
+				pluginFlag(),
+				pluginConfigFlag(),
 			},
 			Action: generatePierConfig,
 		},
@@ -210,27 +205,21 @@ func pierStart(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, types.ReleaseJson))
+	return startPier(repoRoot, chainType, target, upType, configPath, version, ctx.StringSlice("base-port"), ctx.String("plugin"), ctx.String("plugin-config"))
+}
+
+func startPier(repoRoot, chainType, target, upType, configPath, version string, basePorts []string, plugin, pluginConfig string) error {
+	r, err := pierRelease(repoRoot, version)
 	if err != nil {
 		return err
 	}
-
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
+	if err := checkPierUpType(chainType, upType); err != nil {
 		return err
 	}
 
-	if !AdjustVersion(version, release.Pier) {
-		return fmt.Errorf("unsupport pier verison")
-	}
-
-	if target == "" {
-		target = filepath.Join(repoRoot, fmt.Sprintf("pier/.pier_%s", chainType))
-	} else {
-		target, err = filepath.Abs(target)
-		if err != nil {
-			return fmt.Errorf("get absolute pier repo path: %w", err)
-		}
+	target, err = pierTarget(repoRoot, chainType, target)
+	if err != nil {
+		return err
 	}
 
 	if upType == types.TypeBinary && !fileutil.Exist(target) {
@@ -240,7 +229,7 @@ func pierStart(ctx *cli.Context) error {
 	}
 
 	if configPath == "" {
-		configPath = filepath.Join(repoRoot, fmt.Sprintf("%s/%s/%s", types.PierConfigRepo, pierConfigMap[version], types.PierModifyConfig))
+		configPath = filepath.Join(repoRoot, fmt.Sprintf("%s/%s/%s", types.PierConfigRepo, r.Config, types.PierModifyConfig))
 	} else {
 		configPath, err = filepath.Abs(configPath)
 		if err != nil {
@@ -248,18 +237,26 @@ func pierStart(ctx *cli.Context) error {
 		}
 	}
 
-	if err := pier.DownloadPierBinary(repoRoot, version, runtime.GOOS); err != nil {
-		return fmt.Errorf("download pier binary error:%w", err)
+	// the pier config is generated from the allocated ports, so are the ports
+	// published by the docker pier
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
 	}
-	pluginSys := runtime.GOOS
-	if upType == types.TypeDocker {
-		pluginSys = types.LinuxSystem
+	configPath, err = allocatePierPorts(repoRoot, target, configPath, basePorts)
+	if err != nil {
+		return fmt.Errorf("allocate ports: %w", err)
+	}
+
+	binPath, err := pierBinary(repoRoot, version)
+	if err != nil {
+		return err
 	}
-	if err := pier.DownloadPierPlugin(repoRoot, chainType, version, pluginSys); err != nil {
-		return fmt.Errorf("download pier binary error:%w", err)
+	plugin, pluginConfig, err = pierPlugin(repoRoot, chainType, version, upType, plugin, pluginConfig)
+	if err != nil {
+		return err
 	}
 
-	return pier.StartPier(repoRoot, chainType, target, upType, configPath, version)
+	return pier.StartPier(repoRoot, chainType, target, upType, configPath, version, binPath, plugin, pluginConfig)
 }
 
 func pierRegister(ctx *cli.Context) error {
@@ -278,27 +275,24 @@ func pierRegister(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, "release.json"))
-	if err != nil {
+	return registerPier(repoRoot, chainType, target, upType, method, version, cid)
+}
+
+func registerPier(repoRoot, chainType, target, upType, method, version, cid string) error {
+	if _, err := pierRelease(repoRoot, version); err != nil {
 		return err
 	}
-
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
+	appchain, err := GetPierAppchain(chainType)
+	if err != nil {
 		return err
 	}
-
-	if !AdjustVersion(version, release.Pier) {
-		return fmt.Errorf("unsupport pier verison")
+	if err := checkPierUpType(chainType, upType); err != nil {
+		return err
 	}
 
-	if target == "" {
-		target = filepath.Join(repoRoot, fmt.Sprintf("pier/.pier_%s", chainType))
-	} else {
-		target, err = filepath.Abs(target)
-		if err != nil {
-			return fmt.Errorf("get absolute pier repo path: %w", err)
-		}
+	target, err = pierTarget(repoRoot, chainType, target)
+	if err != nil {
+		return err
 	}
 
 	if upType == types.TypeBinary && !fileutil.Exist(target) {
@@ -309,15 +303,15 @@ func pierRegister(ctx *cli.Context) error {
 		return fmt.Errorf("Docker mode needs to specify CID (you can find it by using the conmand `goduck status list`)")
 	}
 
+	binPath := repo.GetLocalBinPath(repoRoot, "pier", version)
 	if upType == types.TypeBinary {
-		if err := pier.DownloadPierBinary(repoRoot, version, runtime.GOOS); err != nil {
-			return fmt.Errorf("download pier binary error:%w", err)
+		if binPath, err = pierBinary(repoRoot, version); err != nil {
+			return err
 		}
-		binPath := filepath.Join(repoRoot, fmt.Sprintf("bin/%s", fmt.Sprintf("pier_%s_%s", runtime.GOOS, version)))
 		color.Blue("pier binary path: %s", binPath)
 	}
 
-	return pier.RegisterPier(repoRoot, target, chainType, upType, method, version, cid)
+	return pier.RegisterPier(repoRoot, target, chainType, upType, method, version, cid, binPath, appchain.Register)
 	//return pier.RegisterPier(repoRoot, chainType, cryptoPath, pierUpType, version, tls, http, pport, aport, overwrite, appchainIP, appchainAddr, appchainPorts, appchainContractAddr, pierRepo, adminKey, method)
 }
 
@@ -338,27 +332,20 @@ func pierRuleDeploy(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, "release.json"))
-	if err != nil {
-		return err
-	}
+	return deployPierRule(repoRoot, chainType, target, ruleRepo, upType, method, version, cid)
+}
 
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
+func deployPierRule(repoRoot, chainType, target, ruleRepo, upType, method, version, cid string) error {
+	if _, err := pierRelease(repoRoot, version); err != nil {
 		return err
 	}
-
-	if !AdjustVersion(version, release.Pier) {
-		return fmt.Errorf("unsupport pier verison")
+	if err := checkPierUpType(chainType, upType); err != nil {
+		return err
 	}
 
-	if target == "" {
-		target = filepath.Join(repoRoot, fmt.Sprintf("pier/.pier_%s", chainType))
-	} else {
-		target, err = filepath.Abs(target)
-		if err != nil {
-			return fmt.Errorf("get absolute pier repo path: %w", err)
-		}
+	target, err := pierTarget(repoRoot, chainType, target)
+	if err != nil {
+		return err
 	}
 
 	if upType == types.TypeBinary && !fileutil.Exist(target) {
@@ -372,8 +359,92 @@ func pierRuleDeploy(ctx *cli.Context) error {
 	if ruleRepo == "" {
 		ruleRepo = filepath.Join(repoRoot, fmt.Sprintf("pier/.pier_%s/%s/validating.wasm", chainType, chainType))
 	}
+	if upType == types.TypeBinary && !fileutil.Exist(ruleRepo) {
+		return fmt.Errorf("the rule(%s) does not exist, please specify it with --ruleRepo", ruleRepo)
+	}
 
-	return pier.DeployRule(repoRoot, chainType, target, ruleRepo, upType, method, version, cid)
+	return pier.DeployRule(repoRoot, chainType, target, ruleRepo, upType, method, version, cid, repo.GetLocalBinPath(repoRoot, "pier", version))
+}
+
+// checkPierUpType checks the pier of the appchain can be started in the type,
+// only some appchains have their pier images
+func checkPierUpType(chainType, upType string) error {
+	appchain, err := GetPierAppchain(chainType)
+	if err != nil {
+		return err
+	}
+	if !appchain.Docker && upType != types.TypeBinary {
+		return fmt.Errorf("the pier of %s only supports binary", chainType)
+	}
+
+	return nil
+}
+
+func pluginFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "plugin",
+		Usage: "Specify the appchain plugin binary instead of the downloaded one, which is required for custom appchain",
+	}
+}
+
+func pluginConfigFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "plugin-config",
+		Usage: "Specify the directory of the appchain plugin config instead of the built-in one, which is required for custom appchain and has to contain the validators",
+	}
+}
+
+// pierPlugin returns the appchain plugin and the directory of its config
+// templates, the built-in ones are used unless they are given
+func pierPlugin(repoRoot, chainType, version, upType, plugin, pluginConfig string) (string, string, error) {
+	appchain, err := GetPierAppchain(chainType)
+	if err != nil {
+		return "", "", err
+	}
+	if appchain.Plugin == "" && (plugin == "" || pluginConfig == "") {
+		return "", "", fmt.Errorf("the plugin and the plugin config of %s appchain are needed, specify them with --plugin and --plugin-config", chainType)
+	}
+
+	if plugin == "" {
+		pluginSys := runtime.GOOS
+		if upType == types.TypeDocker {
+			pluginSys = types.LinuxSystem
+		}
+		if err := pier.DownloadPierPlugin(repoRoot, chainType, version, pluginSys, runtime.GOARCH); err != nil {
+			return "", "", fmt.Errorf("download pier binary error:%w", err)
+		}
+		plugin = filepath.Join(repo.GetBinPath(repoRoot, "pier", pluginSys, runtime.GOARCH, version), appchain.Plugin)
+	} else if plugin, err = filepath.Abs(plugin); err != nil {
+		return "", "", fmt.Errorf("get absolute plugin path: %w", err)
+	}
+
+	if pluginConfig == "" {
+		pluginConfig = filepath.Join(repoRoot, fmt.Sprintf("pier/%s", chainType))
+		if chainType == types.ChainTypeEther {
+			r, err := pierRelease(repoRoot, version)
+			if err != nil {
+				return "", "", err
+			}
+			pluginConfig = filepath.Join(repoRoot, fmt.Sprintf("pier/%s/%s", chainType, r.EthereumContract))
+		}
+	} else if pluginConfig, err = filepath.Abs(pluginConfig); err != nil {
+		return "", "", fmt.Errorf("get absolute plugin config path: %w", err)
+	}
+
+	return plugin, pluginConfig, nil
+}
+
+func pierTarget(repoRoot, chainType, target string) (string, error) {
+	if target == "" {
+		return filepath.Join(repoRoot, fmt.Sprintf("pier/.pier_%s", chainType)), nil
+	}
+
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return "", fmt.Errorf("get absolute pier repo path: %w", err)
+	}
+
+	return target, nil
 }
 
 func pierStop(ctx *cli.Context) error {
@@ -401,7 +472,11 @@ func pierClean(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	return pier.CleanPier(repoRoot, chainType)
+	if err := pier.CleanPier(repoRoot, chainType); err != nil {
+		return err
+	}
+
+	return releasePorts(repoRoot, filepath.Join(repoRoot, fmt.Sprintf("pier/.pier_%s", chainType)))
 }
 
 func generatePierConfig(ctx *cli.Context) error {
@@ -419,20 +494,11 @@ func generatePierConfig(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, "release.json"))
+	r, err := pierRelease(repoRoot, version)
 	if err != nil {
 		return err
 	}
 
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
-		return err
-	}
-
-	if !AdjustVersion(version, release.Pier) {
-		return fmt.Errorf("unsupport Pier verison")
-	}
-
 	if target == "" {
 		target = filepath.Join(repoRoot, fmt.Sprintf("pier/.pier_%s", chainType))
 	} else {
@@ -449,28 +515,32 @@ func generatePierConfig(ctx *cli.Context) error {
 	}
 
 	if configPath == "" {
-		configPath = filepath.Join(repoRoot, fmt.Sprintf("%s/%s/%s", types.PierConfigRepo, pierConfigMap[version], types.PierModifyConfig))
+		configPath = filepath.Join(repoRoot, fmt.Sprintf("%s/%s/%s", types.PierConfigRepo, r.Config, types.PierModifyConfig))
 	}
-
-	if err := pier.DownloadPierBinary(repoRoot, version, runtime.GOOS); err != nil {
-		return fmt.Errorf("download pier binary error:%w", err)
+	configPath, err = allocatePierPorts(repoRoot, target, configPath, ctx.StringSlice("base-port"))
+	if err != nil {
+		return fmt.Errorf("allocate ports: %w", err)
 	}
-	pluginSys := runtime.GOOS
-	if upType == types.TypeDocker {
-		pluginSys = types.LinuxSystem
+
+	// the pier binary of the remote host may not run locally
+	binPath := ""
+	if !ctx.Bool("remote") {
+		if binPath, err = pierBinary(repoRoot, version); err != nil {
+			return err
+		}
+		color.Blue("pier binary path: %s", binPath)
 	}
-	if err := pier.DownloadPierPlugin(repoRoot, chainType, version, pluginSys); err != nil {
-		return fmt.Errorf("download pier binary error:%w", err)
+	pluginPath, appchainConfigPath, err := pierPlugin(repoRoot, chainType, version, upType, ctx.String("plugin"), ctx.String("plugin-config"))
+	if err != nil {
+		return err
 	}
-	binPath := filepath.Join(repoRoot, fmt.Sprintf("bin/%s", fmt.Sprintf("pier_%s_%s", runtime.GOOS, version)))
-	pluginPath := filepath.Join(repoRoot, fmt.Sprintf("bin/%s", fmt.Sprintf("pier_%s_%s", pluginSys, version)))
-	color.Blue("pier binary path: %s", binPath)
-	appchainConfigPath := filepath.Join(repoRoot, fmt.Sprintf("pier/%s", chainType))
-	if chainType == types.ChainTypeEther {
-		appchainConfigPath = filepath.Join(repoRoot, fmt.Sprintf("pier/%s/%s", chainType, EthConfigMap[version]))
+
+	opts, err := pierConfigOptions(configPath, target, version, chainType, appchainConfigPath, binPath, pluginPath)
+	if err != nil {
+		return err
 	}
 
-	return pier.GeneratePier(filepath.Join(repoRoot, types.PierConfigRepo, pierConfigMap[version], types.PierConfigScript), repoRoot, target, configPath, chainType, binPath, pluginPath, appchainConfigPath)
+	return InitPierConfig(opts)
 }
 
 // TODO: delete
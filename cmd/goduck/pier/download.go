@@ -8,12 +8,22 @@ import (
 	"github.com/codeskyblue/go-sh"
 	"github.com/meshplus/bitxhub-kit/fileutil"
 	"github.com/meshplus/goduck/internal/download"
+	"github.com/meshplus/goduck/internal/release"
+	"github.com/meshplus/goduck/internal/repo"
 	"github.com/meshplus/goduck/internal/types"
 )
 
-func DownloadPierBinary(repoPath string, version string, system string) error {
-	path := fmt.Sprintf("pier_%s_%s", system, version)
-	root := filepath.Join(repoPath, "bin", path)
+func DownloadPierBinary(repoPath string, version string, system string, arch string) error {
+	r, checksums, err := pierRelease(repoPath, version)
+	if err != nil {
+		return err
+	}
+	artifact, err := r.Artifact(system, arch)
+	if err != nil {
+		return err
+	}
+
+	root := repo.GetBinPath(repoPath, "pier", system, arch, version)
 	if !fileutil.Exist(root) {
 		err := os.MkdirAll(root, 0755)
 		if err != nil {
@@ -21,39 +31,40 @@ func DownloadPierBinary(repoPath string, version string, system string) error {
 		}
 	}
 
-	if !fileutil.Exist(filepath.Join(root, "pier")) {
-		if system == "linux" {
-			url := fmt.Sprintf(types.PierUrlLinux, version, version)
-			err := download.Download(root, url)
-			if err != nil {
-				return err
-			}
-
-			err = sh.Command("/bin/bash", "-c", fmt.Sprintf("cd %s && tar xf pier_linux-amd64_%s.tar.gz  && export LD_LIBRARY_PATH=$LD_LIBRARY_PATH:%s", root, version, root)).Run()
-			if err != nil {
-				return fmt.Errorf("extract pier binary: %s", err)
-			}
+	// the binary may be extracted from a truncated tarball left by an
+	// interrupted download, so it is trusted only if the tarball is complete
+	tarPath := filepath.Join(root, artifact.Name)
+	if !fileutil.Exist(filepath.Join(root, "pier")) || !checksums.Complete(tarPath) {
+		url := fmt.Sprintf(types.PierReleaseUrl, version, artifact.Name)
+		err := download.Download(tarPath, url, download.WithManifest(checksums))
+		if err != nil {
+			return err
 		}
-		if system == "darwin" {
-			url := fmt.Sprintf(types.PierUrlMacOS, version, version)
-			err := download.Download(root, url)
-			if err != nil {
-				return err
-			}
 
-			err = sh.Command("/bin/bash", "-c", fmt.Sprintf("cd %s && tar xf pier_darwin_x86_64_%s.tar.gz  && install_name_tool -change @rpath/libwasmer.dylib %s/libwasmer.dylib %s/pier", root, version, root, root)).Run()
-			if err != nil {
-				return fmt.Errorf("extract pier binary: %s", err)
-			}
+		extract := fmt.Sprintf("cd %s && tar xf %s  && export LD_LIBRARY_PATH=$LD_LIBRARY_PATH:%s", root, artifact.Name, root)
+		if system == types.DarwinSystem {
+			extract = fmt.Sprintf("cd %s && tar xf %s  && install_name_tool -change @rpath/libwasmer.dylib %s/libwasmer.dylib %s/pier", root, artifact.Name, root, root)
+		}
+		err = sh.Command("/bin/bash", "-c", extract).Run()
+		if err != nil {
+			return fmt.Errorf("extract pier binary: %s", err)
 		}
 	}
 
 	return nil
 }
 
-func DownloadPierPlugin(repoPath string, chain string, version string, system string) error {
-	path := fmt.Sprintf("pier_%s_%s", system, version)
-	root := filepath.Join(repoPath, "bin", path)
+func DownloadPierPlugin(repoPath string, chain string, version string, system string, arch string) error {
+	r, checksums, err := pierRelease(repoPath, version)
+	if err != nil {
+		return err
+	}
+	artifact, err := r.Plugin(chain, system, arch)
+	if err != nil {
+		return err
+	}
+
+	root := repo.GetBinPath(repoPath, "pier", system, arch, version)
 	pluginName := fmt.Sprintf(types.PierPlugin, chain)
 	if !fileutil.Exist(root) {
 		err := os.MkdirAll(root, 0755)
@@ -61,63 +72,38 @@ func DownloadPierPlugin(repoPath string, chain string, version string, system st
 			return err
 		}
 	}
-	if !fileutil.Exist(filepath.Join(root, pluginName)) {
-		if system == "linux" {
-			switch chain {
-			case types.ChainTypeFabric:
-				url := fmt.Sprintf(types.PierFabricClientUrlLinux, version, version)
-				err := download.Download(root, url)
-				if err != nil {
-					return err
-				}
-
-				err = sh.Command("/bin/bash", "-c", fmt.Sprintf("cd %s && mv fabric-client-%s-Linux %s && chmod +x %s", root, version, pluginName, pluginName)).Run()
-				if err != nil {
-					return fmt.Errorf("rename fabric client error: %s", err)
-				}
-			case types.ChainTypeEther:
-				url := fmt.Sprintf(types.PierEthereumClientUrlLinux, version, version)
-				err := download.Download(root, url)
-				if err != nil {
-					return err
-				}
-
-				err = sh.Command("/bin/bash", "-c", fmt.Sprintf("cd %s && mv eth-client-%s-Linux %s && chmod +x %s", root, version, pluginName, pluginName)).Run()
-				if err != nil {
-					return fmt.Errorf("rename eth client error: %s", err)
-				}
-
-			}
 
+	if !fileutil.Exist(filepath.Join(root, pluginName)) {
+		url := fmt.Sprintf(types.PierPluginReleaseUrl, chain, r.Plugins[chain], artifact.Name)
+		err := download.Download(root, url, download.WithManifest(checksums))
+		if err != nil {
+			return err
 		}
-		if system == "darwin" {
-			switch chain {
-			case types.ChainTypeFabric:
-				url := fmt.Sprintf(types.PierFabricClientUrlMacOS, version, version)
-				err := download.Download(root, url)
-				if err != nil {
-					return err
-				}
 
-				err = sh.Command("/bin/bash", "-c", fmt.Sprintf("cd %s && mv fabric-client-%s-Darwin %s && chmod +x %s", root, version, pluginName, pluginName)).Run()
-				if err != nil {
-					return fmt.Errorf("rename fabric client error: %s", err)
-				}
-
-			case types.ChainTypeEther:
-				url := fmt.Sprintf(types.PierEthereumClientUrlMacOS, version, version)
-				err := download.Download(root, url)
-				if err != nil {
-					return err
-				}
-
-				err = sh.Command("/bin/bash", "-c", fmt.Sprintf("cd %s && mv eth-client-%s-Darwin %s && chmod +x %s", root, version, pluginName, pluginName)).Run()
-				if err != nil {
-					return fmt.Errorf("rename eth client error: %s", err)
-				}
-			}
+		err = sh.Command("/bin/bash", "-c", fmt.Sprintf("cd %s && mv %s %s && chmod +x %s", root, artifact.Name, pluginName, pluginName)).Run()
+		if err != nil {
+			return fmt.Errorf("rename %s client error: %s", chain, err)
 		}
 	}
 
 	return nil
 }
+
+// pierRelease returns the pier release in the release manifest and the
+// checksums to verify its artifacts.
+func pierRelease(repoPath string, version string) (*release.Pier, download.Manifest, error) {
+	m, err := release.Load(repoPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := m.FindPier(version)
+	if err != nil {
+		return nil, nil, err
+	}
+	checksums, err := m.Checksums(repoPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r, checksums, nil
+}
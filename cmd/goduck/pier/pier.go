@@ -5,23 +5,31 @@ import (
 	"github.com/meshplus/goduck/internal/utils"
 )
 
-func GeneratePier(scriptPath, repoRoot, pierRepo, configPath, appchainType, pierBinPath, pluginPath, appchainConfigPath string) error {
-	args := []string{scriptPath, "-a", appchainType, "-p", pierRepo, "-c", configPath, "-b", pierBinPath, "-g", pluginPath, "-f", appchainConfigPath}
-	return utils.ExecuteShell(args, repoRoot)
+// AppchainInfo is the appchain registered to BitXHub by the pier
+type AppchainInfo struct {
+	Name    string
+	Type    string
+	Version string
+	// Validators is the path of the validators in the pier repo
+	Validators string
 }
 
-func StartPier(repoRoot, appchainType, pierRepo, upType, configPath, version string) error {
-	args := []string{types.PierScript, "up", "-a", appchainType, "-p", pierRepo, "-u", upType, "-c", configPath, "-v", version}
+// StartPier starts the pier with the appchain plugin and the directory of
+// its config templates
+func StartPier(repoRoot, appchainType, pierRepo, upType, configPath, version, binPath, plugin, pluginConfig string) error {
+	args := []string{types.PierScript, "up", "-a", appchainType, "-p", pierRepo, "-u", upType, "-c", configPath, "-v", version, "-d", binPath,
+		"-g", plugin, "-f", pluginConfig}
 	return utils.ExecuteShell(args, repoRoot)
 }
 
-func RegisterPier(repoRoot, pierRepo, appchainType, upType, method, version, cid string) error {
-	args := []string{types.PierScript, "register", "-a", appchainType, "-p", pierRepo, "-u", upType, "-m", method, "-v", version, "-i", cid}
+func RegisterPier(repoRoot, pierRepo, appchainType, upType, method, version, cid, binPath string, appchain AppchainInfo) error {
+	args := []string{types.PierScript, "register", "-a", appchainType, "-p", pierRepo, "-u", upType, "-m", method, "-v", version, "-i", cid, "-d", binPath,
+		"-n", appchain.Name, "-t", appchain.Type, "-e", appchain.Version, "-l", appchain.Validators}
 	return utils.ExecuteShell(args, repoRoot)
 }
 
-func DeployRule(repoRoot, appchainType, pierRepo, ruleRepo, upType, method, version, cid string) error {
-	args := []string{types.PierScript, "rule", "-a", appchainType, "-p", pierRepo, "-r", ruleRepo, "-u", upType, "-m", method, "-v", version, "-i", cid}
+func DeployRule(repoRoot, appchainType, pierRepo, ruleRepo, upType, method, version, cid, binPath string) error {
+	args := []string{types.PierScript, "rule", "-a", appchainType, "-p", pierRepo, "-r", ruleRepo, "-u", upType, "-m", method, "-v", version, "-i", cid, "-d", binPath}
 	return utils.ExecuteShell(args, repoRoot)
 }
 
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/cmd/goduck/pier"
+	"github.com/meshplus/goduck/internal/types"
+)
+
+// PierAppchain describes how a pier is wired to an appchain by its plugin.
+// The plugin config is the directory named after the appchain in the pier
+// repo, which is copied from the appchain config path.
+type PierAppchain struct {
+	// Plugin is the file name of the plugin downloaded to the plugin path,
+	// the custom appchain brings its own plugin
+	Plugin string
+	// Templates are the files rendered in the plugin config, the others are
+	// copied as they are
+	Templates []string
+	// Docker means there is a pier image of the appchain
+	Docker bool
+	// Register is the appchain registered to BitXHub
+	Register pier.AppchainInfo
+
+	// configData fills the templates with the options
+	configData func(opts *PierConfigOptions) (*appchainConfigData, error)
+	// configPaths are the directories copied to the plugin config besides
+	// the appchain config path
+	configPaths func(opts *PierConfigOptions) []string
+	// validate checks the options of the appchain
+	validate func(opts *PierConfigOptions) error
+}
+
+var pierAppchains = map[string]*PierAppchain{
+	types.ChainTypeEther: {
+		Plugin:    fmt.Sprintf(types.PierPlugin, types.ChainTypeEther),
+		Templates: []string{"ethereum.toml"},
+		Docker:    true,
+		Register: pier.AppchainInfo{
+			Name:       "chainB",
+			Type:       "ether",
+			Version:    "1.9.13",
+			Validators: "ethereum/ether.validators",
+		},
+		configData: func(opts *PierConfigOptions) (*appchainConfigData, error) {
+			return &appchainConfigData{
+				AppchainAddr:         opts.Ethereum.Addr,
+				AppchainContractAddr: opts.Ethereum.ContractAddr,
+			}, nil
+		},
+		validate: func(opts *PierConfigOptions) error {
+			if opts.Ethereum.Addr == "" {
+				return fmt.Errorf("ethereum address is needed")
+			}
+			return nil
+		},
+	},
+	types.ChainTypeFabric: {
+		Plugin:    fmt.Sprintf(types.PierPlugin, types.ChainTypeFabric),
+		Templates: []string{"fabric.toml", types.FabricConfig},
+		Docker:    true,
+		Register: pier.AppchainInfo{
+			Name:       "chainA",
+			Type:       "fabric",
+			Version:    "1.4.3",
+			Validators: "fabric/fabric.validators",
+		},
+		configData: func(opts *PierConfigOptions) (*appchainConfigData, error) {
+			ports := opts.Fabric.Ports
+			data := &appchainConfigData{
+				AppchainAddr: fmt.Sprintf("%s:%s", opts.Fabric.IP, ports[2]),
+				ConfigPath:   opts.Fabric.CryptoPath,
+				AppchainIP:   opts.Fabric.IP,
+			}
+			data.Port1, data.Port2, data.Port3 = ports[0], ports[1], ports[2]
+			data.Port4, data.Port5, data.Port6 = ports[3], ports[4], ports[5]
+			data.Port7, data.Port8, data.Port9 = ports[6], ports[7], ports[8]
+			return data, nil
+		},
+		validate: func(opts *PierConfigOptions) error {
+			if len(opts.Fabric.Ports) != 9 {
+				return fmt.Errorf("the number of fabric ports is not 9: %d", len(opts.Fabric.Ports))
+			}
+			return nil
+		},
+	},
+	types.ChainTypeHpc: {
+		Plugin:    fmt.Sprintf(types.PierPlugin, types.ChainTypeHpc),
+		Templates: []string{"hyperchain.toml", "hyperchain.validators"},
+		Register: pier.AppchainInfo{
+			Name:       "chainC",
+			Type:       "hyperchain",
+			Version:    "1.8.0",
+			Validators: "hyperchain/hyperchain.validators",
+		},
+		configData: func(opts *PierConfigOptions) (*appchainConfigData, error) {
+			addr, account, err := hyperchainNode(opts.Hyperchain.ConfigPath)
+			if err != nil {
+				return nil, err
+			}
+			return &appchainConfigData{
+				AppchainAddr:         addr,
+				AppchainAccount:      account,
+				AppchainContractAddr: opts.Hyperchain.ContractAddr,
+			}, nil
+		},
+		// the sdk config and the account of hyperchain are used by the plugin as they are
+		configPaths: func(opts *PierConfigOptions) []string {
+			return []string{opts.Hyperchain.ConfigPath}
+		},
+		validate: func(opts *PierConfigOptions) error {
+			if !fileutil.Exist(filepath.Join(opts.Hyperchain.ConfigPath, "hpc.toml")) || !fileutil.Exist(filepath.Join(opts.Hyperchain.ConfigPath, "hpc.account")) {
+				return fmt.Errorf("hpc.toml and hpc.account are needed in hyperchain config path %s", opts.Hyperchain.ConfigPath)
+			}
+			if opts.Hyperchain.ContractAddr == "" {
+				return fmt.Errorf("hyperchain broker contract address is needed, deploy it by `goduck hpc deploy` and set contractAddr")
+			}
+			return nil
+		},
+	},
+	// the plugin and its config of the custom appchain are given by
+	// --plugin and --plugin-config, the config is copied as it is and
+	// has to contain the validators to register
+	types.ChainTypeCustom: {
+		Register: pier.AppchainInfo{
+			Name:       "chainD",
+			Type:       "custom",
+			Version:    "1.0.0",
+			Validators: "custom/validators",
+		},
+	},
+}
+
+// GetPierAppchain returns the appchain of the type
+func GetPierAppchain(typ string) (*PierAppchain, error) {
+	appchain, ok := pierAppchains[typ]
+	if !ok {
+		return nil, fmt.Errorf("invalid appchain type(%s), choose one of %s", typ, strings.Join(pierAppchainTypes(), ", "))
+	}
+
+	return appchain, nil
+}
+
+// pierAppchainTypes returns the sorted types of the appchains
+func pierAppchainTypes() []string {
+	var typs []string
+	for typ := range pierAppchains {
+		typs = append(typs, typ)
+	}
+	sort.Strings(typs)
+
+	return typs
+}
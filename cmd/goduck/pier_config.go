@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/pelletier/go-toml"
+)
+
+var (
+	// fabricPortKeys are the keys of the fabric ports in pier_modify_config.toml,
+	// which are the port of the orderer, and the ports of the four peers
+	fabricPortKeys = []string{
+		"ordererP",
+		"urlSubstitutionExpP1", "eventUrlSubstitutionExpP1",
+		"urlSubstitutionExpP2", "eventUrlSubstitutionExpP2",
+		"urlSubstitutionExpP3", "eventUrlSubstitutionExpP3",
+		"urlSubstitutionExpP4", "eventUrlSubstitutionExpP4",
+	}
+)
+
+// PierConfigOptions are the options to generate the configuration of a pier.
+// The ones of the pier and the appchain are loaded from pier_modify_config.toml,
+// the paths are given by the caller.
+type PierConfigOptions struct {
+	// Target is the repo of the pier
+	Target  string
+	Version string
+	// AppchainType is one of pierAppchains
+	AppchainType string
+	// AppchainConfigPath is the directory of the appchain config templates,
+	// e.g. $repo/pier/ethereum/1.2.0
+	AppchainConfigPath string
+	// BinPath is the directory of the pier binary and its libraries, which
+	// generates the keys. It is empty for a remote deployment, whose keys
+	// are generated on the remote host.
+	BinPath string
+	// PluginPath is the appchain plugin
+	PluginPath string
+
+	// Rewrite means the existing configuration in Target is overwritten
+	Rewrite   bool
+	HTTPPort  int
+	PprofPort int
+	APIPort   int
+	TLS       bool
+	// Mode is relay, direct or union
+	Mode string
+	// Method is the did method of the appchain, only for v1.8.0 to v1.11.1
+	Method string
+
+	// BitXHubAddrs and Validators are for relay mode, the older versions
+	// only connect to the first address
+	BitXHubAddrs []string
+	Validators   []string
+	// DirectPort and Peers are for direct mode
+	DirectPort int
+	Peers      []string
+	// Connectors and Providers are for union mode
+	Connectors []string
+	Providers  int
+
+	Ethereum   EthereumPierOptions
+	Fabric     FabricPierOptions
+	Hyperchain HyperchainPierOptions
+}
+
+type EthereumPierOptions struct {
+	// Addr is the websocket address of the ethereum node
+	Addr         string
+	ContractAddr string
+}
+
+type FabricPierOptions struct {
+	CryptoPath string
+	IP         string
+	// Ports are the port of the orderer, and the url and event url
+	// substitution ports of the four peers
+	Ports []string
+}
+
+type HyperchainPierOptions struct {
+	// ConfigPath is the directory of hpc.toml, hpc.account and certs, which
+	// are copied to the plugin config
+	ConfigPath   string
+	ContractAddr string
+}
+
+// pierConfigData is the data of the pier.toml and api templates, the lists
+// are in the format of toml arrays
+type pierConfigData struct {
+	Mode         string
+	Bitxhub      string
+	Validators   string
+	Peers        string
+	Connectors   string
+	Providers    int
+	Tls          bool
+	HttpPort     int
+	PprofPort    int
+	PluginFile   string
+	PluginConfig string
+	ApiPort      int
+	Method       string
+}
+
+// tomlStrings formats the strings as a toml array
+func tomlStrings(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, strconv.Quote(v))
+	}
+
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// appchainConfigData is the data of the appchain config templates
+type appchainConfigData struct {
+	AppchainAddr         string
+	AppchainContractAddr string
+	ConfigPath           string
+	AppchainIP           string
+	AppchainAccount      string
+	Port1                string
+	Port2                string
+	Port3                string
+	Port4                string
+	Port5                string
+	Port6                string
+	Port7                string
+	Port8                string
+	Port9                string
+}
+
+// LoadPierConfigOptions loads the options of the pier and the appchains from
+// the modify config, the options of the other modes are left empty.
+func LoadPierConfigOptions(path string) (*PierConfigOptions, error) {
+	config, err := repo.LoadModifyConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &PierConfigOptions{
+		Rewrite: config.Bool("pier.rewrite"),
+		TLS:     config.Bool("pier.tls"),
+		Mode:    config.Get("pier.mode"),
+		Method:  config.Get("pier.method"),
+		Ethereum: EthereumPierOptions{
+			Addr:         config.Get("appchain.ethereum.ethAddr"),
+			ContractAddr: config.Get("appchain.ethereum.contractAddr"),
+		},
+		Fabric: FabricPierOptions{
+			CryptoPath: config.Get("appchain.fabric.cryptoPath"),
+			IP:         config.Get("appchain.fabric.fabricIP"),
+		},
+		Hyperchain: HyperchainPierOptions{
+			ConfigPath:   config.Get("appchain.hyperchain.configPath"),
+			ContractAddr: config.Get("appchain.hyperchain.contractAddr"),
+		},
+	}
+
+	ints := map[string]*int{
+		"pier.httpPort":  &opts.HTTPPort,
+		"pier.pprofPort": &opts.PprofPort,
+		"pier.apiPort":   &opts.APIPort,
+	}
+	lists := make(map[string]*[]string)
+	switch opts.Mode {
+	case types.PierModeRelay:
+		lists["pier.relay.bitxhubAddr"] = &opts.BitXHubAddrs
+		lists["pier.relay.bitxhubValidators"] = &opts.Validators
+	case types.PierModeDirect:
+		ints["pier.direct.directPort"] = &opts.DirectPort
+		lists["pier.direct.peersAddr"] = &opts.Peers
+	case types.PierModeUnion:
+		ints["pier.union.providers"] = &opts.Providers
+		lists["pier.union.connectors"] = &opts.Connectors
+	}
+	for key, v := range ints {
+		if *v, err = config.Int(key); err != nil {
+			return nil, err
+		}
+	}
+	for key, v := range lists {
+		if *v, err = config.List(key); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range fabricPortKeys {
+		if port := config.Get("appchain.fabric." + key); port != "" {
+			opts.Fabric.Ports = append(opts.Fabric.Ports, port)
+		}
+	}
+
+	return opts, nil
+}
+
+// hyperchainNode returns the jsonrpc address of the first node in hpc.toml,
+// and the account address in hpc.account
+func hyperchainNode(configPath string) (string, string, error) {
+	sdkConfig, err := toml.LoadFile(filepath.Join(configPath, "hpc.toml"))
+	if err != nil {
+		return "", "", fmt.Errorf("load hpc.toml: %w", err)
+	}
+	nodes, _ := sdkConfig.Get("jsonRPC.nodes").([]interface{})
+	ports, _ := sdkConfig.Get("jsonRPC.ports").([]interface{})
+	if len(nodes) == 0 || len(ports) == 0 {
+		return "", "", fmt.Errorf("no jsonRPC node in hpc.toml")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(configPath, "hpc.account"))
+	if err != nil {
+		return "", "", fmt.Errorf("read hpc.account: %w", err)
+	}
+	account := struct {
+		Address string `json:"address"`
+	}{}
+	if err := json.Unmarshal(data, &account); err != nil {
+		return "", "", fmt.Errorf("unmarshal hpc.account: %w", err)
+	}
+
+	return net.JoinHostPort(fmt.Sprint(nodes[0]), fmt.Sprint(ports[0])), account.Address, nil
+}
+
+// pierConfigOptions loads the options from the modify config, and sets the
+// paths of the pier
+func pierConfigOptions(configPath, target, version, appchainType, appchainConfigPath, binPath, pluginPath string) (*PierConfigOptions, error) {
+	opts, err := LoadPierConfigOptions(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("load pier options: %w", err)
+	}
+
+	opts.Target = target
+	opts.Version = version
+	opts.AppchainType = appchainType
+	opts.AppchainConfigPath = appchainConfigPath
+	opts.BinPath = binPath
+	opts.PluginPath = pluginPath
+
+	return opts, nil
+}
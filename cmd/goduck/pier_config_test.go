@@ -0,0 +1,159 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/pelletier/go-toml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitPierConfigRemote(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pier")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	plugin := filepath.Join(dir, "eth-client")
+	require.Nil(t, ioutil.WriteFile(plugin, []byte("plugin"), 0644))
+	opts := &PierConfigOptions{
+		Target:             filepath.Join(dir, ".pier_ethereum"),
+		Version:            "v1.11.3",
+		AppchainType:       types.ChainTypeEther,
+		AppchainConfigPath: "../../config/pier/ethereum/1.2.0",
+		PluginPath:         plugin,
+		HTTPPort:           44544,
+		PprofPort:          44555,
+		APIPort:            8080,
+		TLS:                true,
+		Mode:               types.PierModeRelay,
+		BitXHubAddrs:       []string{"localhost:60011", "localhost:60012"},
+		Validators:         []string{"0xc7F999b83Af6DF9e67d0a37Ee7e900bF38b3D013"},
+		Ethereum:           EthereumPierOptions{Addr: "ws://127.0.0.1:8546", ContractAddr: "0x857133c5C69e6Ce66F7AD46F200B9B3573e77582"},
+	}
+	require.Nil(t, InitPierConfig(opts))
+
+	tree, err := toml.LoadFile(filepath.Join(opts.Target, repo.PierConfigName))
+	require.Nil(t, err)
+	require.EqualValues(t, 44544, tree.Get("port.http"))
+	require.EqualValues(t, 44555, tree.Get("port.pprof"))
+	require.Equal(t, types.PierModeRelay, tree.Get("mode.type"))
+	require.Equal(t, []interface{}{"localhost:60011", "localhost:60012"}, tree.Get("mode.relay.addrs"))
+	require.Equal(t, []interface{}{"0xc7F999b83Af6DF9e67d0a37Ee7e900bF38b3D013"}, tree.Get("mode.relay.validators"))
+	require.Equal(t, true, tree.Get("security.enable_tls"))
+	require.Equal(t, "appchain_plugin", tree.Get("appchain.plugin"))
+	require.Equal(t, types.ChainTypeEther, tree.Get("appchain.config"))
+	require.False(t, tree.Has("appchain.did"))
+
+	api, err := ioutil.ReadFile(filepath.Join(opts.Target, "api"))
+	require.Nil(t, err)
+	require.Equal(t, "http://localhost:8080/v1/\n", string(api))
+
+	// the tls ca, the plugin and the appchain config are in place, and no key
+	// is generated for the remote host
+	require.FileExists(t, filepath.Join(opts.Target, types.TlsCerts, "agency.cert"))
+	require.FileExists(t, filepath.Join(opts.Target, "plugins", "appchain_plugin"))
+	ethereum, err := ioutil.ReadFile(filepath.Join(opts.Target, types.ChainTypeEther, "ethereum.toml"))
+	require.Nil(t, err)
+	require.Contains(t, string(ethereum), opts.Ethereum.Addr)
+	require.Contains(t, string(ethereum), opts.Ethereum.ContractAddr)
+	_, err = os.Stat(filepath.Join(opts.Target, repo.KeyName))
+	require.True(t, os.IsNotExist(err))
+
+	// the existing config is overwritten with rewrite
+	opts.Rewrite = true
+	opts.Mode = types.PierModeUnion
+	opts.Connectors = []string{"/ip4/127.0.0.1/tcp/4001/p2p/Qma1oh5JtrV24gfP9bFrVv4miGKz7AABpfJhZ4F2Z5ngmL"}
+	opts.Providers = 1
+	require.Nil(t, InitPierConfig(opts))
+	tree, err = toml.LoadFile(filepath.Join(opts.Target, repo.PierConfigName))
+	require.Nil(t, err)
+	require.Equal(t, types.PierModeUnion, tree.Get("mode.type"))
+	require.Equal(t, []interface{}{opts.Connectors[0]}, tree.Get("mode.union.connectors"))
+}
+
+// fakePier is a pier binary which writes all the keys the versions may have,
+// and the arguments of init to key.json
+const fakePier = `#!/bin/sh
+repo=$2
+shift 2
+case "$1" in
+init)
+  mkdir -p "$repo"
+  echo "$@" > "$repo/key.json"
+  echo "node" > "$repo/node.priv"
+  echo "admin" > "$repo/admin.json"
+  ;;
+p2p)
+  echo "QmXi58fp9ZczF3Z5iz1yXAez3Hy5NYo1R8STHWKEM9XnTL"
+  ;;
+esac
+`
+
+func TestInitPierConfigKeys(t *testing.T) {
+	if runtime.GOOS != types.LinuxSystem {
+		t.Skip("the fake pier binary is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "pier")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	binPath := filepath.Join(dir, "bin")
+	require.Nil(t, os.MkdirAll(binPath, 0755))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(binPath, "pier"), []byte(fakePier), 0755))
+	plugin := filepath.Join(dir, "eth-client")
+	require.Nil(t, ioutil.WriteFile(plugin, []byte("plugin"), 0644))
+
+	tests := []struct {
+		version  string
+		initArgs string
+		plugin   string
+		keys     []string
+		noKeys   []string
+	}{
+		{version: "v1.0.0", initArgs: "init", plugin: "eth-client.so", keys: []string{repo.KeyName}, noKeys: []string{repo.NodeKeyName, repo.AdminKeyName}},
+		{version: "v1.6.5", initArgs: "init", plugin: "appchain_plugin", keys: []string{repo.KeyName, repo.NodeKeyName}, noKeys: []string{repo.AdminKeyName}},
+		{version: "v1.11.3", initArgs: "init direct", plugin: "appchain_plugin", keys: []string{repo.KeyName, repo.NodeKeyName, repo.AdminKeyName}},
+	}
+
+	for _, test := range tests {
+		opts := &PierConfigOptions{
+			Target:             filepath.Join(dir, test.version),
+			Version:            test.version,
+			AppchainType:       types.ChainTypeEther,
+			AppchainConfigPath: "../../config/pier/ethereum/1.2.0",
+			BinPath:            binPath,
+			PluginPath:         plugin,
+			HTTPPort:           44544,
+			PprofPort:          44555,
+			APIPort:            8080,
+			Mode:               types.PierModeDirect,
+			DirectPort:         5001,
+			Ethereum:           EthereumPierOptions{Addr: "ws://127.0.0.1:8546", ContractAddr: "0x857133c5C69e6Ce66F7AD46F200B9B3573e77582"},
+		}
+		require.Nil(t, InitPierConfig(opts), test.version)
+
+		initArgs, err := ioutil.ReadFile(filepath.Join(opts.Target, repo.KeyName))
+		require.Nil(t, err, test.version)
+		require.Equal(t, test.initArgs, strings.TrimSpace(string(initArgs)), test.version)
+		for _, key := range test.keys {
+			require.FileExists(t, filepath.Join(opts.Target, key), test.version)
+		}
+		for _, key := range test.noKeys {
+			_, err := os.Stat(filepath.Join(opts.Target, key))
+			require.True(t, os.IsNotExist(err), test.version)
+		}
+
+		tree, err := toml.LoadFile(filepath.Join(opts.Target, repo.PierConfigName))
+		require.Nil(t, err, test.version)
+		require.Equal(t, test.plugin, tree.Get("appchain.plugin"), test.version)
+		require.FileExists(t, filepath.Join(opts.Target, "plugins", test.plugin), test.version)
+		require.Equal(t, []interface{}{"/ip4/127.0.0.1/tcp/5001/p2p/QmXi58fp9ZczF3Z5iz1yXAez3Hy5NYo1R8STHWKEM9XnTL"}, tree.Get("mode.direct.peers"), test.version)
+	}
+}
@@ -1,15 +1,15 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"path/filepath"
+	"runtime"
 
 	"github.com/meshplus/bitxhub-kit/crypto/asym"
 	"github.com/meshplus/bitxhub-kit/fileutil"
 	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
 	"github.com/meshplus/goduck/cmd/goduck/pier"
+	"github.com/meshplus/goduck/internal/release"
 	"github.com/meshplus/goduck/internal/repo"
 	"github.com/meshplus/goduck/internal/types"
 	"github.com/meshplus/goduck/internal/utils"
@@ -78,18 +78,17 @@ func dockerUp(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, "release.json"))
+	// the playground runs the pier and the plugin of the same version as BitXHub
+	m, err := release.Load(repoRoot)
 	if err != nil {
 		return err
 	}
-
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
+	if err := m.Check(version, version, types.ChainTypeEther); err != nil {
 		return err
 	}
-
-	if !AdjustVersion(version, release.Bitxhub) {
-		return fmt.Errorf("unsupport verison")
+	ethVersion, err := m.EthereumContract(version)
+	if err != nil {
+		return err
 	}
 
 	//get bitxhub addr
@@ -104,7 +103,7 @@ func dockerUp(ctx *cli.Context) error {
 	}
 
 	// download
-	if err := pier.DownloadPierPlugin(repoRoot, types.ChainTypeEther, version, types.LinuxSystem); err != nil {
+	if err := pier.DownloadPierPlugin(repoRoot, types.ChainTypeEther, version, types.LinuxSystem, runtime.GOARCH); err != nil {
 		return fmt.Errorf("download pier plugin binary error:%w", err)
 	}
 
@@ -112,7 +111,6 @@ func dockerUp(ctx *cli.Context) error {
 		return fmt.Errorf("download bitxhub.toml error:%w", err)
 	}
 
-	ethVersion := EthConfigMap[version]
 	args := []string{types.QuickStartScript, "up"}
 	args = append(args, address.String(), prometheus, version, ethVersion)
 	return utils.ExecuteShell(args, repoRoot)
@@ -154,7 +152,10 @@ func transfer(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	ethVersion := EthConfigMap[version]
+	ethVersion, err := ethContractVersion(repoRoot, version)
+	if err != nil {
+		return err
+	}
 	args := []string{types.QuickStartScript, "transfer", ethVersion, version}
 	return utils.ExecuteShell(args, repoRoot)
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/meshplus/goduck/internal/ports"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	// bxhPortKeys are the keys of the node ports in bxh_modify_config.toml
+	bxhPortKeys = map[ports.Kind]string{
+		ports.P2P:     "p2p_port",
+		ports.JSONRPC: "jsonrpc_port",
+		ports.GRPC:    "grpc_port",
+		ports.Gateway: "gateway_port",
+		ports.Pprof:   "pprof_port",
+		ports.Monitor: "monitor_port",
+	}
+	// pierPortKeys are the keys of the pier ports in pier_modify_config.toml
+	pierPortKeys = map[ports.Kind]string{
+		ports.PierP2P:   "pier.direct.directPort",
+		ports.PierHTTP:  "pier.httpPort",
+		ports.PierPprof: "pier.pprofPort",
+		ports.PierAPI:   "pier.apiPort",
+	}
+)
+
+func basePortFlag() cli.Flag {
+	return &cli.StringSliceFlag{
+		Name:  "base-port",
+		Usage: "Override the port of the first node or pier which the allocated ports start from, e.g. p2p=5001 or pier_http=45554",
+	}
+}
+
+// allocateBxhPorts allocates the ports of the nodes configured in target,
+// and writes a copy of the modify config with them whose path is returned.
+// The nodes missing in the modify config are added on the host of its last node.
+func allocateBxhPorts(repoRoot, target, configPath string, overrides []string) (string, error) {
+	config, err := repo.LoadModifyConfig(configPath)
+	if err != nil {
+		return "", err
+	}
+	num := 1
+	if config.Get("bitxhub.mode") == types.ClusterMode {
+		if num, err = config.Int("bitxhub.num"); err != nil {
+			return "", err
+		}
+	}
+
+	configured := len(config.Values(bxhPortKeys[ports.GRPC]))
+	if configured != 0 {
+		last := fmt.Sprintf("bitxhub.node%d", configured)
+		keys := config.Keys(last)
+		for i := configured + 1; i <= num; i++ {
+			lines := []string{fmt.Sprintf("  [[bitxhub.node%d]]", i)}
+			for _, key := range keys {
+				lines = append(lines, fmt.Sprintf("    %s = %s", key, config.Get(last+"."+key)))
+			}
+			config.Append(lines...)
+		}
+	}
+
+	env, err := allocatePorts(repoRoot, target, config, bxhPortKeys, overrides, num, 0)
+	if err != nil {
+		return "", err
+	}
+	for kind, key := range bxhPortKeys {
+		values := config.Values(key)
+		if len(values) == 0 {
+			// the older configs have no such port
+			continue
+		}
+		for i := 0; i < num && i < len(values); i++ {
+			values[i] = strconv.Itoa(env.Nodes[i][kind])
+		}
+		if err := config.SetValues(key, values); err != nil {
+			return "", err
+		}
+	}
+
+	path := target + "_" + types.BxhModifyConfig
+	if err := config.Write(path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// allocatePierPorts allocates the ports of the pier in target, and writes a
+// copy of the modify config with them whose path is returned.
+func allocatePierPorts(repoRoot, target, configPath string, overrides []string) (string, error) {
+	config, err := repo.LoadModifyConfig(configPath)
+	if err != nil {
+		return "", err
+	}
+
+	env, err := allocatePorts(repoRoot, target, config, pierPortKeys, overrides, 0, 1)
+	if err != nil {
+		return "", err
+	}
+	for kind, key := range pierPortKeys {
+		if config.Get(key) == "" {
+			continue
+		}
+		if err := config.Set(key, strconv.Itoa(env.Piers[0][kind])); err != nil {
+			return "", err
+		}
+	}
+
+	path := target + "_" + types.PierModifyConfig
+	if err := config.Write(path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// allocatePorts allocates the ports of the environment in target and saves
+// them, the ports of the first node or pier in the modify config are the
+// bases unless they are overridden. The environment is reallocated if the
+// bases are overridden.
+func allocatePorts(repoRoot, target string, config *repo.ModifyConfig, keys map[ports.Kind]string, overrides []string, nodes, piers int) (*ports.Env, error) {
+	bases, err := ports.ParseBases(overrides)
+	if err != nil {
+		return nil, err
+	}
+	for kind, key := range keys {
+		if _, ok := bases[kind]; ok {
+			continue
+		}
+		if port, err := strconv.Atoi(config.Get(key)); err == nil {
+			bases[kind] = port
+		}
+	}
+
+	alloc, err := ports.Load(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	if len(overrides) != 0 {
+		alloc.Release(target)
+	}
+	env, err := ports.NewAllocator(bases).Allocate(alloc, target, nodes, piers)
+	if err != nil {
+		return nil, err
+	}
+	if err := alloc.Save(); err != nil {
+		return nil, fmt.Errorf("save ports: %w", err)
+	}
+
+	return env, nil
+}
+
+// releasePorts frees the ports allocated to the environment in target
+func releasePorts(repoRoot, target string) error {
+	alloc, err := ports.Load(repoRoot)
+	if err != nil {
+		return err
+	}
+	if alloc.Get(target) == nil {
+		return nil
+	}
+	alloc.Release(target)
+
+	return alloc.Save()
+}
@@ -6,12 +6,15 @@ import (
 	"strings"
 
 	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/internal/ports"
 	"github.com/meshplus/goduck/internal/repo"
 	"github.com/meshplus/goduck/internal/types"
 	"github.com/meshplus/goduck/internal/utils"
 	"github.com/urfave/cli/v2"
 )
 
+const defaultPromAddrs = "host.docker.internal:40011 host.docker.internal:40012 host.docker.internal:40013 host.docker.internal:40014"
+
 func prometheusCMD() *cli.Command {
 	return &cli.Command{
 		Name:  "prometheus",
@@ -23,8 +26,7 @@ func prometheusCMD() *cli.Command {
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "addrs",
-						Usage:    "address of BitXHub nodes",
-						Value:    "host.docker.internal:40011 host.docker.internal:40012 host.docker.internal:40013 host.docker.internal:40014",
+						Usage:    "address of BitXHub nodes, default: the monitor ports allocated to $repo/bitxhub/.bitxhub",
 						Required: false,
 					},
 				},
@@ -41,8 +43,7 @@ func prometheusCMD() *cli.Command {
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "addrs",
-						Usage:    "address of BitXHub nodes",
-						Value:    "host.docker.internal:40011 host.docker.internal:40012 host.docker.internal:40013 host.docker.internal:40014",
+						Usage:    "address of BitXHub nodes, default: the monitor ports allocated to $repo/bitxhub/.bitxhub",
 						Required: false,
 					},
 				},
@@ -53,7 +54,6 @@ func prometheusCMD() *cli.Command {
 }
 
 func startProm(ctx *cli.Context) error {
-	addrs := strings.Fields(ctx.String("addrs"))
 	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
 	if err != nil {
 		return err
@@ -61,6 +61,10 @@ func startProm(ctx *cli.Context) error {
 	if !fileutil.Exist(repoRoot) {
 		return fmt.Errorf("please `goduck init` first")
 	}
+	addrs, err := promAddrs(repoRoot, ctx.String("addrs"))
+	if err != nil {
+		return err
+	}
 	args := make([]string, 0)
 	args = append(args, filepath.Join(repoRoot, types.Prometheus), "up")
 
@@ -85,7 +89,6 @@ func stopProm(ctx *cli.Context) error {
 }
 
 func restartProm(ctx *cli.Context) error {
-	addrs := strings.Fields(ctx.String("addrs"))
 	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
 	if err != nil {
 		return err
@@ -93,6 +96,10 @@ func restartProm(ctx *cli.Context) error {
 	if !fileutil.Exist(repoRoot) {
 		return fmt.Errorf("please `goduck init` first")
 	}
+	addrs, err := promAddrs(repoRoot, ctx.String("addrs"))
+	if err != nil {
+		return err
+	}
 	args := make([]string, 0)
 	args = append(args, filepath.Join(repoRoot, types.Prometheus), "restart")
 
@@ -102,3 +109,27 @@ func restartProm(ctx *cli.Context) error {
 
 	return utils.ExecuteShell(args, repoRoot)
 }
+
+// promAddrs returns the given addresses, or the monitor addresses of the nodes
+// in $repo/bitxhub/.bitxhub if none is given
+func promAddrs(repoRoot, addrs string) ([]string, error) {
+	if addrs != "" {
+		return strings.Fields(addrs), nil
+	}
+
+	alloc, err := ports.Load(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	env := alloc.Get(filepath.Join(repoRoot, "bitxhub/.bitxhub"))
+	if env == nil || len(env.Nodes) == 0 {
+		return strings.Fields(defaultPromAddrs), nil
+	}
+
+	var ret []string
+	for _, node := range env.Nodes {
+		ret = append(ret, fmt.Sprintf("host.docker.internal:%d", node[ports.Monitor]))
+	}
+
+	return ret, nil
+}
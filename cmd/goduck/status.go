@@ -3,44 +3,27 @@ package main
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cheynewallace/tabby"
-	"github.com/codeskyblue/go-sh"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 	"github.com/meshplus/bitxhub-kit/fileutil"
 	"github.com/meshplus/goduck/internal/repo"
+	gnet "github.com/shirou/gopsutil/net"
 	gops "github.com/shirou/gopsutil/process"
 	"github.com/urfave/cli/v2"
 )
 
-var processesNames = []string{
-	"bitxhub/node",
-	"pier_ethereum",
-	"pier_fabric",
-}
-
-var processesAppchainNames = []string{
-	".goduck/ethereum/datadir",
-}
-
-var containerNames = []string{
-	"bitxhub_solo",
-	"bitxhub_node",
-	"pier-ethereum",
-	"pier-fabric",
-	"ethereum-node",
-	"ethereum-1",
-	"ethereum-2",
-	"cli",
-	"peer0.org1.example.com",
-	"peer1.org1.example.com",
-	"orderer.example.com",
-	"peer1.org2.example.com",
-	"peer0.org2.example.com",
-}
+// ComponentLabel is the docker label of the containers started by goduck,
+// its value is the component name, e.g. bitxhub, pier, ethereum or fabric.
+const ComponentLabel = "goduck.component"
 
 var modes = []string{
 	"binary",
@@ -53,8 +36,19 @@ func GetStatusCMD() *cli.Command {
 		Usage: "Check status of interchain system",
 		Subcommands: []*cli.Command{
 			{
-				Name:   "list",
-				Usage:  "Check the BitxHub, PIER and other components which in default configuration directories or start in docker",
+				Name:  "list",
+				Usage: "Check the BitxHub, PIER and other components which in default configuration directories or start in docker",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "watch",
+						Usage: "Redraw the status in place and log state transitions until interrupted",
+					},
+					&cli.DurationFlag{
+						Name:  "interval",
+						Value: 2 * time.Second,
+						Usage: "Refresh interval of watch mode",
+					},
+				},
 				Action: showStatus,
 			},
 			{
@@ -69,10 +63,85 @@ func GetStatusCMD() *cli.Command {
 				},
 				Action: showComponentStatus,
 			},
+			{
+				Name:  "health",
+				Usage: "Probe the endpoints of BitXHub nodes, piers and appchains, exit with non-zero code if any of them is unhealthy",
+				Flags: []cli.Flag{
+					&cli.DurationFlag{
+						Name:  "interval",
+						Value: 3 * time.Second,
+						Usage: "Interval between two height samples used to check whether the height is advancing",
+					},
+					&cli.DurationFlag{
+						Name:  "probe-timeout",
+						Value: 3 * time.Second,
+						Usage: "Timeout of each probe",
+					},
+				},
+				Action: showHealth,
+			},
 		},
 	}
 }
 
+// ComponentStatus is the status of a component started in binary or docker.
+type ComponentStatus struct {
+	Name      string   `json:"name" yaml:"name"`
+	Component string   `json:"component" yaml:"component"`
+	Mode      string   `json:"mode" yaml:"mode"`
+	ID        string   `json:"id" yaml:"id"`
+	Status    string   `json:"status" yaml:"status"`
+	Created   string   `json:"created" yaml:"created"`
+	Ports     []string `json:"ports" yaml:"ports"`
+	Version   string   `json:"version" yaml:"version"`
+	Args      string   `json:"args" yaml:"args"`
+	// Repo is the config directory of the component on the host
+	Repo string `json:"repo,omitempty" yaml:"repo,omitempty"`
+
+	// hostPorts maps the ports inside the container to the published ports
+	hostPorts map[int]int
+}
+
+var statusHeader = []string{"Name", "Component", "Mode", "PID/ContanierID", "Status", "Created Time", "Ports", "Version", "Args"}
+
+var binaryVersionRegexp = regexp.MustCompile(`_(v?\d+\.\d+\.\d+[^/]*)/`)
+
+func (s *ComponentStatus) row() []string {
+	return []string{s.Name, s.Component, s.Mode, s.ID, s.Status, s.Created, strings.Join(s.Ports, ","), s.Version, s.Args}
+}
+
+func (s *ComponentStatus) hasPort(port string) bool {
+	for _, p := range s.Ports {
+		if p == port {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hostPort returns the port on the host which the component port is reachable from
+func (s *ComponentStatus) hostPort(port int) int {
+	if p, ok := s.hostPorts[port]; ok {
+		return p
+	}
+
+	return port
+}
+
+func printStatus(ctx *cli.Context, statuses []*ComponentStatus) error {
+	table := [][]string{statusHeader}
+	for _, s := range statuses {
+		table = append(table, s.row())
+	}
+
+	if statuses == nil {
+		statuses = []*ComponentStatus{}
+	}
+
+	return printOutput(ctx, statuses, table)
+}
+
 func showStatus(ctx *cli.Context) error {
 	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
 	if err != nil {
@@ -83,21 +152,16 @@ func showStatus(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	var table [][]string
-	table = append(table, []string{"Name", "Component", "Mode", "PID/ContanierID", "Status", "Created Time", "Args"})
-
-	table, err = existProcess(table)
-	if err != nil {
-		return err
+	if ctx.Bool("watch") {
+		return watchStatus(ctx)
 	}
 
-	table, err = existContainer(table)
+	statuses, err := listComponents(ctx.Context)
 	if err != nil {
 		return err
 	}
 
-	PrintTable(table, true)
-	return nil
+	return printStatus(ctx, statuses)
 }
 
 func showComponentStatus(ctx *cli.Context) error {
@@ -112,241 +176,307 @@ func showComponentStatus(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	var table [][]string
-	table = append(table, []string{"Name", "Component", "Mode", "PID/ContanierID", "Status", "Created Time", "Args"})
-
-	paramsBin, err := binaryStatus(port)
+	statuses, err := listComponents(ctx.Context)
 	if err != nil {
-		return fmt.Errorf("binary status error: %w", err)
+		return err
 	}
-	if paramsBin != nil {
-		if paramsBin[1] == "pier" || paramsBin[1] == "bitxhub" {
-			table = append(table, paramsBin)
+
+	var matched []*ComponentStatus
+	for _, status := range statuses {
+		if status.hasPort(port) {
+			matched = append(matched, status)
 		}
 	}
 
-	paramsDocker, err := dockerStatus(port)
+	return printStatus(ctx, matched)
+}
+
+// listComponents lists the components started in binary and in docker.
+func listComponents(ctx context.Context) ([]*ComponentStatus, error) {
+	statuses, err := processStatuses()
 	if err != nil {
-		return fmt.Errorf("docker status error: %w", err)
+		return nil, fmt.Errorf("list processes: %w", err)
 	}
-	if paramsDocker != nil {
-		table = append(table, paramsDocker)
+
+	containers, err := containerStatuses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list containers: %w", err)
 	}
 
-	PrintTable(table, true)
-	return nil
+	return append(statuses, containers...), nil
 }
 
-func binaryStatus(port string) ([]string, error) {
-	// lsof -i:60011 | grep LISTEN | awk '{print $2}'
-	pidOut, err := sh.Command("/bin/bash", "-c", fmt.Sprintf("lsof -i:%s | grep LISTEN | awk '{print $2}'", port)).Output()
+// processStatuses enumerates all processes once and returns the bitxhub, pier
+// and ethereum processes started by goduck, with their listening ports.
+func processStatuses() ([]*ComponentStatus, error) {
+	processes, err := gops.Processes()
 	if err != nil {
-		return nil, fmt.Errorf("get pid error: %w", err)
+		return nil, err
 	}
 
-	if string(pidOut) != "" {
-		params, err := getProcessParam(string(pidOut[:len(pidOut)-1]), "")
-		if err != nil {
-			return nil, fmt.Errorf("get process param error: %w", err)
+	var statuses []*ComponentStatus
+	for _, process := range processes {
+		cmdline, err := process.CmdlineSlice()
+		if err != nil || len(cmdline) == 0 {
+			// the process is exited or not accessible
+			continue
 		}
-		return params, nil
-	}
 
-	return nil, nil
-}
+		name, component := classifyProcess(cmdline)
+		if component == "" {
+			continue
+		}
 
-func dockerStatus(port string) ([]string, error) {
-	tmpOut, _ := sh.Command("/bin/bash", "-c", "docker ps -a -q").Output()
-	if string(tmpOut) == "" {
-		return nil, nil
+		statuses = append(statuses, getProcessParam(process, cmdline, name, component))
 	}
-	// docker inspect -f "{{.Id}} {{.HostConfig.PortBindings}} {{.Name}} " $(docker ps -q) | grep HostPort:6001
-	cidOut, err := sh.Command("/bin/bash", "-c", fmt.Sprintf("docker inspect -f \"{{.Id}} {{.HostConfig.PortBindings}} {{.Name}} \" $(docker ps -a -q) | grep HostPort:%s | awk '{print $1}'", port)).Output()
-	if err != nil {
-		return nil, fmt.Errorf("get cid error: %w", err)
+
+	if len(statuses) == 0 {
+		return nil, nil
 	}
 
-	ctx := context.Background()
-	mycli, err := client.NewClientWithOpts(client.FromEnv)
+	ports, err := listenPorts()
 	if err != nil {
 		return nil, err
 	}
+	for _, status := range statuses {
+		pid, _ := strconv.Atoi(status.ID)
+		status.Ports = ports[int32(pid)]
+	}
 
-	if string(cidOut) != "" {
-		params, err := getContainerParam(string(cidOut[:len(cidOut)-1]), mycli, ctx)
-		if err != nil {
-			return nil, fmt.Errorf("get container param error: %w", err)
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Component != statuses[j].Component {
+			return statuses[i].Component < statuses[j].Component
 		}
-		return params, nil
-	}
+		return statuses[i].Name < statuses[j].Name
+	})
 
-	return nil, nil
+	return statuses, nil
 }
 
-func existProcess(table [][]string) ([][]string, error) {
-	for _, pname := range processesNames {
-		pidOut, err := sh.Command("/bin/bash", "-c", fmt.Sprintf("ps aux | grep %s | grep start | grep -v grep | awk '{print $2}'", pname)).Output()
-		if err != nil {
-			return nil, fmt.Errorf("get pid error: %w", err)
+// classifyProcess returns the node name and the component of the process by
+// its command line, the component is empty if it is not started by goduck.
+func classifyProcess(cmdline []string) (string, string) {
+	switch filepath.Base(cmdline[0]) {
+	case "bitxhub":
+		if !hasArg(cmdline, "start") {
+			return "", ""
+		}
+		return repoName(flagValue(cmdline, "--repo"), "bitxhub"), "bitxhub"
+	case "pier":
+		if !hasArg(cmdline, "start") {
+			return "", ""
+		}
+		return repoName(flagValue(cmdline, "--repo"), "pier"), "pier"
+	case "geth":
+		datadir := flagValue(cmdline, "--datadir")
+		if !strings.HasSuffix(filepath.Clean(datadir), filepath.Join("ethereum", "datadir")) {
+			return "", ""
 		}
-		pidOutStr := string(pidOut)
-
-		if pidOutStr != "" {
-			processesIDs := strings.Split(pidOutStr, "\n")
-			for i, pid := range processesIDs {
-				if pid != "" {
-					params, err := getProcessParam(pid, fmt.Sprintf("%s_%d", pname, i+1))
-					if err != nil {
-						return table, err
-					}
-
-					table = append(table, params)
-				}
-			}
+		return "ethereum", "ethereum"
+	default:
+		return "", ""
+	}
+}
+
+// hasArg reports whether arg is one of the arguments, not just a substring of them
+func hasArg(cmdline []string, arg string) bool {
+	for _, a := range cmdline[1:] {
+		if a == arg {
+			return true
 		}
 	}
 
-	for _, pname := range processesAppchainNames {
-		pidOut, err := sh.Command("/bin/bash", "-c", fmt.Sprintf("ps aux | grep %s | grep -v grep | awk '{print $2}'", pname)).Output()
-		if err != nil {
-			return nil, fmt.Errorf("get pid error: %w", err)
+	return false
+}
+
+// flagValue returns the value of flag in the form of "--flag value" or "--flag=value"
+func flagValue(cmdline []string, flag string) string {
+	for i, a := range cmdline {
+		if a == flag && i+1 < len(cmdline) {
+			return cmdline[i+1]
 		}
-		pidOutStr := string(pidOut)
-
-		if pidOutStr != "" {
-			processesIDs := strings.Split(pidOutStr, "\n")
-			for i, pid := range processesIDs {
-				if pid != "" {
-					params, err := getProcessParam(pid, fmt.Sprintf("%s_%d", pname, i+1))
-					if err != nil {
-						return table, err
-					}
-
-					table = append(table, params)
-				}
-			}
+		if strings.HasPrefix(a, flag+"=") {
+			return strings.TrimPrefix(a, flag+"=")
 		}
 	}
 
-	return table, nil
+	return ""
 }
 
-func getProcessParam(pidStr, pName string) ([]string, error) {
-	pid, err := strconv.Atoi(string(pidStr))
-	if err != nil {
-		return nil, err
+// repoName returns the name of a node by its repo, e.g. node1 or pier_ethereum
+func repoName(repoPath, defaultName string) string {
+	if repoPath == "" {
+		return defaultName
 	}
 
-	var status, name, timeFormat, args string
-	exist, err := gops.PidExists(int32(pid))
-	if err != nil {
-		// the process is killed by the outside
-		return nil, fmt.Errorf("pid exist: %w", err)
-	} else if exist {
-		status = "running"
-		process, err := gops.NewProcess(int32(pid))
-		if err != nil {
-			return nil, err
-		}
+	return strings.TrimPrefix(filepath.Base(repoPath), ".")
+}
 
-		createTime, err := process.CreateTime()
-		if err != nil {
-			return nil, err
-		}
+func getProcessParam(process *gops.Process, cmdline []string, name, component string) *ComponentStatus {
+	status := "running"
+	if s, err := process.Status(); err == nil && s == "Z" {
+		status = "exited"
+	}
 
+	var timeFormat string
+	if createTime, err := process.CreateTime(); err == nil {
 		tm := time.Unix(0, createTime*int64(time.Millisecond))
 		timeFormat = tm.Format(time.RFC3339)
+	}
 
-		name, _ = process.Name()
-
-		if pName == "" {
-			pName = name
-		}
+	version := ""
+	if match := binaryVersionRegexp.FindStringSubmatch(cmdline[0]); match != nil {
+		version = match[1]
+	}
 
-		slice, _ := process.CmdlineSlice()
-		args = strings.Join(slice, " ")
-		if len(strings.Join(slice, " ")) > 500 {
-			args = args[:70] + "..."
-		}
-	} else {
-		status = "exited"
-		if strings.Contains(pName, "bitxhub") {
-			name = "bitxhub"
-		} else if strings.Contains(pName, "pier") {
-			name = "pier"
-		}
+	args := strings.Join(cmdline, " ")
+	if len(args) > 500 {
+		args = args[:70] + "..."
 	}
 
-	return []string{
-		pName,
-		name,
-		modes[0],
-		pidStr,
-		status,
-		timeFormat,
-		args,
-	}, nil
+	return &ComponentStatus{
+		Name:      name,
+		Component: component,
+		Mode:      modes[0],
+		ID:        strconv.Itoa(int(process.Pid)),
+		Status:    status,
+		Created:   timeFormat,
+		Version:   version,
+		Args:      args,
+		Repo:      flagValue(cmdline, "--repo"),
+	}
 }
 
-func existContainer(table [][]string) ([][]string, error) {
-	ctx := context.Background()
-	mycli, err := client.NewClientWithOpts(client.FromEnv)
+// listenPorts returns the listening tcp ports of all processes keyed by pid
+func listenPorts() (map[int32][]string, error) {
+	conns, err := gnet.Connections("tcp")
 	if err != nil {
-		return table, err
+		return nil, err
 	}
 
-	for _, cname := range containerNames {
-		cidOut, err := sh.Command("/bin/bash", "-c", fmt.Sprintf("docker ps -qf \"name=%s\"", cname)).Output()
-		if err != nil {
-			return nil, fmt.Errorf("get cid error: %w", err)
+	ports := make(map[int32][]string)
+	exist := make(map[string]bool)
+	for _, conn := range conns {
+		if conn.Status != "LISTEN" || conn.Pid == 0 {
+			continue
 		}
-		cidOutStr := string(cidOut)
-		if cidOutStr != "" {
-			containerIDs := strings.Split(cidOutStr, "\n")
-			for _, cid := range containerIDs {
-				if cid != "" {
-					params, err := getContainerParam(cid, mycli, ctx)
-					if err != nil {
-						return table, err
-					}
-
-					table = append(table, params)
-				}
-			}
+		port := strconv.Itoa(int(conn.Laddr.Port))
+		key := fmt.Sprintf("%d:%s", conn.Pid, port)
+		if exist[key] {
+			continue
 		}
+		exist[key] = true
+		ports[conn.Pid] = append(ports[conn.Pid], port)
+	}
+	for _, p := range ports {
+		sort.Strings(p)
 	}
 
-	return table, nil
+	return ports, nil
 }
 
-func getContainerParam(cid string, mycli *client.Client, ctx context.Context) ([]string, error) {
-	containerInfo, err := mycli.ContainerInspect(ctx, cid)
+// containerStatuses lists the containers labeled by goduck with a single docker api call.
+func containerStatuses(ctx context.Context) ([]*ComponentStatus, error) {
+	mycli, err := client.NewClientWithOpts(client.FromEnv)
 	if err != nil {
 		return nil, err
 	}
+	defer mycli.Close()
 
-	info, _, err := mycli.ImageInspectWithRaw(ctx, containerInfo.Image[7:])
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	containers, err := mycli.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", ComponentLabel)),
+	})
 	if err != nil {
+		if client.IsErrConnectionFailed(err) {
+			// docker is not running, so there is no container
+			return nil, nil
+		}
 		return nil, err
 	}
 
-	pos1 := strings.LastIndex(info.RepoTags[0], "/")
-	pos2 := len(info.RepoTags[0])
-	component := info.RepoTags[0][pos1+1 : pos2]
+	var statuses []*ComponentStatus
+	for _, container := range containers {
+		statuses = append(statuses, getContainerParam(container))
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Component != statuses[j].Component {
+			return statuses[i].Component < statuses[j].Component
+		}
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses, nil
+}
 
-	args := strings.Join(containerInfo.Args, " ")
-	if len(strings.Join(containerInfo.Args, " ")) > 70 {
+func getContainerParam(container types.Container) *ComponentStatus {
+	name := container.ID
+	if len(container.Names) != 0 {
+		name = strings.TrimPrefix(container.Names[0], "/")
+	}
+
+	version := "latest"
+	if pos := strings.LastIndex(container.Image, ":"); pos != -1 && !strings.Contains(container.Image[pos:], "/") {
+		version = container.Image[pos+1:]
+	}
+
+	var ports []string
+	exist := make(map[uint16]bool)
+	hostPorts := make(map[int]int)
+	for _, port := range container.Ports {
+		if port.PublicPort == 0 || exist[port.PublicPort] {
+			continue
+		}
+		hostPorts[int(port.PrivatePort)] = int(port.PublicPort)
+		exist[port.PublicPort] = true
+		ports = append(ports, strconv.Itoa(int(port.PublicPort)))
+	}
+	sort.Strings(ports)
+
+	id := container.ID
+	if len(id) > 12 {
+		id = id[:12]
+	}
+
+	args := container.Command
+	if len(args) > 70 {
 		args = args[:70] + "..."
 	}
-	return []string{
-		containerInfo.Name[1:],
-		component,
-		modes[1],
-		cid,
-		containerInfo.State.Status,
-		containerInfo.Created,
-		args,
-	}, nil
+
+	return &ComponentStatus{
+		Name:      name,
+		Component: container.Labels[ComponentLabel],
+		Mode:      modes[1],
+		ID:        id,
+		Status:    container.State,
+		Created:   time.Unix(container.Created, 0).Format(time.RFC3339),
+		Ports:     ports,
+		Version:   version,
+		Args:      args,
+		Repo:      containerRepo(container.Mounts),
+		hostPorts: hostPorts,
+	}
+}
+
+// containerRepo returns the host directory of the bitxhub or pier repo mounted into the container
+func containerRepo(mounts []types.MountPoint) string {
+	for _, mount := range mounts {
+		switch strings.TrimSuffix(mount.Destination, "/") {
+		case "/root/.pier":
+			return mount.Source
+		case "/root/.bitxhub/bitxhub.toml", "/root/.pier/pier.toml":
+			return filepath.Dir(mount.Source)
+		}
+	}
+
+	return ""
 }
 
 // PrintTable accepts a matrix of strings and print them as ASCII table to terminal
@@ -0,0 +1,593 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
+	"github.com/meshplus/goduck/cmd/goduck/ethereum"
+	"github.com/meshplus/goduck/cmd/goduck/fabric"
+	"github.com/meshplus/goduck/cmd/goduck/pier"
+	"github.com/meshplus/goduck/internal/release"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/utils"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	topologyFile              = "topology.yaml"
+	topologyBxhModifyConfig   = "topology_bxh_modify_config.toml"
+	defaultTopologyWait       = 2 * time.Minute
+	defaultFabricOrdererPort  = "7050"
+	defaultFabricPeerPort     = "7051"
+	defaultEthereumHttpPort   = "8545"
+	defaultEthereumWsPort     = "8546"
+	defaultEthereumListenPort = "30303"
+	defaultPierMethod         = "appchain"
+)
+
+// Topology describes an interchain system brought up by `goduck up`.
+type Topology struct {
+	BitXHub   *TopologyBitXHub    `yaml:"bitxhub"`
+	Appchains []*TopologyAppchain `yaml:"appchains"`
+	Piers     []*TopologyPier     `yaml:"piers"`
+}
+
+type TopologyBitXHub struct {
+	Version    string `yaml:"version"`
+	Type       string `yaml:"type"`
+	Mode       string `yaml:"mode"`
+	Num        int    `yaml:"num"`
+	ConfigPath string `yaml:"configPath"`
+	Target     string `yaml:"target"`
+	Genesis    string `yaml:"genesis"`
+}
+
+type TopologyAppchain struct {
+	Type         string `yaml:"type"`
+	UpType       string `yaml:"upType"`
+	HttpPort     string `yaml:"httpPort"`
+	WsPort       string `yaml:"wsPort"`
+	Port         string `yaml:"port"`
+	CryptoConfig string `yaml:"cryptoConfig"`
+}
+
+type TopologyPier struct {
+	Appchain   string `yaml:"appchain"`
+	Version    string `yaml:"version"`
+	UpType     string `yaml:"upType"`
+	ConfigPath string `yaml:"configPath"`
+	Target     string `yaml:"target"`
+	Method     string `yaml:"method"`
+	Register   bool   `yaml:"register"`
+	Rule       bool   `yaml:"rule"`
+	RuleRepo   string `yaml:"ruleRepo"`
+}
+
+func upCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "up",
+		Usage: "Start BitXHub, appchains and piers described in a topology file",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "file",
+				Aliases: []string{"f"},
+				Usage:   "Specify the topology file, default: $repo/topology.yaml",
+			},
+			&cli.DurationFlag{
+				Name:  "wait",
+				Value: defaultTopologyWait,
+				Usage: "Max time to wait for each component to be ready",
+			},
+		},
+		Action: topologyUp,
+	}
+}
+
+func downCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "down",
+		Usage: "Stop piers, appchains and BitXHub described in a topology file",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "file",
+				Aliases: []string{"f"},
+				Usage:   "Specify the topology file, default: $repo/topology.yaml",
+			},
+			&cli.BoolFlag{
+				Name:  "clean",
+				Usage: "Clean the components after stopping them",
+			},
+		},
+		Action: topologyDown,
+	}
+}
+
+func topologyUp(ctx *cli.Context) error {
+	repoRoot, topo, err := loadTopology(ctx)
+	if err != nil {
+		return err
+	}
+	wait := ctx.Duration("wait")
+
+	// 1. relay chain
+	if topo.BitXHub != nil {
+		bxh := topo.BitXHub
+		color.Blue("======> Start bitxhub %s in %s", bxh.Version, bxh.Type)
+		configPath, err := topologyBxhConfig(repoRoot, bxh)
+		if err != nil {
+			return err
+		}
+		if err := startBitXHubNetwork(repoRoot, bxh.Type, bxh.Version, configPath, bxh.Target, bitxhub.RestartNo, 0, nil, bxh.Genesis); err != nil {
+			return fmt.Errorf("start bitxhub: %w", err)
+		}
+		if bxh.Type == types.TypeBinary {
+			// the binary nodes listen on the allocated ports
+			target, err := topologyBxhTarget(repoRoot, bxh)
+			if err != nil {
+				return err
+			}
+			configPath = target + "_" + types.BxhModifyConfig
+		}
+		if err := waitBitXHub(configPath, wait); err != nil {
+			return err
+		}
+	}
+
+	// 2. appchains
+	for _, appchain := range topo.Appchains {
+		color.Blue("======> Start appchain %s in %s", appchain.Type, appchain.UpType)
+		if err := startTopologyAppchain(repoRoot, topo, appchain); err != nil {
+			return fmt.Errorf("start appchain %s: %w", appchain.Type, err)
+		}
+		if err := waitForAddrs(appchainAddrs(appchain), wait); err != nil {
+			return fmt.Errorf("appchain %s is not ready: %w", appchain.Type, err)
+		}
+	}
+
+	// 3. piers
+	for _, p := range topo.Piers {
+		color.Blue("======> Start pier %s of %s in %s", p.Version, p.Appchain, p.UpType)
+		if err := startPier(repoRoot, p.Appchain, p.Target, p.UpType, p.ConfigPath, p.Version, nil, "", ""); err != nil {
+			return fmt.Errorf("start pier of %s: %w", p.Appchain, err)
+		}
+		if err := waitPier(repoRoot, p, wait); err != nil {
+			return err
+		}
+
+		cid := ""
+		if p.UpType == types.TypeDocker && (p.Register || p.Rule) {
+			cid, err = pierContainerID(repoRoot, p.Appchain)
+			if err != nil {
+				return err
+			}
+		}
+
+		if p.Register {
+			if err := registerPier(repoRoot, p.Appchain, p.Target, p.UpType, p.Method, p.Version, cid); err != nil {
+				return fmt.Errorf("register pier of %s: %w", p.Appchain, err)
+			}
+		}
+
+		if p.Rule {
+			if err := deployPierRule(repoRoot, p.Appchain, p.Target, p.RuleRepo, p.UpType, p.Method, p.Version, cid); err != nil {
+				return fmt.Errorf("deploy rule of %s: %w", p.Appchain, err)
+			}
+		}
+	}
+
+	color.Green("Topology is up, you can use the \"goduck status list\" command to check the status of the components.")
+	return nil
+}
+
+func topologyDown(ctx *cli.Context) error {
+	repoRoot, topo, err := loadTopology(ctx)
+	if err != nil {
+		return err
+	}
+	clean := ctx.Bool("clean")
+
+	var errs []string
+	for i := len(topo.Piers) - 1; i >= 0; i-- {
+		p := topo.Piers[i]
+		stop := pier.StopPier
+		if clean {
+			stop = pier.CleanPier
+		}
+		if err := stop(repoRoot, p.Appchain); err != nil {
+			errs = append(errs, fmt.Sprintf("pier of %s: %s", p.Appchain, err))
+		}
+	}
+
+	for i := len(topo.Appchains) - 1; i >= 0; i-- {
+		appchain := topo.Appchains[i]
+		if err := stopTopologyAppchain(repoRoot, appchain, clean); err != nil {
+			errs = append(errs, fmt.Sprintf("appchain %s: %s", appchain.Type, err))
+		}
+	}
+
+	if topo.BitXHub != nil {
+		if err := stopBitXHubNetwork(repoRoot, nil); err != nil {
+			errs = append(errs, fmt.Sprintf("bitxhub: %s", err))
+		}
+		if clean {
+			args := []string{filepath.Join(repoRoot, types.PlaygroundScript), "clean"}
+			if err := utils.ExecuteShell(args, repoRoot); err != nil {
+				errs = append(errs, fmt.Sprintf("bitxhub: %s", err))
+			}
+			target, err := topologyBxhTarget(repoRoot, topo.BitXHub)
+			if err == nil {
+				err = releasePorts(repoRoot, target)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("bitxhub: %s", err))
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("topology down error:\n%s", strings.Join(errs, "\n"))
+	}
+
+	return nil
+}
+
+// topologyBxhTarget returns the absolute target of the bitxhub nodes, which
+// is the default one of the repo if not given
+func topologyBxhTarget(repoRoot string, bxh *TopologyBitXHub) (string, error) {
+	if bxh.Target == "" {
+		return filepath.Join(repoRoot, "bitxhub/.bitxhub"), nil
+	}
+	target, err := filepath.Abs(bxh.Target)
+	if err != nil {
+		return "", fmt.Errorf("get absolute target path: %w", err)
+	}
+
+	return target, nil
+}
+
+func loadTopology(ctx *cli.Context) (string, *Topology, error) {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return "", nil, fmt.Errorf("parse repo path error:%w", err)
+	}
+	if !fileutil.Exist(filepath.Join(repoRoot, types.PlaygroundScript)) {
+		return "", nil, fmt.Errorf("please `goduck init` first")
+	}
+
+	path := ctx.String("file")
+	if path == "" {
+		path = filepath.Join(repoRoot, topologyFile)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("read topology file: %w", err)
+	}
+
+	topo := &Topology{}
+	if err := yaml.Unmarshal(data, topo); err != nil {
+		return "", nil, fmt.Errorf("unmarshal topology file: %w", err)
+	}
+
+	if err := topo.complete(); err != nil {
+		return "", nil, fmt.Errorf("invalid topology file %s: %w", path, err)
+	}
+
+	m, err := release.Load(repoRoot)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := topo.checkReleases(m); err != nil {
+		return "", nil, fmt.Errorf("invalid topology file %s: %w", path, err)
+	}
+
+	return repoRoot, topo, nil
+}
+
+// complete checks the topology and fills in default values.
+func (t *Topology) complete() error {
+	version := "v2.8.0"
+	if t.BitXHub != nil {
+		if t.BitXHub.Version == "" {
+			t.BitXHub.Version = version
+		}
+		version = t.BitXHub.Version
+
+		if t.BitXHub.Type == "" {
+			t.BitXHub.Type = types.TypeBinary
+		}
+		if t.BitXHub.Type != types.TypeBinary && t.BitXHub.Type != types.TypeDocker {
+			return fmt.Errorf("bitxhub type should be binary or docker")
+		}
+		if t.BitXHub.Mode != "" && t.BitXHub.Mode != types.SoloMode && t.BitXHub.Mode != types.ClusterMode {
+			return fmt.Errorf("bitxhub mode should be solo or cluster")
+		}
+	}
+
+	appchains := make(map[string]bool)
+	for _, appchain := range t.Appchains {
+		switch appchain.Type {
+		case types.ChainTypeEther:
+			if appchain.UpType == "" {
+				appchain.UpType = types.TypeDocker
+			}
+			if appchain.HttpPort == "" {
+				appchain.HttpPort = defaultEthereumHttpPort
+			}
+			if appchain.WsPort == "" {
+				appchain.WsPort = defaultEthereumWsPort
+			}
+			if appchain.Port == "" {
+				appchain.Port = defaultEthereumListenPort
+			}
+		case types.ChainTypeFabric:
+			appchain.UpType = types.TypeDocker
+		default:
+			return fmt.Errorf("unsupported appchain type %s", appchain.Type)
+		}
+
+		if appchains[appchain.Type] {
+			return fmt.Errorf("duplicate appchain %s", appchain.Type)
+		}
+		appchains[appchain.Type] = true
+	}
+
+	piers := make(map[string]bool)
+	for _, p := range t.Piers {
+		if p.Appchain != types.ChainTypeEther && p.Appchain != types.ChainTypeFabric {
+			return fmt.Errorf("unsupported pier appchain %s", p.Appchain)
+		}
+		if piers[p.Appchain] {
+			return fmt.Errorf("duplicate pier of %s", p.Appchain)
+		}
+		piers[p.Appchain] = true
+
+		if p.Version == "" {
+			p.Version = version
+		}
+		if p.UpType == "" {
+			p.UpType = types.TypeBinary
+		}
+		if p.Method == "" {
+			p.Method = defaultPierMethod
+		}
+	}
+
+	return nil
+}
+
+// checkReleases checks the versions of the topology are supported and
+// compatible with each other, so nothing is started if they are not.
+func (t *Topology) checkReleases(m *release.Manifest) error {
+	bxhVersion := ""
+	if t.BitXHub != nil {
+		bxhVersion = t.BitXHub.Version
+		if err := m.Check(bxhVersion, "", ""); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range t.Piers {
+		if err := m.Check(bxhVersion, p.Version, p.Appchain); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// topologyBxhConfig writes a copy of the bitxhub modify config with the mode
+// and node number of the topology.
+func topologyBxhConfig(repoRoot string, bxh *TopologyBitXHub) (string, error) {
+	configPath := bxh.ConfigPath
+	if configPath == "" {
+		r, err := bxhRelease(repoRoot, bxh.Version)
+		if err != nil {
+			return "", err
+		}
+		configPath = filepath.Join(repoRoot, fmt.Sprintf("bxh_config/%s/%s", r.Config, types.BxhModifyConfig))
+	}
+
+	config, err := repo.LoadModifyConfig(configPath)
+	if err != nil {
+		return "", err
+	}
+
+	if bxh.Mode != "" {
+		if err := config.Set("bitxhub.mode", bxh.Mode); err != nil {
+			return "", err
+		}
+	}
+	if bxh.Num != 0 {
+		// the ports of the missing binary nodes are allocated on start
+		if bxh.Type == types.TypeDocker && bxh.Num > len(config.Values("grpc_port")) {
+			return "", fmt.Errorf("%s only configures %d nodes, but %d nodes are required", configPath, len(config.Values("grpc_port")), bxh.Num)
+		}
+		if err := config.Set("bitxhub.num", strconv.Itoa(bxh.Num)); err != nil {
+			return "", err
+		}
+	}
+
+	path := filepath.Join(repoRoot, types.BitXHub, topologyBxhModifyConfig)
+	if err := config.Write(path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+func startTopologyAppchain(repoRoot string, topo *Topology, appchain *TopologyAppchain) error {
+	switch appchain.Type {
+	case types.ChainTypeEther:
+		bxhVersion := "v2.8.0"
+		if topo.BitXHub != nil {
+			bxhVersion = topo.BitXHub.Version
+		}
+		if appchain.UpType == types.TypeBinary {
+			if err := ethereum.DownloadGethBinary(repoRoot); err != nil {
+				return fmt.Errorf("download binary error:%w", err)
+			}
+		}
+		ethVersion, err := ethContractVersion(repoRoot, bxhVersion)
+		if err != nil {
+			return err
+		}
+		return StartEthereum(repoRoot, appchain.UpType, appchain.HttpPort, appchain.WsPort, appchain.Port, ethVersion)
+	case types.ChainTypeFabric:
+		return fabric.Start(repoRoot, appchain.CryptoConfig)
+	}
+
+	return nil
+}
+
+func stopTopologyAppchain(repoRoot string, appchain *TopologyAppchain, clean bool) error {
+	switch appchain.Type {
+	case types.ChainTypeEther:
+		if clean {
+			return CleanEthereum(repoRoot)
+		}
+		return StopEthereum(repoRoot)
+	case types.ChainTypeFabric:
+		if clean {
+			return fabric.Clean(repoRoot)
+		}
+		return fabric.Stop(repoRoot)
+	}
+
+	return nil
+}
+
+func appchainAddrs(appchain *TopologyAppchain) []string {
+	switch appchain.Type {
+	case types.ChainTypeEther:
+		return []string{net.JoinHostPort("127.0.0.1", appchain.WsPort)}
+	case types.ChainTypeFabric:
+		return []string{
+			net.JoinHostPort("127.0.0.1", defaultFabricOrdererPort),
+			net.JoinHostPort("127.0.0.1", defaultFabricPeerPort),
+		}
+	}
+
+	return nil
+}
+
+// waitBitXHub waits until the grpc ports of all bitxhub nodes are listening.
+func waitBitXHub(configPath string, timeout time.Duration) error {
+	config, err := repo.LoadModifyConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	num := 1
+	if config.Get("bitxhub.mode") == types.ClusterMode {
+		num, err = config.Int("bitxhub.num")
+		if err != nil {
+			return err
+		}
+	}
+
+	ports := config.Values("grpc_port")
+	if len(ports) < num {
+		return fmt.Errorf("%s only configures %d grpc ports", configPath, len(ports))
+	}
+
+	var addrs []string
+	for _, port := range ports[:num] {
+		addrs = append(addrs, net.JoinHostPort("127.0.0.1", port))
+	}
+
+	if err := waitForAddrs(addrs, timeout); err != nil {
+		return fmt.Errorf("bitxhub is not ready: %w", err)
+	}
+
+	return nil
+}
+
+// waitPier waits until the pier process or container keeps running for a while.
+func waitPier(repoRoot string, p *TopologyPier, timeout time.Duration) error {
+	const stable = 3
+
+	deadline := time.Now().Add(timeout)
+	count := 0
+	for time.Now().Before(deadline) {
+		if pierRunning(repoRoot, p) {
+			count++
+			if count == stable {
+				return nil
+			}
+		} else {
+			count = 0
+		}
+		time.Sleep(time.Second)
+	}
+
+	return fmt.Errorf("pier of %s is not ready in %s", p.Appchain, timeout)
+}
+
+func pierRunning(repoRoot string, p *TopologyPier) bool {
+	if p.UpType == types.TypeDocker {
+		cid, err := pierContainerID(repoRoot, p.Appchain)
+		return err == nil && cid != ""
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(repoRoot, types.Pier, fmt.Sprintf("pier-%s.pid", p.Appchain)))
+	if err != nil {
+		return false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return false
+	}
+
+	return bitxhub.ProcessExists(pid)
+}
+
+// pierContainerID returns the id of the running pier container of the appchain.
+func pierContainerID(repoRoot, chainType string) (string, error) {
+	task := utils.ExecTask{
+		Command: "docker",
+		Args:    []string{"ps", "-q", "-f", fmt.Sprintf("name=pier-%s", chainType)},
+		Repo:    repoRoot,
+	}
+	result, err := task.Execute()
+	if err != nil {
+		return "", fmt.Errorf("docker ps error:%w", err)
+	}
+
+	cid := strings.TrimSpace(result.Stdout)
+	if cid == "" {
+		return "", fmt.Errorf("pier-%s container is not running", chainType)
+	}
+
+	return strings.Fields(cid)[0], nil
+}
+
+func waitForAddrs(addrs []string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for _, addr := range addrs {
+		for {
+			conn, err := net.DialTimeout("tcp", addr, time.Second)
+			if err == nil {
+				conn.Close()
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("wait for %s timeout: %w", addr, err)
+			}
+			time.Sleep(time.Second)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/bitxhub-kit/crypto/asym"
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	libp2pcert "github.com/meshplus/go-libp2p-cert"
+	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/pelletier/go-toml"
+	"github.com/spf13/viper"
+	"github.com/urfave/cli/v2"
+)
+
+// nodeConfig is the configuration of a node loaded for validation
+type nodeConfig struct {
+	name    string
+	id      int
+	root    string
+	pid     string
+	account string
+	// peers are the nodes in network.toml
+	peers []*NetworkNodes
+	// isNew means the node joined the running cluster after the genesis
+	isNew bool
+	// admins are the genesis admins in bitxhub.toml
+	admins []string
+	// ports are the ports in bitxhub.toml keyed by their names
+	ports map[string]int
+}
+
+func validateBitXHubConfig(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return fmt.Errorf("parse repo path error:%w", err)
+	}
+
+	target := ctx.String("target")
+	if target == "" {
+		target = filepath.Join(repoRoot, "bitxhub/.bitxhub")
+	}
+	if target, err = filepath.Abs(target); err != nil {
+		return fmt.Errorf("get absolute target path: %w", err)
+	}
+
+	names, err := bitxhubNodeNames(target)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	var nodes []*nodeConfig
+	for i, name := range names {
+		node, errs := loadNodeConfig(filepath.Join(target, name), i+1)
+		for _, err := range errs {
+			report("%s: %s", name, err)
+		}
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+
+	validateNetwork(nodes, report)
+	validateAdmins(nodes, report)
+	validatePorts(nodes, report)
+
+	if len(problems) != 0 {
+		for _, problem := range problems {
+			color.Red(problem)
+		}
+		return fmt.Errorf("%d problems found in %s", len(problems), target)
+	}
+
+	color.Green("%d BitXHub nodes in %s are valid", len(nodes), target)
+	return nil
+}
+
+// bitxhubNodeNames returns the names of the nodes in the target in node order
+func bitxhubNodeNames(target string) ([]string, error) {
+	var names []string
+	if fileutil.Exist(filepath.Join(target, bitxhub.NodeName(types.SoloMode, 1))) {
+		names = append(names, bitxhub.NodeName(types.SoloMode, 1))
+	}
+	for i := 1; fileutil.Exist(filepath.Join(target, bitxhub.NodeName(types.ClusterMode, i))); i++ {
+		names = append(names, bitxhub.NodeName(types.ClusterMode, i))
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no bitxhub node found in %s", target)
+	}
+
+	return names, nil
+}
+
+// loadNodeConfig loads the configs of the node and checks its certs, the
+// node is nil if its configs can not be loaded.
+func loadNodeConfig(root string, id int) (*nodeConfig, []error) {
+	node := &nodeConfig{
+		name:  filepath.Base(root),
+		id:    id,
+		root:  root,
+		ports: make(map[string]int),
+	}
+	var errs []error
+
+	certRoot := filepath.Join(root, "certs")
+	pid, err := getPidFromPrivateKey(repo.GetPrivKeyPath("node", certRoot))
+	if err != nil {
+		return nil, append(errs, fmt.Errorf("get pid from node.priv: %w", err))
+	}
+	node.pid = pid
+
+	if node.account, err = nodeAccount(root); err != nil {
+		return nil, append(errs, fmt.Errorf("get account: %w", err))
+	}
+
+	if err := verifyNodeCerts(certRoot); err != nil {
+		errs = append(errs, err)
+	}
+
+	v := viper.New()
+	v.SetConfigFile(filepath.Join(root, repo.BitXHubConfigName))
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, append(errs, fmt.Errorf("read %s: %w", repo.BitXHubConfigName, err))
+	}
+	for _, key := range []string{"jsonrpc", "grpc", "gateway", "pprof", "monitor"} {
+		if port := v.GetInt("port." + key); port != 0 {
+			node.ports[key] = port
+		}
+	}
+	genesis := &Genesis{}
+	if err := v.UnmarshalKey("genesis", genesis); err == nil && len(genesis.Admins) != 0 {
+		for _, admin := range genesis.Admins {
+			node.admins = append(node.admins, admin.Address)
+		}
+	} else {
+		// the genesis of the older versions is a list of addresses
+		node.admins = v.GetStringSlice("genesis.addresses")
+	}
+
+	networkPath := filepath.Join(root, repo.NetworkConfigName)
+	if !fileutil.Exist(networkPath) {
+		return node, errs
+	}
+	data, err := ioutil.ReadFile(networkPath)
+	if err != nil {
+		return nil, append(errs, err)
+	}
+	network := &NetworkConfig{}
+	if err := toml.Unmarshal(data, network); err != nil {
+		return nil, append(errs, fmt.Errorf("unmarshal %s: %w", repo.NetworkConfigName, err))
+	}
+	if network.ID != 0 && int(network.ID) != id {
+		errs = append(errs, fmt.Errorf("id in %s is %d, but it is node %d", repo.NetworkConfigName, network.ID, id))
+	}
+	if network.N != 0 && int(network.N) != len(network.Nodes) {
+		errs = append(errs, fmt.Errorf("n in %s is %d, but there are %d nodes", repo.NetworkConfigName, network.N, len(network.Nodes)))
+	}
+	node.peers = network.Nodes
+	node.isNew = network.New
+
+	return node, errs
+}
+
+// nodeAccount returns the account of the node from key.priv, or key.json
+// generated by the bitxhub binary, or node.priv of the older versions.
+func nodeAccount(root string) (string, error) {
+	certRoot := filepath.Join(root, "certs")
+	if path := repo.GetPrivKeyPath(repo.KeyPriv, certRoot); fileutil.Exist(path) {
+		return getAddressFromPrivateKey(path, crypto.Secp256k1)
+	}
+
+	if path := filepath.Join(root, repo.KeyName); fileutil.Exist(path) {
+		privKey, err := asym.RestorePrivateKey(path, repo.KeyPassword)
+		if err != nil {
+			return "", fmt.Errorf("restore %s: %w", repo.KeyName, err)
+		}
+		addr, err := privKey.PublicKey().Address()
+		if err != nil {
+			return "", err
+		}
+		return addr.String(), nil
+	}
+
+	return getAddressFromPrivateKey(repo.GetPrivKeyPath("node", certRoot), crypto.ECDSA_P521)
+}
+
+// verifyNodeCerts checks node.cert is issued by agency.cert which is issued by ca.cert
+func verifyNodeCerts(certRoot string) error {
+	certs := make(map[string]string)
+	for _, name := range []string{"node", repo.AgencyName, "ca"} {
+		certs[name] = repo.GetCertPath(name, certRoot)
+	}
+
+	nodeCert, err := loadCertFile(certs["node"])
+	if err != nil {
+		return err
+	}
+	agencyCert, err := loadCertFile(certs[repo.AgencyName])
+	if err != nil {
+		return err
+	}
+	caCert, err := loadCertFile(certs["ca"])
+	if err != nil {
+		return err
+	}
+
+	if err := libp2pcert.VerifySign(nodeCert, agencyCert); err != nil {
+		return fmt.Errorf("node.cert is not issued by agency.cert: %w", err)
+	}
+	if err := libp2pcert.VerifySign(agencyCert, caCert); err != nil {
+		return fmt.Errorf("agency.cert is not issued by ca.cert: %w", err)
+	}
+
+	return nil
+}
+
+func loadCertFile(path string) (*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cert: %w", err)
+	}
+	c, err := libp2pcert.ParseCert(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+	}
+
+	return c, nil
+}
+
+func validateNetwork(nodes []*nodeConfig, report func(string, ...interface{})) {
+	byID := make(map[int]*nodeConfig)
+	for _, node := range nodes {
+		byID[node.id] = node
+	}
+
+	for _, node := range nodes {
+		for _, peer := range node.peers {
+			other, ok := byID[int(peer.ID)]
+			if !ok {
+				report("%s: node %d in %s is not found", node.name, peer.ID, repo.NetworkConfigName)
+				continue
+			}
+			if peer.Pid != other.pid {
+				report("%s: pid of node %d in %s is %s, but %s is derived from its node.priv", node.name, peer.ID, repo.NetworkConfigName, peer.Pid, other.pid)
+			}
+			if peer.Account != "" && !strings.EqualFold(peer.Account, other.account) {
+				report("%s: account of node %d in %s is %s, but %s is derived from its key", node.name, peer.ID, repo.NetworkConfigName, peer.Account, other.account)
+			}
+		}
+	}
+}
+
+// validateAdmins checks the accounts of the genesis nodes are genesis admins,
+// the nodes added by `bitxhub node add` join after the genesis and are not.
+func validateAdmins(nodes []*nodeConfig, report func(string, ...interface{})) {
+	for _, node := range nodes {
+		admins := make(map[string]bool)
+		for _, admin := range node.admins {
+			admins[strings.ToLower(admin)] = true
+		}
+		for _, other := range nodes {
+			if other.isNew {
+				continue
+			}
+			if !admins[strings.ToLower(other.account)] {
+				report("%s: account %s of %s is not a genesis admin", node.name, other.account, other.name)
+			}
+		}
+	}
+}
+
+// validatePorts checks the ports are unique on each host, the p2p ports are
+// the ones in network.toml of the first node.
+func validatePorts(nodes []*nodeConfig, report func(string, ...interface{})) {
+	if len(nodes) == 0 {
+		return
+	}
+
+	hosts := make(map[int]string)
+	for _, peer := range nodes[0].peers {
+		for _, host := range peer.Hosts {
+			parts := strings.Split(host, "/")
+			if len(parts) > 4 && parts[1] == "ip4" {
+				hosts[int(peer.ID)] = parts[2]
+				var p2p int
+				if _, err := fmt.Sscanf(parts[4], "%d", &p2p); err == nil {
+					for _, node := range nodes {
+						if node.id == int(peer.ID) {
+							node.ports["p2p"] = p2p
+						}
+					}
+				}
+			}
+		}
+	}
+
+	used := make(map[string]string)
+	for _, node := range nodes {
+		host, ok := hosts[node.id]
+		if !ok {
+			host = "127.0.0.1"
+		}
+
+		keys := make([]string, 0, len(node.ports))
+		for key := range node.ports {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			addr := fmt.Sprintf("%s:%d", host, node.ports[key])
+			usage := fmt.Sprintf("%s port of %s", key, node.name)
+			if other, ok := used[addr]; ok {
+				report("%s: %s is used by both the %s and the %s", node.name, addr, other, usage)
+				continue
+			}
+			used[addr] = usage
+		}
+	}
+}
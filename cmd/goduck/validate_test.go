@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/pelletier/go-toml"
+	"github.com/stretchr/testify/require"
+)
+
+// testNode is a node generated by newTestCluster
+type testNode struct {
+	root    string
+	pid     string
+	account string
+}
+
+// newTestCluster generates the certs and keys of a cluster of n genesis
+// nodes in target the way bxh_config.sh does, with the genesis admins in
+// bitxhub.toml and the peers in network.toml.
+func newTestCluster(t *testing.T, target string, n int) []*testNode {
+	require.Nil(t, os.MkdirAll(target, 0755))
+	caPriv, caCert, err := generateCA(target)
+	require.Nil(t, err)
+	agencyPriv, agencyCert, err := generateCert(repo.AgencyName, strings.ToUpper(repo.AgencyName), target, caPriv, caCert, true)
+	require.Nil(t, err)
+
+	var nodes []*testNode
+	for i := 1; i <= n; i++ {
+		nodes = append(nodes, newTestNode(t, target, i, agencyPriv, agencyCert))
+	}
+	for i, node := range nodes {
+		writeTestNodeConfig(t, node, i+1, nodes, nodes, false)
+	}
+
+	return nodes
+}
+
+// newTestNode generates the certs and the key of the ith node
+func newTestNode(t *testing.T, target string, i int, agencyPriv, agencyCert string) *testNode {
+	root := filepath.Join(target, fmt.Sprintf("node%d", i))
+	certRoot := filepath.Join(root, "certs")
+	require.Nil(t, os.MkdirAll(certRoot, 0755))
+
+	_, _, err := generateCert("node", fmt.Sprintf("Node%d", i), certRoot, agencyPriv, agencyCert, false)
+	require.Nil(t, err)
+	require.Nil(t, copyFile(repo.GetCACertPath(certRoot), repo.GetCACertPath(target)))
+	require.Nil(t, copyFile(repo.GetCertPath(repo.AgencyName, certRoot), agencyCert))
+	require.Nil(t, generatePrivKey(repo.KeyPriv, certRoot, crypto.Secp256k1))
+
+	pid, err := getPidFromPrivateKey(repo.GetPrivKeyPath("node", certRoot))
+	require.Nil(t, err)
+	account, err := getAddressFromPrivateKey(repo.GetPrivKeyPath(repo.KeyPriv, certRoot), crypto.Secp256k1)
+	require.Nil(t, err)
+
+	return &testNode{root: root, pid: pid, account: account}
+}
+
+// writeTestNodeConfig writes bitxhub.toml with the admins and network.toml
+// with the peers of the ith node
+func writeTestNodeConfig(t *testing.T, node *testNode, i int, admins, peers []*testNode, isNew bool) {
+	config := fmt.Sprintf("[port]\n  jsonrpc = %d\n  grpc = %d\n\n[genesis]\n", 8880+i, 60010+i)
+	for _, admin := range admins {
+		config += fmt.Sprintf("  [[genesis.admins]]\n    address = %q\n    weight = 1\n", admin.account)
+	}
+	require.Nil(t, ioutil.WriteFile(filepath.Join(node.root, repo.BitXHubConfigName), []byte(config), 0644))
+
+	network := &NetworkConfig{ID: uint64(i), N: uint64(len(peers)), New: isNew}
+	for j, peer := range peers {
+		network.Nodes = append(network.Nodes, &NetworkNodes{
+			ID:      uint64(j + 1),
+			Pid:     peer.pid,
+			Hosts:   []string{fmt.Sprintf("/ip4/127.0.0.1/tcp/%d/p2p/", 4000+j+1)},
+			Account: peer.account,
+		})
+	}
+	data, err := toml.Marshal(network)
+	require.Nil(t, err)
+	require.Nil(t, ioutil.WriteFile(filepath.Join(node.root, repo.NetworkConfigName), data, 0644))
+}
+
+// validateTestCluster runs the checks of `bitxhub config validate`
+func validateTestCluster(t *testing.T, target string) []string {
+	names, err := bitxhubNodeNames(target)
+	require.Nil(t, err)
+
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	var nodes []*nodeConfig
+	for i, name := range names {
+		node, errs := loadNodeConfig(filepath.Join(target, name), i+1)
+		for _, err := range errs {
+			report("%s: %s", name, err)
+		}
+		require.NotNil(t, node)
+		nodes = append(nodes, node)
+	}
+	validateNetwork(nodes, report)
+	validateAdmins(nodes, report)
+	validatePorts(nodes, report)
+
+	return problems
+}
+
+func TestValidateAddedNode(t *testing.T) {
+	target, err := ioutil.TempDir("", "validate")
+	require.Nil(t, err)
+	defer os.RemoveAll(target)
+
+	genesis := newTestCluster(t, target, 4)
+	require.Empty(t, validateTestCluster(t, target))
+
+	// node5 joins the running cluster by `bitxhub node add`, it shares the
+	// genesis of the cluster which does not have its account
+	agencyPriv := repo.GetPrivKeyPath(repo.AgencyName, target)
+	agencyCert := repo.GetCertPath(repo.AgencyName, target)
+	added := newTestNode(t, target, 5, agencyPriv, agencyCert)
+	writeTestNodeConfig(t, added, 5, genesis, append(genesis, added), true)
+	require.Empty(t, validateTestCluster(t, target))
+
+	// a genesis node whose account is not a genesis admin is still reported
+	writeTestNodeConfig(t, added, 5, genesis, append(genesis, added), false)
+	problems := validateTestCluster(t, target)
+	require.Equal(t, 5, len(problems), strings.Join(problems, "\n"))
+	for _, problem := range problems {
+		require.Contains(t, problem, fmt.Sprintf("account %s of node5 is not a genesis admin", added.account))
+	}
+}
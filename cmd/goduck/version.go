@@ -4,12 +4,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 
 	"github.com/meshplus/bitxhub-kit/fileutil"
 	"github.com/meshplus/goduck"
+	"github.com/meshplus/goduck/internal/release"
 	"github.com/meshplus/goduck/internal/repo"
 	"github.com/meshplus/goduck/internal/types"
-	"github.com/meshplus/goduck/internal/utils"
 	"github.com/urfave/cli/v2"
 )
 
@@ -57,10 +58,39 @@ func getVersionCMD() *cli.Command {
 				},
 				Action: pierVersion,
 			},
+			{
+				Name:  "compat",
+				Usage: "Show the compatible releases, or validate a combination of them",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "bitxhub",
+						Usage: "Specify the BitXHub version",
+					},
+					&cli.StringFlag{
+						Name:  "pier",
+						Usage: "Specify the pier version",
+					},
+					&cli.StringFlag{
+						Name:  "appchain",
+						Usage: "Specify appchain type whose plugin is required, one of ethereum or fabric",
+					},
+				},
+				Action: compatVersion,
+			},
 		},
 	}
 }
 
+// VersionInfo is the version of a component.
+type VersionInfo struct {
+	Component string `json:"component" yaml:"component"`
+	Mode      string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Version   string `json:"version" yaml:"version"`
+	BuildDate string `json:"build_date" yaml:"build_date"`
+	System    string `json:"system" yaml:"system"`
+	GoVersion string `json:"go_version" yaml:"go_version"`
+}
+
 func allVersion(ctx *cli.Context) error {
 	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
 	if err != nil {
@@ -70,19 +100,36 @@ func allVersion(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, "release.json"))
+	m, err := release.Load(repoRoot)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(string(data))
-	return nil
+	return printOutput(ctx, m, allVersionTable(m))
+}
+
+// allVersionTable is the table of the versions in the release manifest
+func allVersionTable(m *release.Manifest) [][]string {
+	return [][]string{
+		{"Component", "Versions"},
+		{"BitXHub", strings.Join(m.BitXHubVersions(), ", ")},
+		{"Pier", strings.Join(m.PierVersions(), ", ")},
+	}
 }
 
 func goduckVersion(ctx *cli.Context) error {
-	printVersion()
+	if ctx.String("output") == OutputTable {
+		printVersion()
+		return nil
+	}
 
-	return nil
+	return printOutput(ctx, &VersionInfo{
+		Component: "goduck",
+		Version:   fmt.Sprintf("%s-%s-%s", goduck.CurrentVersion, goduck.CurrentBranch, goduck.CurrentCommit),
+		BuildDate: goduck.BuildDate,
+		System:    goduck.Platform,
+		GoVersion: goduck.GoVersion,
+	}, nil)
 }
 
 func bxhVersion(ctx *cli.Context) error {
@@ -95,12 +142,8 @@ func bxhVersion(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	args := []string{types.VersionScript, "bxh", upType}
-	if err := utils.ExecuteShell(args, repoRoot); err != nil {
-		return err
-	}
-
-	return nil
+	path := filepath.Join(repoRoot, "bitxhub", fmt.Sprintf("bitxhub-%s.version", upType))
+	return printComponentVersion(ctx, "bitxhub", upType, path)
 }
 
 func pierVersion(ctx *cli.Context) error {
@@ -114,12 +157,56 @@ func pierVersion(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	args := []string{types.VersionScript, "pier", upType, appchain}
-	if err := utils.ExecuteShell(args, repoRoot); err != nil {
+	path := filepath.Join(repoRoot, "pier", fmt.Sprintf("pier-%s-%s.version", appchain, upType))
+	return printComponentVersion(ctx, fmt.Sprintf("pier-%s", appchain), upType, path)
+}
+
+func printComponentVersion(ctx *cli.Context, component, upType, path string) error {
+	if !fileutil.Exist(path) {
+		return fmt.Errorf("%s is not started in %s", component, upType)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	info := parseVersion(string(data))
+	info.Component = component
+	info.Mode = upType
+
+	table := [][]string{
+		{"Component", "Mode", "Version", "Build Date", "System", "Go Version"},
+		{info.Component, info.Mode, info.Version, info.BuildDate, info.System, info.GoVersion},
+	}
+
+	return printOutput(ctx, info, table)
+}
+
+// parseVersion parses the "Xxx version: v1.11.0" style lines printed by `bitxhub version` or `pier version`
+func parseVersion(data string) *VersionInfo {
+	info := &VersionInfo{}
+	for _, line := range strings.Split(data, "\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		value := strings.TrimSpace(kv[1])
+		switch {
+		case strings.HasSuffix(key, "build date"):
+			info.BuildDate = value
+		case strings.HasPrefix(key, "system"):
+			info.System = value
+		case strings.HasPrefix(key, "golang"):
+			info.GoVersion = value
+		case strings.HasSuffix(key, "version"):
+			info.Version = value
+		}
+	}
+
+	return info
 }
 
 func printVersion() {
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cheynewallace/tabby"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// maxWatchEvents is the max number of events printed below the table
+	maxWatchEvents = 20
+
+	clearScreen = "\033[H\033[2J"
+)
+
+// statusEvent is a state transition of a component found between two refreshes.
+type statusEvent struct {
+	time    time.Time
+	key     string
+	message string
+	color   *color.Color
+}
+
+var (
+	upColor      = color.New(color.FgGreen, color.Bold)
+	downColor    = color.New(color.FgRed, color.Bold)
+	restartColor = color.New(color.FgYellow, color.Bold)
+)
+
+// statusKey identifies a component across refreshes, the repo tells apart the
+// components of the same name, e.g. node1 of the clusters in two targets.
+func statusKey(s *ComponentStatus) string {
+	return s.Mode + "/" + s.Name + "/" + s.Repo
+}
+
+// watchStatus redraws the status table every interval until it is interrupted.
+func watchStatus(ctx *cli.Context) error {
+	if ctx.String("output") != OutputTable {
+		return fmt.Errorf("--watch only supports table output")
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	interval := ctx.Duration("interval")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		statuses []*ComponentStatus
+		prev     map[string]*ComponentStatus
+		events   []*statusEvent
+	)
+	for {
+		now := time.Now()
+		var changed []*statusEvent
+		cur, err := listComponents(c)
+		switch {
+		case c.Err() != nil:
+			return nil
+		case err != nil:
+			// a failed refresh is logged and the last statuses are kept,
+			// the next refresh may succeed
+			events = append(events, &statusEvent{
+				time:    now,
+				message: fmt.Sprintf("refresh failed: %s", err),
+				color:   downColor,
+			})
+		default:
+			statuses = cur
+			curStatus := make(map[string]*ComponentStatus, len(statuses))
+			for _, s := range statuses {
+				curStatus[statusKey(s)] = s
+			}
+			if prev != nil {
+				changed = diffStatus(prev, curStatus, now)
+			}
+			events = append(events, changed...)
+			prev = curStatus
+		}
+		if len(events) > maxWatchEvents {
+			events = events[len(events)-maxWatchEvents:]
+		}
+
+		drawStatus(statuses, changed, events, interval, now)
+
+		select {
+		case <-c.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// diffStatus returns the transitions from prev to cur: new and removed
+// components, status changes and restarts which change the pid or container id.
+func diffStatus(prev, cur map[string]*ComponentStatus, now time.Time) []*statusEvent {
+	var events []*statusEvent
+	add := func(key string, c *color.Color, format string, a ...interface{}) {
+		events = append(events, &statusEvent{
+			time:    now,
+			key:     key,
+			message: fmt.Sprintf(format, a...),
+			color:   c,
+		})
+	}
+
+	for key, s := range cur {
+		old, ok := prev[key]
+		switch {
+		case !ok:
+			add(key, upColor, "%s %s %s appeared (%s)", s.Mode, s.Component, s.Name, s.Status)
+		case old.ID != s.ID:
+			add(key, restartColor, "%s %s restarted (%s -> %s)", s.Component, s.Name, old.ID, s.ID)
+		case old.Status != s.Status:
+			c := restartColor
+			if s.Status == "running" {
+				c = upColor
+			} else if s.Status == "exited" || s.Status == "dead" {
+				c = downColor
+			}
+			add(key, c, "%s %s %s -> %s", s.Component, s.Name, old.Status, s.Status)
+		}
+	}
+
+	for key, old := range prev {
+		if _, ok := cur[key]; !ok {
+			add(key, downColor, "%s %s %s is gone (was %s)", old.Mode, old.Component, old.Name, old.Status)
+		}
+	}
+
+	return events
+}
+
+func drawStatus(statuses []*ComponentStatus, changed, events []*statusEvent, interval time.Duration, now time.Time) {
+	highlights := make(map[string]*color.Color, len(changed))
+	for _, e := range changed {
+		highlights[e.key] = e.color
+	}
+
+	var buf bytes.Buffer
+	t := tabby.NewCustom(tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0))
+	addRow(t, statusHeader, true)
+	for _, s := range statuses {
+		addRow(t, s.row(), false)
+	}
+	t.Print()
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	var out strings.Builder
+	out.WriteString(clearScreen)
+	out.WriteString(fmt.Sprintf("Every %s: goduck status list    %s\n\n", interval, now.Format(time.RFC3339)))
+	for i, line := range lines {
+		// the first two lines are the header and the separator
+		if i >= 2 {
+			if c, ok := highlights[statusKey(statuses[i-2])]; ok {
+				line = c.Sprint(line)
+			}
+		}
+		out.WriteString(line + "\n")
+	}
+
+	out.WriteString("\nEvents:\n")
+	if len(events) == 0 {
+		out.WriteString("  no changes yet\n")
+	}
+	for _, e := range events {
+		out.WriteString(fmt.Sprintf("  %s  %s\n", e.time.Format("15:04:05"), e.color.Sprint(e.message)))
+	}
+
+	fmt.Print(out.String())
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffStatus(t *testing.T) {
+	nodeIn := func(repo, id, status string) *ComponentStatus {
+		return &ComponentStatus{Name: "node1", Component: "bitxhub", Mode: "binary", ID: id, Status: status, Repo: repo}
+	}
+	node := func(id, status string) *ComponentStatus {
+		return nodeIn("/tmp/.bitxhub/node1", id, status)
+	}
+	pier := func(id, status string) *ComponentStatus {
+		return &ComponentStatus{Name: "pier-ethereum", Component: "pier", Mode: "docker", ID: id, Status: status}
+	}
+	statuses := func(ss ...*ComponentStatus) map[string]*ComponentStatus {
+		m := make(map[string]*ComponentStatus, len(ss))
+		for _, s := range ss {
+			m[statusKey(s)] = s
+		}
+		return m
+	}
+
+	tests := []struct {
+		name     string
+		prev     map[string]*ComponentStatus
+		cur      map[string]*ComponentStatus
+		messages []string
+		colors   []string
+	}{
+		{
+			name: "unchanged",
+			prev: statuses(node("100", "running")),
+			cur:  statuses(node("100", "running")),
+		},
+		{
+			name:     "added",
+			prev:     statuses(node("100", "running")),
+			cur:      statuses(node("100", "running"), pier("abc", "running")),
+			messages: []string{"docker pier pier-ethereum appeared (running)"},
+			colors:   []string{"up"},
+		},
+		{
+			name:     "removed",
+			prev:     statuses(node("100", "running"), pier("abc", "running")),
+			cur:      statuses(node("100", "running")),
+			messages: []string{"docker pier pier-ethereum is gone (was running)"},
+			colors:   []string{"down"},
+		},
+		{
+			name:     "restarted",
+			prev:     statuses(node("100", "running")),
+			cur:      statuses(node("200", "running")),
+			messages: []string{"bitxhub node1 restarted (100 -> 200)"},
+			colors:   []string{"restart"},
+		},
+		{
+			name:     "exited",
+			prev:     statuses(pier("abc", "running")),
+			cur:      statuses(pier("abc", "exited")),
+			messages: []string{"pier pier-ethereum running -> exited"},
+			colors:   []string{"down"},
+		},
+		{
+			name:     "up again",
+			prev:     statuses(pier("abc", "restarting")),
+			cur:      statuses(pier("abc", "running")),
+			messages: []string{"pier pier-ethereum restarting -> running"},
+			colors:   []string{"up"},
+		},
+		{
+			name: "added and removed",
+			prev: statuses(node("100", "running")),
+			cur:  statuses(pier("abc", "running")),
+			messages: []string{
+				"binary bitxhub node1 is gone (was running)",
+				"docker pier pier-ethereum appeared (running)",
+			},
+			colors: []string{"down", "up"},
+		},
+		{
+			name:     "same name in another target",
+			prev:     statuses(node("100", "running"), nodeIn("/tmp/other/node1", "300", "running")),
+			cur:      statuses(node("100", "running"), nodeIn("/tmp/other/node1", "300", "exited")),
+			messages: []string{"bitxhub node1 running -> exited"},
+			colors:   []string{"down"},
+		},
+		{
+			name:     "same name in another target is gone",
+			prev:     statuses(node("100", "running"), nodeIn("/tmp/other/node1", "300", "running")),
+			cur:      statuses(node("100", "running")),
+			messages: []string{"binary bitxhub node1 is gone (was running)"},
+			colors:   []string{"down"},
+		},
+	}
+
+	colorNames := map[*color.Color]string{
+		upColor:      "up",
+		downColor:    "down",
+		restartColor: "restart",
+	}
+	now := time.Now()
+	for _, test := range tests {
+		events := diffStatus(test.prev, test.cur, now)
+		sort.Slice(events, func(i, j int) bool { return events[i].message < events[j].message })
+
+		var messages, colors []string
+		for _, e := range events {
+			require.Equal(t, now, e.time, test.name)
+			messages = append(messages, e.message)
+			colors = append(colors, colorNames[e.color])
+		}
+		require.Equal(t, test.messages, messages, test.name)
+		require.Equal(t, test.colors, colors, test.name)
+	}
+}
@@ -0,0 +1,106 @@
+package capability
+
+import (
+	"golang.org/x/mod/semver"
+)
+
+// Capability is a feature which only some releases of BitXHub or pier have.
+type Capability string
+
+const (
+	// PierAdminJSON means pier reads the admin.json in its repo
+	PierAdminJSON Capability = "pier_admin_json"
+	// PierNodeKey means pier generates a node key besides the account key
+	PierNodeKey Capability = "pier_node_key"
+	// PierUnionMode means pier can run in union mode
+	PierUnionMode Capability = "pier_union_mode"
+	// PierPluginSharedObject means pier loads the appchain plugin as a
+	// shared object instead of running it as a process
+	PierPluginSharedObject Capability = "pier_plugin_shared_object"
+	// PierInitMode means `pier init` takes the mode of the pier and writes
+	// the pier.toml of the mode
+	PierInitMode Capability = "pier_init_mode"
+
+	// Secp256k1NodeKey means the account of a BitXHub node is a Secp256k1
+	// key.priv, older nodes use their ECDSA P521 node.priv as the account
+	Secp256k1NodeKey Capability = "secp256k1_node_key"
+	// GenesisAdmins means the genesis is a list of admins with their weights
+	// and the voting strategies, older ones are a list of addresses
+	GenesisAdmins Capability = "genesis_admins"
+	// NetworkNodeList means the network.toml has the n, new and nodes with
+	// their pids, hosts and accounts
+	NetworkNodeList Capability = "network_node_list"
+	// NetworkAddrList means the network.toml of v1.1.0-rc1 which is a list of
+	// the addresses of the nodes
+	NetworkAddrList Capability = "network_addr_list"
+	// RBFTConsensus means BitXHub nodes can order with rbft besides raft
+	RBFTConsensus Capability = "rbft_consensus"
+)
+
+// Range is the versions from Since, inclusive, to Until, exclusive. An empty
+// bound is unlimited.
+type Range struct {
+	Since string
+	Until string
+}
+
+// Contains reports whether the version is in the range, an invalid version
+// is in no range.
+func (r Range) Contains(version string) bool {
+	v := semver.Canonical(version)
+	if v == "" {
+		return false
+	}
+	if r.Since != "" && semver.Compare(v, r.Since) < 0 {
+		return false
+	}
+	if r.Until != "" && semver.Compare(v, r.Until) >= 0 {
+		return false
+	}
+
+	return true
+}
+
+var registry = map[Capability]Range{
+	PierAdminJSON:          {Since: "v1.8.0"},
+	PierNodeKey:            {Since: "v1.4.0"},
+	PierUnionMode:          {Since: "v1.4.0"},
+	PierPluginSharedObject: {Until: "v1.1.0-rc1"},
+	PierInitMode:           {Since: "v1.11.3"},
+	Secp256k1NodeKey:       {Since: "v1.4.0"},
+	GenesisAdmins:          {Since: "v1.6.0"},
+	NetworkNodeList:        {Since: "v1.1.0"},
+	NetworkAddrList:        {Since: "v1.1.0-rc1", Until: "v1.1.0"},
+	RBFTConsensus:          {Since: "v1.11.0"},
+}
+
+// Has reports whether the release of the version has the capability
+func Has(version string, c Capability) bool {
+	r, ok := registry[c]
+	if !ok {
+		return false
+	}
+
+	return r.Contains(version)
+}
+
+// templates are the BitXHub config templates and the versions since which they are used
+var templates = []struct {
+	since string
+	dir   string
+}{
+	{"v1.6.0", "v1.6.0"},
+	{"v1.4.0", "v1.4.0"},
+	{"", "v1.1.0"},
+}
+
+// BitXHubTemplate returns the directory of the BitXHub config templates for the version
+func BitXHubTemplate(version string) string {
+	for _, t := range templates {
+		if (Range{Since: t.since}).Contains(version) {
+			return t.dir
+		}
+	}
+
+	return templates[len(templates)-1].dir
+}
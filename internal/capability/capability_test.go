@@ -0,0 +1,44 @@
+package capability
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHas(t *testing.T) {
+	// "v1.11.3" < "v1.8.0" as strings
+	require.True(t, Has("v1.11.3", PierAdminJSON))
+	require.True(t, Has("v1.8.0", PierAdminJSON))
+	require.False(t, Has("v1.7.0", PierAdminJSON))
+
+	require.True(t, Has("v1.23.0", Secp256k1NodeKey))
+	require.False(t, Has("v1.3.0", Secp256k1NodeKey))
+
+	require.True(t, Has("v1.11.0", GenesisAdmins))
+	require.False(t, Has("v1.5.0", GenesisAdmins))
+
+	require.True(t, Has("v2.8.0", PierUnionMode))
+	require.False(t, Has("v1.1.0", PierUnionMode))
+
+	require.True(t, Has("v1.23.0", PierInitMode))
+	require.False(t, Has("v1.11.1", PierInitMode))
+
+	// pre-releases are earlier than their releases
+	require.True(t, Has("v1.0.0-rc1", PierPluginSharedObject))
+	require.True(t, Has("v1.0.0", PierPluginSharedObject))
+	require.False(t, Has("v1.1.0-rc1", PierPluginSharedObject))
+	require.True(t, Has("v1.1.0-rc1", NetworkAddrList))
+	require.False(t, Has("v1.1.0-rc1", NetworkNodeList))
+	require.True(t, Has("v1.10.0", NetworkNodeList))
+
+	require.False(t, Has("latest", PierAdminJSON))
+	require.False(t, Has("v2.8.0", Capability("unknown")))
+}
+
+func TestBitXHubTemplate(t *testing.T) {
+	require.Equal(t, "v1.6.0", BitXHubTemplate("v1.11.3"))
+	require.Equal(t, "v1.6.0", BitXHubTemplate("v1.6.0"))
+	require.Equal(t, "v1.4.0", BitXHubTemplate("v1.5.0"))
+	require.Equal(t, "v1.1.0", BitXHubTemplate("v1.1.0-rc1"))
+}
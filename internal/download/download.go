@@ -1,15 +1,221 @@
 package download
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/Rican7/retry"
+	"github.com/Rican7/retry/backoff"
+	"github.com/Rican7/retry/strategy"
 	"github.com/cavaliercoder/grab"
 	"github.com/cheggaaa/pb"
+	"github.com/fatih/color"
+	"github.com/meshplus/bitxhub-kit/fileutil"
 )
 
-func Download(dst string, url string) error {
+const (
+	// partSuffix is appended to the file being downloaded, it is renamed to
+	// the target only after it is completed and verified.
+	partSuffix = ".part"
+
+	defaultAttempts = 5
+	defaultBackoff  = time.Second
+)
+
+var ErrNotFound = errors.New("resource not found")
+
+type options struct {
+	sha256   string
+	manifest Manifest
+	attempts uint
+	backoff  time.Duration
+}
+
+type Option func(*options)
+
+// WithSHA256 verifies the downloaded file against the hex encoded sha256 checksum
+func WithSHA256(sum string) Option {
+	return func(o *options) {
+		o.sha256 = sum
+	}
+}
+
+// WithManifest verifies the downloaded file against its checksum in the
+// manifest, see RequireChecksum for the files not listed in the manifest.
+func WithManifest(m Manifest) Option {
+	return func(o *options) {
+		o.manifest = m
+	}
+}
+
+// WithRetry sets the number of attempts and the initial delay between them,
+// the delay is doubled after each failed attempt.
+func WithRetry(attempts uint, delay time.Duration) Option {
+	return func(o *options) {
+		o.attempts = attempts
+		o.backoff = delay
+	}
+}
+
+// Download fetches url to dst, dst is either a file or a directory, which
+// exists or ends with a separator, in which case the file name is taken from url. The content is written to
+// dst.part first, which is resumed by the next attempt if it is interrupted,
+// and is moved to dst only after it is completed and its checksum is verified.
+//
+// The file without a checksum is downloaded with a warning, or not at all if
+// RequireChecksum is set.
+//
+// If CacheDir is set, the file is taken from the cache or downloaded into it
+// first. If Mirror is set, the file is fetched from the mirror, and from url
+// only if the mirror does not have it and Offline is not set.
+func Download(dst string, url string, opts ...Option) error {
+	o := &options{
+		attempts: defaultAttempts,
+		backoff:  defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.attempts == 0 {
+		o.attempts = 1
+	}
+
+	if info, err := os.Stat(dst); (err == nil && info.IsDir()) || strings.HasSuffix(dst, string(filepath.Separator)) {
+		dst = filepath.Join(dst, path.Base(url))
+	}
+	name := filepath.Base(dst)
+	if o.sha256 == "" {
+		o.sha256 = o.manifest.Checksum(name)
+	}
+	if o.sha256 == "" {
+		if RequireChecksum {
+			return fmt.Errorf("no sha256 checksum of %s to verify %s", name, url)
+		}
+		color.Yellow("WARNING: no sha256 checksum of %s, %s is not verified", name, url)
+	}
+
+	if CacheDir == "" {
+		return get(dst, url, name, o)
+	}
+
+	cached, err := cachePath(url)
+	if err != nil {
+		return err
+	}
+	if !complete(cached, name, o.sha256) {
+		if err := get(cached, url, name, o); err != nil {
+			return err
+		}
+	}
+
+	return copyFile(cached, dst)
+}
+
+// get fetches url to dst from the mirror or from url itself
+func get(dst string, url string, name string, o *options) error {
+	if Mirror != "" {
+		src, local, err := mirrorURL(url)
+		if err != nil {
+			return err
+		}
+		if local {
+			if fileutil.Exist(src) {
+				if err := verify(src, name, o.sha256); err != nil {
+					return err
+				}
+				return copyFile(src, dst)
+			}
+		} else {
+			err := fetchWithRetry(dst, src, name, o)
+			if !errors.Is(err, ErrNotFound) {
+				return err
+			}
+		}
+		if Offline {
+			return fmt.Errorf("%s is not found in the mirror %s", url, Mirror)
+		}
+	}
+
+	if Offline {
+		return fmt.Errorf("%s is not cached and can not be downloaded in offline mode", url)
+	}
+
+	return fetchWithRetry(dst, url, name, o)
+}
+
+// fetchWithRetry fetches url to dst.part, retries with backoff and moves it to
+// dst after it is verified.
+func fetchWithRetry(dst string, url string, name string, o *options) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	part := dst + partSuffix
+	// Limit counts the retries after the first attempt
+	retries := o.attempts - 1
+	// fatal is the error not worth retrying
+	var fatal error
+	err := retry.Retry(func(attempt uint) error {
+		if attempt > 0 {
+			fmt.Printf("retry to download %s (%d/%d)\n", url, attempt+1, o.attempts)
+		}
+
+		err := fetch(part, url)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				fatal = err
+				return nil
+			}
+			return err
+		}
+
+		if err := verify(part, name, o.sha256); err != nil {
+			// the partial file is broken, start over in the next attempt
+			os.Remove(part)
+			return err
+		}
+
+		return nil
+	}, strategy.Limit(retries), strategy.Backoff(backoff.BinaryExponential(o.backoff)))
+	if fatal != nil {
+		return fatal
+	}
+	if err != nil {
+		return fmt.Errorf("download %s: %w", url, err)
+	}
+
+	return os.Rename(part, dst)
+}
+
+// complete reports whether the file exists and passes the verification
+func complete(path string, name string, sum string) bool {
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+
+	return verify(path, name, sum) == nil
+}
+
+func verify(path string, name string, sum string) error {
+	if err := VerifyFile(path, sum); err != nil {
+		return err
+	}
+	if isArchive(name) {
+		return CheckArchive(path)
+	}
+
+	return nil
+}
+
+// fetch downloads url to dst, the existing content of dst is kept and only
+// the rest is requested if the server supports range requests.
+func fetch(dst string, url string) error {
 	client := grab.NewClient()
 	req, err := grab.NewRequest(dst, url)
 	if err != nil {
@@ -38,7 +244,11 @@ L:
 		if grab.IsStatusCodeError(err) {
 			code := err.(grab.StatusCodeError)
 			if int(code) == http.StatusNotFound {
-				return fmt.Errorf("resource not found")
+				return ErrNotFound
+			}
+			if int(code) == http.StatusRequestedRangeNotSatisfiable {
+				// the partial file is larger than the resource, start over
+				os.Remove(dst)
 			}
 		}
 		return err
@@ -0,0 +1,136 @@
+package download
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Manifest maps the file names of the artifacts to their sha256 checksums.
+type Manifest map[string]string
+
+// LoadManifest reads a manifest in the format of sha256sum, that is, a
+// checksum and a file name on each line. A missing manifest is empty.
+func LoadManifest(path string) (Manifest, error) {
+	m := make(Manifest)
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid checksum at %s:%d", path, n)
+		}
+		// sha256sum marks the files read in binary mode with a leading *
+		m[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Checksum returns the checksum of the file, or empty if it is not listed
+func (m Manifest) Checksum(name string) string {
+	return m[name]
+}
+
+// Verify checks the file against its checksum in the manifest, files not
+// listed in the manifest pass the check.
+func (m Manifest) Verify(path string, name string) error {
+	return VerifyFile(path, m.Checksum(name))
+}
+
+// Complete reports whether the file exists and passes the check of its
+// checksum, and of its content if it is a gzipped tarball.
+func (m Manifest) Complete(path string) bool {
+	return complete(path, filepath.Base(path), m.Checksum(filepath.Base(path)))
+}
+
+// VerifyFile checks the sha256 checksum of the file, an empty sum passes the check
+func VerifyFile(path string, sum string) error {
+	if sum == "" {
+		return nil
+	}
+
+	actual, err := SHA256(path)
+	if err != nil {
+		return err
+	}
+	if actual != strings.ToLower(sum) {
+		return fmt.Errorf("checksum mismatch of %s: expected %s, got %s", path, sum, actual)
+	}
+
+	return nil
+}
+
+// SHA256 returns the hex encoded sha256 checksum of the file
+func SHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func isArchive(name string) bool {
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// CheckArchive reads the gzipped tarball to the end, so a truncated or
+// corrupted archive is detected even if it has no checksum in the manifest.
+func CheckArchive(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("read archive %s: %w", path, err)
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		_, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read archive %s: %w", path, err)
+		}
+		if _, err := io.Copy(ioutil.Discard, tr); err != nil {
+			return fmt.Errorf("read archive %s: %w", path, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package download
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// Mirror is the base url of a http server or a local directory which
+	// mirrors the artifacts, the artifact https://github.com/a/b is looked up
+	// at <Mirror>/github.com/a/b.
+	Mirror string
+
+	// CacheDir is the directory shared by repos to keep the downloaded
+	// artifacts, in the same layout as Mirror. No cache is used if it is empty.
+	CacheDir string
+
+	// Offline forbids the downloads from anywhere but the cache and the mirror.
+	Offline bool
+
+	// RequireChecksum fails the downloads which have no checksum to verify
+	// against, they are only warned about otherwise.
+	RequireChecksum bool
+)
+
+// artifactPath is the path of the artifact in the mirror and the cache, that
+// is, the host and the path of its url.
+func artifactPath(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("parse url %s: %w", rawURL, err)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("url %s has no host", rawURL)
+	}
+
+	return path.Join(u.Host, path.Clean("/"+u.Path)), nil
+}
+
+func cachePath(rawURL string) (string, error) {
+	p, err := artifactPath(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(CacheDir, filepath.FromSlash(p)), nil
+}
+
+// mirrorURL returns where the artifact is in the mirror, local is true if
+// the mirror is a local directory.
+func mirrorURL(rawURL string) (string, bool, error) {
+	p, err := artifactPath(rawURL)
+	if err != nil {
+		return "", false, err
+	}
+
+	if strings.HasPrefix(Mirror, "http://") || strings.HasPrefix(Mirror, "https://") {
+		return strings.TrimSuffix(Mirror, "/") + "/" + p, false, nil
+	}
+
+	dir := strings.TrimPrefix(Mirror, "file://")
+	return filepath.Join(dir, filepath.FromSlash(p)), true, nil
+}
+
+// copyFile links or copies src to dst.part and moves it to dst
+func copyFile(src string, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	part := dst + partSuffix
+	os.Remove(part)
+	if err := os.Link(src, part); err != nil {
+		if err := copyContent(src, part); err != nil {
+			os.Remove(part)
+			return fmt.Errorf("copy %s to %s: %w", src, dst, err)
+		}
+	}
+
+	return os.Rename(part, dst)
+}
+
+func copyContent(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
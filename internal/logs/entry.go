@@ -0,0 +1,104 @@
+package logs
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Levels in ascending order of severity
+var levels = []string{"trace", "debug", "info", "warning", "error", "fatal", "panic"}
+
+var (
+	ansiRegexp   = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	timeRegexp   = regexp.MustCompile(`time="([^"]+)"`)
+	levelRegexp  = regexp.MustCompile(`level=(\w+)`)
+	moduleRegexp = regexp.MustCompile(`module=("[^"]*"|\S+)`)
+	// ttyRegexp matches the lines written by logrus to a terminal, e.g.
+	// INFO[2021-09-22T11:16:02.480] Start bitxhub   module=app
+	ttyRegexp = regexp.MustCompile(`^(TRAC|DEBU|INFO|WARN|ERRO|FATA|PANI)\[([^\]]+)\]`)
+)
+
+var timeLayouts = []string{
+	"2006-01-02T15:04:05.000",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05.000",
+	"2006-01-02 15:04:05",
+}
+
+// Entry is a line of log.
+type Entry struct {
+	// Source is the name of the node the line comes from
+	Source string
+	// Time is zero if the line has no timestamp, e.g. a line of a stack trace
+	Time   time.Time
+	Level  string
+	Module string
+	// Line is the raw line without color codes
+	Line string
+}
+
+// Parse parses a line written by the logrus based logger of bitxhub and pier.
+// The fields which can not be parsed are left empty.
+func Parse(source, line string) *Entry {
+	line = ansiRegexp.ReplaceAllString(strings.TrimRight(line, "\r\n"), "")
+	entry := &Entry{
+		Source: source,
+		Line:   line,
+	}
+
+	if match := ttyRegexp.FindStringSubmatch(line); match != nil {
+		entry.Level = NormalizeLevel(match[1])
+		entry.Time = parseTime(match[2])
+	} else {
+		if match := timeRegexp.FindStringSubmatch(line); match != nil {
+			entry.Time = parseTime(match[1])
+		}
+		if match := levelRegexp.FindStringSubmatch(line); match != nil {
+			entry.Level = NormalizeLevel(match[1])
+		}
+	}
+
+	if match := moduleRegexp.FindStringSubmatch(line); match != nil {
+		entry.Module = strings.Trim(match[1], `"`)
+	}
+
+	return entry
+}
+
+// NormalizeLevel returns the full lowercase name of level, e.g. warning for WARN,
+// or empty string if it is not a known level.
+func NormalizeLevel(level string) string {
+	level = strings.ToLower(level)
+	for _, l := range levels {
+		if l == level || (len(level) >= 4 && strings.HasPrefix(l, level)) {
+			return l
+		}
+	}
+	if level == "warn" {
+		return "warning"
+	}
+
+	return ""
+}
+
+func levelIndex(level string) int {
+	for i, l := range levels {
+		if l == level {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func parseTime(s string) time.Time {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,83 @@
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Filter selects the entries to print, the zero value selects all entries.
+type Filter struct {
+	// Level is the minimum level
+	Level   string
+	Modules []string
+	Regexp  *regexp.Regexp
+	Since   time.Time
+}
+
+// NewFilter creates a filter, level is the minimum level and pattern is a regular expression.
+func NewFilter(level string, modules []string, pattern string, since time.Time) (*Filter, error) {
+	f := &Filter{Since: since}
+
+	if level != "" {
+		f.Level = NormalizeLevel(level)
+		if f.Level == "" {
+			return nil, fmt.Errorf("unknown log level %s, one of %s", level, strings.Join(levels, ", "))
+		}
+	}
+
+	for _, module := range modules {
+		for _, m := range strings.Split(module, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				f.Modules = append(f.Modules, m)
+			}
+		}
+	}
+
+	if pattern != "" {
+		reg, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("compile regexp %s: %w", pattern, err)
+		}
+		f.Regexp = reg
+	}
+
+	return f, nil
+}
+
+// Match reports whether the entry is selected. Entries without time or level,
+// such as the continuation lines of a stack trace, are judged by the fields
+// they inherit from the previous entry of the same source.
+func (f *Filter) Match(e *Entry) bool {
+	if f == nil {
+		return true
+	}
+
+	if !f.Since.IsZero() && !e.Time.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+
+	if f.Level != "" && e.Level != "" && levelIndex(e.Level) < levelIndex(f.Level) {
+		return false
+	}
+
+	if len(f.Modules) != 0 {
+		matched := false
+		for _, m := range f.Modules {
+			if e.Module == m {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if f.Regexp != nil && !f.Regexp.MatchString(e.Line) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,92 @@
+package logs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	entry := Parse("node1", `time="2021-09-22T11:16:02.480" level=warning msg="Vote rejected" module=order`+"\n")
+	require.Equal(t, "node1", entry.Source)
+	require.Equal(t, "warning", entry.Level)
+	require.Equal(t, "order", entry.Module)
+	require.Equal(t, 480*time.Millisecond, time.Duration(entry.Time.Nanosecond()))
+
+	entry = Parse("node2", "\x1b[36mINFO\x1b[0m[2021-09-22T11:16:02.480] Start bitxhub    \x1b[36mmodule\x1b[0m=app")
+	require.Equal(t, "info", entry.Level)
+	require.Equal(t, "app", entry.Module)
+	require.False(t, entry.Time.IsZero())
+	require.Equal(t, "INFO[2021-09-22T11:16:02.480] Start bitxhub    module=app", entry.Line)
+
+	entry = Parse("node3", "goroutine 1 [running]:")
+	require.True(t, entry.Time.IsZero())
+	require.Equal(t, "", entry.Level)
+}
+
+func TestFilter(t *testing.T) {
+	_, err := NewFilter("verbose", nil, "", time.Time{})
+	require.NotNil(t, err)
+
+	filter, err := NewFilter("WARN", []string{"order,p2p"}, "rejected", time.Time{})
+	require.Nil(t, err)
+	require.True(t, filter.Match(Parse("node1", `time="2021-09-22T11:16:02.480" level=error msg="Vote rejected" module=order`)))
+	require.False(t, filter.Match(Parse("node1", `time="2021-09-22T11:16:02.480" level=info msg="Vote rejected" module=order`)))
+	require.False(t, filter.Match(Parse("node1", `time="2021-09-22T11:16:02.480" level=error msg="Vote rejected" module=app`)))
+	require.False(t, filter.Match(Parse("node1", `time="2021-09-22T11:16:02.480" level=error msg="Vote accepted" module=order`)))
+
+	since := time.Date(2021, 9, 22, 11, 16, 3, 0, time.Local)
+	filter, err = NewFilter("", nil, "", since)
+	require.Nil(t, err)
+	require.False(t, filter.Match(Parse("node1", `time="2021-09-22T11:16:02.480" level=info msg="old"`)))
+	require.True(t, filter.Match(Parse("node1", `time="2021-09-22T11:16:03.480" level=info msg="new"`)))
+}
+
+func TestStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	node1 := filepath.Join(dir, "node1.log")
+	node2 := filepath.Join(dir, "node2.log")
+	require.Nil(t, ioutil.WriteFile(node1, []byte(
+		`time="2021-09-22T11:16:02.100" level=info msg="a" module=app`+"\n"+
+			`time="2021-09-22T11:16:02.300" level=error msg="c" module=order`+"\n"+
+			"stack of c\n"), 0644))
+	require.Nil(t, ioutil.WriteFile(node2, []byte(
+		`time="2021-09-22T11:16:02.200" level=info msg="b" module=app`+"\n"+
+			`time="2021-09-22T11:16:02.400" level=info msg="d" module=order`+"\n"), 0644))
+
+	sources := []Source{NewFileSource("node1", node1), NewFileSource("node2", node2)}
+
+	var got []string
+	err = Stream(context.Background(), sources, Options{Tail: -1}, func(entry *Entry) {
+		got = append(got, entry.Source+" "+entry.Line)
+	})
+	require.Nil(t, err)
+	require.Equal(t, []string{
+		`node1 time="2021-09-22T11:16:02.100" level=info msg="a" module=app`,
+		`node2 time="2021-09-22T11:16:02.200" level=info msg="b" module=app`,
+		`node1 time="2021-09-22T11:16:02.300" level=error msg="c" module=order`,
+		`node1 stack of c`,
+		`node2 time="2021-09-22T11:16:02.400" level=info msg="d" module=order`,
+	}, got)
+
+	// the continuation line inherits the level and module of its entry
+	filter, err := NewFilter("error", []string{"order"}, "", time.Time{})
+	require.Nil(t, err)
+	got = nil
+	err = Stream(context.Background(), sources, Options{Tail: 2, Filter: filter}, func(entry *Entry) {
+		got = append(got, entry.Source+" "+entry.Line)
+	})
+	require.Nil(t, err)
+	require.Equal(t, []string{
+		`node1 time="2021-09-22T11:16:02.300" level=error msg="c" module=order`,
+		`node1 stack of c`,
+	}, got)
+}
@@ -0,0 +1,155 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// idleTimeout is how long a followed source may be silent before the entries
+// of the other sources are printed without waiting for it.
+const idleTimeout = 300 * time.Millisecond
+
+type Options struct {
+	// Tail is the number of the latest lines of each source to read, negative means all
+	Tail   int
+	Follow bool
+	Filter *Filter
+}
+
+type sourceEntry struct {
+	index int
+	entry *Entry
+	done  bool
+	err   error
+}
+
+// Stream reads the logs of all sources and calls out with the matched entries
+// interleaved by time. An entry is printed only when no source can produce an
+// earlier one, that is, each source has a later entry queued, is finished, or
+// has been idle for a while.
+func Stream(ctx context.Context, sources []Source, opts Options, out func(*Entry)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var since time.Time
+	if opts.Filter != nil {
+		since = opts.Filter.Since
+	}
+
+	ch := make(chan *sourceEntry, 1024)
+	for i, source := range sources {
+		go func(i int, source Source) {
+			var prev *Entry
+			err := source.Read(ctx, opts.Tail, since, opts.Follow, func(line string, t time.Time) {
+				entry := Parse(source.Name(), line)
+				if !t.IsZero() && entry.Time.IsZero() {
+					entry.Time = t
+				}
+				inherit(entry, prev)
+				prev = entry
+
+				select {
+				case ch <- &sourceEntry{index: i, entry: entry}:
+				case <-ctx.Done():
+				}
+			})
+			if err != nil {
+				err = fmt.Errorf("read log of %s: %w", source.Name(), err)
+			}
+			select {
+			case ch <- &sourceEntry{index: i, done: true, err: err}:
+			case <-ctx.Done():
+			}
+		}(i, source)
+	}
+
+	m := &merger{
+		queues:   make([][]*Entry, len(sources)),
+		lastSeen: make([]time.Time, len(sources)),
+		done:     make([]bool, len(sources)),
+		filter:   opts.Filter,
+		out:      out,
+	}
+	now := time.Now()
+	for i := range m.lastSeen {
+		m.lastSeen[i] = now
+	}
+
+	ticker := time.NewTicker(idleTimeout / 3)
+	defer ticker.Stop()
+	remaining := len(sources)
+	for remaining > 0 {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-ch:
+			if e.done {
+				if e.err != nil {
+					return e.err
+				}
+				m.done[e.index] = true
+				remaining--
+			} else {
+				m.queues[e.index] = append(m.queues[e.index], e.entry)
+				m.lastSeen[e.index] = time.Now()
+			}
+		case <-ticker.C:
+		}
+		m.flush(time.Now())
+	}
+	m.flush(time.Now())
+
+	return nil
+}
+
+// inherit fills the time, level and module of the continuation lines, such
+// as a stack trace, from the previous entry.
+func inherit(entry, prev *Entry) {
+	if prev == nil || !entry.Time.IsZero() {
+		return
+	}
+
+	entry.Time = prev.Time
+	if entry.Level == "" {
+		entry.Level = prev.Level
+	}
+	if entry.Module == "" {
+		entry.Module = prev.Module
+	}
+}
+
+type merger struct {
+	queues   [][]*Entry
+	lastSeen []time.Time
+	done     []bool
+	filter   *Filter
+	out      func(*Entry)
+}
+
+func (m *merger) flush(now time.Time) {
+	for {
+		next := -1
+		for i, q := range m.queues {
+			if len(q) == 0 {
+				if !m.done[i] && now.Sub(m.lastSeen[i]) < idleTimeout {
+					// the source may still produce an earlier entry
+					return
+				}
+				continue
+			}
+			if next == -1 || q[0].Time.Before(m.queues[next][0].Time) {
+				next = i
+			}
+		}
+		if next == -1 {
+			return
+		}
+
+		entry := m.queues[next][0]
+		m.queues[next] = m.queues[next][1:]
+		if m.filter.Match(entry) {
+			m.out(entry)
+		}
+	}
+}
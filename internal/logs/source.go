@@ -0,0 +1,221 @@
+package logs
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// pollInterval is the interval to check new lines of a followed file
+const pollInterval = 200 * time.Millisecond
+
+// Source is where the log of a node is read from.
+type Source interface {
+	Name() string
+	// Read calls fn with each line of the log, t is the time reported by the
+	// source or zero if unknown. Only the last tail lines are read if tail is
+	// not negative. If follow is true, Read keeps waiting for new lines until
+	// ctx is done.
+	Read(ctx context.Context, tail int, since time.Time, follow bool, fn func(line string, t time.Time)) error
+}
+
+// FileSource reads the log file of a node started in binary.
+type FileSource struct {
+	name string
+	path string
+}
+
+func NewFileSource(name, path string) *FileSource {
+	return &FileSource{
+		name: name,
+		path: path,
+	}
+}
+
+func (s *FileSource) Name() string {
+	return s.name
+}
+
+func (s *FileSource) Read(ctx context.Context, tail int, since time.Time, follow bool, fn func(line string, t time.Time)) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if !follow || !os.IsNotExist(err) {
+			return err
+		}
+		// the node has not created the log file yet
+		if f, err = s.waitFile(ctx); f == nil {
+			return err
+		}
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var (
+		offset  int64
+		pending string
+		ring    []string
+	)
+	for {
+		line, err := reader.ReadString('\n')
+		offset += int64(len(line))
+		if err != nil {
+			pending = line
+			break
+		}
+		if tail < 0 {
+			fn(line, time.Time{})
+			continue
+		}
+		ring = append(ring, line)
+		if len(ring) > tail {
+			ring = ring[1:]
+		}
+	}
+	for _, line := range ring {
+		fn(line, time.Time{})
+	}
+
+	if !follow {
+		if pending != "" {
+			fn(pending, time.Time{})
+		}
+		return nil
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if info, err := os.Stat(s.path); err == nil && info.Size() < offset {
+			// the file is truncated or rotated, read it from the beginning
+			f.Close()
+			if f, err = os.Open(s.path); err != nil {
+				return err
+			}
+			reader.Reset(f)
+			offset = 0
+			pending = ""
+		}
+
+		for {
+			line, err := reader.ReadString('\n')
+			offset += int64(len(line))
+			if err != nil {
+				pending += line
+				break
+			}
+			fn(pending+line, time.Time{})
+			pending = ""
+		}
+	}
+}
+
+func (s *FileSource) waitFile(ctx context.Context) (*os.File, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-ticker.C:
+		}
+
+		f, err := os.Open(s.path)
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+}
+
+// DockerSource reads the log of a container through the docker api.
+type DockerSource struct {
+	name string
+	id   string
+	cli  *client.Client
+}
+
+func NewDockerSource(cli *client.Client, name, id string) *DockerSource {
+	return &DockerSource{
+		name: name,
+		id:   id,
+		cli:  cli,
+	}
+}
+
+func (s *DockerSource) Name() string {
+	return s.name
+}
+
+func (s *DockerSource) Read(ctx context.Context, tail int, since time.Time, follow bool, fn func(line string, t time.Time)) error {
+	info, err := s.cli.ContainerInspect(ctx, s.id)
+	if err != nil {
+		return err
+	}
+
+	opts := types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+		Timestamps: true,
+		Tail:       "all",
+	}
+	if tail >= 0 {
+		opts.Tail = strconv.Itoa(tail)
+	}
+	if !since.IsZero() {
+		opts.Since = strconv.FormatInt(since.Unix(), 10)
+	}
+
+	rc, err := s.cli.ContainerLogs(ctx, s.id, opts)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var reader io.Reader = rc
+	if info.Config == nil || !info.Config.Tty {
+		// the stdout and stderr are multiplexed if the container has no tty
+		pr, pw := io.Pipe()
+		go func() {
+			_, err := stdcopy.StdCopy(pw, pw, rc)
+			pw.CloseWithError(err)
+		}()
+		reader = pr
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var t time.Time
+		if pos := strings.IndexByte(line, ' '); pos != -1 {
+			if ts, err := time.Parse(time.RFC3339Nano, line[:pos]); err == nil {
+				t = ts.Local()
+				line = line[pos+1:]
+			}
+		}
+		fn(line, t)
+	}
+
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+
+	return nil
+}
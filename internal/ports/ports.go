@@ -0,0 +1,264 @@
+package ports
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FileName is the name of the file in the repo which holds the allocations
+const FileName = "ports.json"
+
+// maxPort is the largest port to allocate
+const maxPort = 65535
+
+// Kind is the usage of a port
+type Kind string
+
+const (
+	P2P     Kind = "p2p"
+	JSONRPC Kind = "jsonrpc"
+	GRPC    Kind = "grpc"
+	Gateway Kind = "gateway"
+	Pprof   Kind = "pprof"
+	Monitor Kind = "monitor"
+
+	PierP2P   Kind = "pier_p2p"
+	PierHTTP  Kind = "pier_http"
+	PierPprof Kind = "pier_pprof"
+	PierAPI   Kind = "pier_api"
+)
+
+var (
+	// NodeKinds are the ports of a BitXHub node
+	NodeKinds = []Kind{P2P, JSONRPC, GRPC, Gateway, Pprof, Monitor}
+	// PierKinds are the ports of a pier
+	PierKinds = []Kind{PierP2P, PierHTTP, PierPprof, PierAPI}
+
+	// DefaultBases are the ports of the first node or pier, which are the
+	// ones in the modify configs
+	DefaultBases = map[Kind]int{
+		P2P:       4001,
+		JSONRPC:   8881,
+		GRPC:      60011,
+		Gateway:   9091,
+		Pprof:     53121,
+		Monitor:   40011,
+		PierP2P:   5001,
+		PierHTTP:  44554,
+		PierPprof: 44550,
+		PierAPI:   8080,
+	}
+)
+
+// Ports are the ports of a node or pier
+type Ports map[Kind]int
+
+// Env is the ports of the nodes and piers of an environment, which is
+// identified by the directory of its configs.
+type Env struct {
+	Nodes []Ports `json:"nodes,omitempty"`
+	Piers []Ports `json:"piers,omitempty"`
+}
+
+// Allocation is the ports allocated to all environments of the repo
+type Allocation struct {
+	Envs map[string]*Env `json:"envs"`
+
+	path string
+}
+
+// Load reads the allocations of the repo, it is empty if nothing is allocated
+func Load(repoRoot string) (*Allocation, error) {
+	a := &Allocation{
+		Envs: make(map[string]*Env),
+		path: filepath.Join(repoRoot, FileName),
+	}
+
+	data, err := ioutil.ReadFile(a.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return a, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, fmt.Errorf("unmarshal %s: %w", a.path, err)
+	}
+	if a.Envs == nil {
+		a.Envs = make(map[string]*Env)
+	}
+
+	return a, nil
+}
+
+// Save writes the allocations to the repo
+func (a *Allocation) Save() error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal ports: %w", err)
+	}
+
+	// write to a temporary file first so that a failed write does not lose the
+	// allocations of the other environments
+	tmp := a.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, a.path)
+}
+
+// Get returns the ports of the environment, or nil if nothing is allocated
+func (a *Allocation) Get(env string) *Env {
+	return a.Envs[env]
+}
+
+// Release frees the ports of the environment
+func (a *Allocation) Release(env string) {
+	delete(a.Envs, env)
+}
+
+// taken returns all the allocated ports
+func (a *Allocation) taken() map[int]bool {
+	taken := make(map[int]bool)
+	for _, env := range a.Envs {
+		for _, ports := range append(env.Nodes, env.Piers...) {
+			for _, port := range ports {
+				taken[port] = true
+			}
+		}
+	}
+
+	return taken
+}
+
+// Allocator assigns the ports which are neither allocated to an environment
+// nor used on the machine.
+type Allocator struct {
+	// Bases are the ports tried first for the first node or pier, the ones of
+	// the others follow, DefaultBases is used for the missing kinds
+	Bases map[Kind]int
+	// InUse reports whether the port is used on the machine
+	InUse func(port int) bool
+}
+
+func NewAllocator(bases map[Kind]int) *Allocator {
+	return &Allocator{
+		Bases: bases,
+		InUse: InUse,
+	}
+}
+
+// Allocate assigns the ports of the nodes and piers of the environment. The
+// ports already allocated to it are kept, they are not checked since they may
+// be used by the environment itself.
+func (al *Allocator) Allocate(a *Allocation, env string, nodes, piers int) (*Env, error) {
+	e := a.Envs[env]
+	if e == nil {
+		e = &Env{}
+	}
+
+	taken := a.taken()
+	var err error
+	if e.Nodes, err = al.allocate(e.Nodes, nodes, NodeKinds, taken); err != nil {
+		return nil, err
+	}
+	if e.Piers, err = al.allocate(e.Piers, piers, PierKinds, taken); err != nil {
+		return nil, err
+	}
+
+	a.Envs[env] = e
+
+	return e, nil
+}
+
+func (al *Allocator) allocate(allocated []Ports, num int, kinds []Kind, taken map[int]bool) ([]Ports, error) {
+	for i := len(allocated); i < num; i++ {
+		ports := make(Ports)
+		for _, kind := range kinds {
+			port, err := al.next(al.base(kind)+i, taken)
+			if err != nil {
+				return nil, fmt.Errorf("allocate %s port: %w", kind, err)
+			}
+			taken[port] = true
+			ports[kind] = port
+		}
+		allocated = append(allocated, ports)
+	}
+
+	return allocated, nil
+}
+
+func (al *Allocator) base(kind Kind) int {
+	if port, ok := al.Bases[kind]; ok {
+		return port
+	}
+
+	return DefaultBases[kind]
+}
+
+// next returns the first free port from the port on
+func (al *Allocator) next(port int, taken map[int]bool) (int, error) {
+	for p := port; p <= maxPort; p++ {
+		if taken[p] {
+			continue
+		}
+		if al.InUse != nil && al.InUse(p) {
+			continue
+		}
+		return p, nil
+	}
+
+	return 0, fmt.Errorf("no free port from %d", port)
+}
+
+// InUse reports whether the TCP port is listened on the machine
+func InUse(port int) bool {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return true
+	}
+	_ = l.Close()
+
+	return false
+}
+
+// ParseBases parses the base port overrides like p2p=5001
+func ParseBases(overrides []string) (map[Kind]int, error) {
+	bases := make(map[Kind]int)
+	for _, o := range overrides {
+		kv := strings.SplitN(o, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid base port %s, e.g. p2p=5001", o)
+		}
+
+		kind := Kind(strings.TrimSpace(kv[0]))
+		if _, ok := DefaultBases[kind]; !ok {
+			return nil, fmt.Errorf("unknown port kind %s, one of %s", kind, strings.Join(kinds(), ", "))
+		}
+		port, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil || port <= 0 || port > maxPort {
+			return nil, fmt.Errorf("invalid base port %s of %s", kv[1], kind)
+		}
+		bases[kind] = port
+	}
+
+	return bases, nil
+}
+
+func kinds() []string {
+	var names []string
+	for kind := range DefaultBases {
+		names = append(names, string(kind))
+	}
+	sort.Strings(names)
+
+	return names
+}
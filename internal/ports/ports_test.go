@@ -0,0 +1,74 @@
+package ports
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocate(t *testing.T) {
+	repoRoot, err := ioutil.TempDir("", "ports")
+	require.Nil(t, err)
+	defer os.RemoveAll(repoRoot)
+
+	a, err := Load(repoRoot)
+	require.Nil(t, err)
+
+	used := map[int]bool{4002: true, 60011: true}
+	al := NewAllocator(nil)
+	al.InUse = func(port int) bool { return used[port] }
+
+	// more than 10 nodes on one host
+	env, err := al.Allocate(a, "/repo/bitxhub/.bitxhub", 12, 0)
+	require.Nil(t, err)
+	require.Equal(t, 12, len(env.Nodes))
+	require.Equal(t, 4001, env.Nodes[0][P2P])
+	require.Equal(t, 4003, env.Nodes[1][P2P])
+	require.Equal(t, 60012, env.Nodes[0][GRPC])
+	require.Equal(t, 40011, env.Nodes[0][Monitor])
+	require.Nil(t, a.Save())
+
+	// the ports of the other environment are not reused
+	a, err = Load(repoRoot)
+	require.Nil(t, err)
+	other, err := al.Allocate(a, "/repo/other", 1, 1)
+	require.Nil(t, err)
+	for _, ports := range a.Get("/repo/bitxhub/.bitxhub").Nodes {
+		require.NotEqual(t, ports[P2P], other.Nodes[0][P2P])
+		require.NotEqual(t, ports[GRPC], other.Nodes[0][GRPC])
+	}
+	require.Equal(t, 44554, other.Piers[0][PierHTTP])
+
+	// the allocated ports are kept even if they are used by the environment
+	used[4001] = true
+	env, err = al.Allocate(a, "/repo/bitxhub/.bitxhub", 12, 0)
+	require.Nil(t, err)
+	require.Equal(t, 4001, env.Nodes[0][P2P])
+
+	a.Release("/repo/bitxhub/.bitxhub")
+	require.Nil(t, a.Get("/repo/bitxhub/.bitxhub"))
+}
+
+func TestParseBases(t *testing.T) {
+	bases, err := ParseBases([]string{"p2p=5001", "pier_http = 45000"})
+	require.Nil(t, err)
+	require.Equal(t, map[Kind]int{P2P: 5001, PierHTTP: 45000}, bases)
+
+	al := NewAllocator(bases)
+	al.InUse = func(int) bool { return false }
+	a := &Allocation{Envs: make(map[string]*Env)}
+	env, err := al.Allocate(a, "env", 2, 1)
+	require.Nil(t, err)
+	require.Equal(t, 5002, env.Nodes[1][P2P])
+	require.Equal(t, 8882, env.Nodes[1][JSONRPC])
+	require.Equal(t, 45000, env.Piers[0][PierHTTP])
+
+	_, err = ParseBases([]string{"p2p"})
+	require.NotNil(t, err)
+	_, err = ParseBases([]string{"raft=5001"})
+	require.NotNil(t, err)
+	_, err = ParseBases([]string{"p2p=70000"})
+	require.NotNil(t, err)
+}
@@ -0,0 +1,116 @@
+package release
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meshplus/goduck/internal/download"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// testTarball returns a gzipped tarball holding the bitxhub binary
+func testTarball(t *testing.T, content string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	require.Nil(t, tw.WriteHeader(&tar.Header{Name: "bitxhub", Mode: 0755, Size: int64(len(content))}))
+	_, err := tw.Write([]byte(content))
+	require.Nil(t, err)
+	require.Nil(t, tw.Close())
+	require.Nil(t, gw.Close())
+
+	return buf.Bytes()
+}
+
+func TestDownloadChecksum(t *testing.T) {
+	repoRoot, err := ioutil.TempDir("", "release")
+	require.Nil(t, err)
+	defer os.RemoveAll(repoRoot)
+
+	// the mirror serves the tarball of v1.6.5 and a tampered one of v1.6.1
+	released := testTarball(t, "bitxhub v1.6.5")
+	tampered := testTarball(t, "tampered")
+	sum := sha256.Sum256(released)
+	files := map[string][]byte{
+		"/github.com/meshplus/bitxhub/releases/download/v1.6.5/bitxhub_linux-amd64_v1.6.5.tar.gz": released,
+		"/github.com/meshplus/bitxhub/releases/download/v1.6.1/bitxhub_linux-amd64_v1.6.1.tar.gz": tampered,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, filepath.Base(r.URL.Path), time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	mirror, offline, requireChecksum := download.Mirror, download.Offline, download.RequireChecksum
+	defer func() {
+		download.Mirror, download.Offline, download.RequireChecksum = mirror, offline, requireChecksum
+	}()
+	download.Mirror = server.URL
+	download.Offline = true
+
+	m := &Manifest{
+		Version: ManifestVersion,
+		BitXHub: []*BitXHub{
+			{Version: "v1.6.5", Artifacts: []*Artifact{{Name: "bitxhub_linux-amd64_{{.Version}}.tar.gz", System: types.LinuxSystem, Arch: types.AMD64Arch, SHA256: hex.EncodeToString(sum[:])}}},
+			// the tarballs of the versions share no checksum
+			{Version: "v1.6.1", Artifacts: []*Artifact{{Name: "bitxhub_linux-amd64_{{.Version}}.tar.gz", System: types.LinuxSystem, Arch: types.AMD64Arch, SHA256: hex.EncodeToString(sum[:])}}},
+			{Version: "v1.7.0", Artifacts: []*Artifact{{Name: "bitxhub_linux-amd64_{{.Version}}.tar.gz", System: types.LinuxSystem, Arch: types.AMD64Arch}}},
+		},
+	}
+	fetch := func(version string) (string, error) {
+		checksums, err := m.Checksums(repoRoot)
+		require.Nil(t, err)
+		r, err := m.FindBitXHub(version)
+		require.Nil(t, err)
+		a, err := r.Artifact(types.LinuxSystem, types.AMD64Arch)
+		require.Nil(t, err)
+
+		dst := filepath.Join(repoRoot, "bin", version, a.Name)
+		url := fmt.Sprintf(types.BitxhubReleaseUrl, version, a.Name)
+		return dst, download.Download(dst, url, download.WithManifest(checksums), download.WithRetry(1, 0))
+	}
+
+	// the released tarball passes its checksum in the manifest
+	dst, err := fetch("v1.6.5")
+	require.Nil(t, err)
+	data, err := ioutil.ReadFile(dst)
+	require.Nil(t, err)
+	require.Equal(t, released, data)
+
+	// the tampered one is refused and not moved into place
+	dst, err = fetch("v1.6.1")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "checksum mismatch")
+	_, err = os.Stat(dst)
+	require.True(t, os.IsNotExist(err))
+
+	// checksums.sha256 of the repo takes precedence over the manifest
+	tamperedSum := sha256.Sum256(tampered)
+	line := fmt.Sprintf("%s  bitxhub_linux-amd64_v1.6.1.tar.gz\n", hex.EncodeToString(tamperedSum[:]))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(repoRoot, types.ChecksumManifest), []byte(line), 0644))
+	_, err = fetch("v1.6.1")
+	require.Nil(t, err)
+
+	// the one without a checksum is refused with RequireChecksum
+	download.RequireChecksum = true
+	_, err = fetch("v1.7.0")
+	require.NotNil(t, err)
+	require.True(t, strings.Contains(err.Error(), "no sha256 checksum"), err.Error())
+}
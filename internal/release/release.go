@@ -0,0 +1,309 @@
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/fatih/color"
+	"github.com/meshplus/goduck/internal/download"
+	"github.com/meshplus/goduck/internal/types"
+)
+
+// ManifestVersion is the version of the manifest format goduck understands
+const ManifestVersion = 2
+
+// Manifest is the single source of the supported releases, the compatibility
+// between them, and the artifacts to download for them.
+type Manifest struct {
+	Version int        `json:"version" yaml:"version"`
+	BitXHub []*BitXHub `json:"bitxhub" yaml:"bitxhub"`
+	Pier    []*Pier    `json:"pier" yaml:"pier"`
+}
+
+type BitXHub struct {
+	Version string `json:"version" yaml:"version"`
+	// Config is the directory of the config scripts under bxh_config
+	Config string `json:"config" yaml:"config"`
+	// Pier is the versions of the compatible piers
+	Pier      []string    `json:"pier" yaml:"pier"`
+	Artifacts []*Artifact `json:"artifacts" yaml:"artifacts"`
+}
+
+type Pier struct {
+	Version string `json:"version" yaml:"version"`
+	// Config is the directory of the config scripts under pier_config
+	Config string `json:"config" yaml:"config"`
+	// Plugins is the versions of the appchain plugins
+	Plugins map[string]string `json:"plugins" yaml:"plugins"`
+	// EthereumContract is the version of the ethereum contract config
+	EthereumContract string      `json:"ethereum_contract" yaml:"ethereum_contract"`
+	Artifacts        []*Artifact `json:"artifacts" yaml:"artifacts"`
+}
+
+type Artifact struct {
+	// Name is a template of the file name, {{.Version}} is the version of the
+	// release, or the version of the plugin for the appchain plugins
+	Name   string `json:"name" yaml:"name"`
+	System string `json:"system" yaml:"system"`
+	Arch   string `json:"arch" yaml:"arch"`
+	// Appchain is set for the artifacts of the appchain plugins
+	Appchain string `json:"appchain,omitempty" yaml:"appchain,omitempty"`
+	// SHA256 is the hex encoded checksum the downloaded artifact is verified against
+	SHA256 string `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+}
+
+// Load reads the manifest in the repo
+func Load(repoRoot string) (*Manifest, error) {
+	path := filepath.Join(repoRoot, types.ReleaseJson)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("please `goduck init` first")
+		}
+		return nil, err
+	}
+
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil || m.Version < ManifestVersion {
+		// the manifest written by the old goduck is a list of versions, or
+		// has no architectures of the artifacts
+		return nil, fmt.Errorf("%s is outdated, please `goduck init` again", path)
+	}
+	if m.Version > ManifestVersion {
+		return nil, fmt.Errorf("%s is of version %d, please upgrade goduck", path, m.Version)
+	}
+
+	return m, nil
+}
+
+// FindBitXHub returns the release of the BitXHub version
+func (m *Manifest) FindBitXHub(version string) (*BitXHub, error) {
+	for _, r := range m.BitXHub {
+		if r.Version == version {
+			return r, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported BitXHub version %s, one of %s", version, strings.Join(m.BitXHubVersions(), ", "))
+}
+
+// FindPier returns the release of the pier version
+func (m *Manifest) FindPier(version string) (*Pier, error) {
+	for _, r := range m.Pier {
+		if r.Version == version {
+			return r, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported pier version %s, one of %s", version, strings.Join(m.PierVersions(), ", "))
+}
+
+func (m *Manifest) BitXHubVersions() []string {
+	var versions []string
+	for _, r := range m.BitXHub {
+		versions = append(versions, r.Version)
+	}
+
+	return versions
+}
+
+func (m *Manifest) PierVersions() []string {
+	var versions []string
+	for _, r := range m.Pier {
+		versions = append(versions, r.Version)
+	}
+
+	return versions
+}
+
+// Check validates the combination of the versions, the empty ones are not checked
+func (m *Manifest) Check(bxhVersion, pierVersion, appchain string) error {
+	var (
+		bxh *BitXHub
+		p   *Pier
+		err error
+	)
+	if bxhVersion != "" {
+		if bxh, err = m.FindBitXHub(bxhVersion); err != nil {
+			return err
+		}
+	}
+	if pierVersion != "" {
+		if p, err = m.FindPier(pierVersion); err != nil {
+			return err
+		}
+	}
+
+	if bxh != nil && p != nil && !contains(bxh.Pier, p.Version) {
+		return fmt.Errorf("pier %s is incompatible with BitXHub %s, one of %s", p.Version, bxh.Version, strings.Join(bxh.Pier, ", "))
+	}
+	if p != nil && appchain != "" {
+		if _, ok := p.Plugins[appchain]; !ok {
+			return fmt.Errorf("pier %s has no plugin for appchain %s", p.Version, appchain)
+		}
+	}
+
+	return nil
+}
+
+// Artifact returns the BitXHub tarball of the platform
+func (r *BitXHub) Artifact(system, arch string) (*Artifact, error) {
+	a, err := findArtifact(r.Artifacts, system, arch, "", r.Version)
+	if err != nil {
+		return nil, fmt.Errorf("BitXHub %s: %w", r.Version, err)
+	}
+
+	return a, nil
+}
+
+// Artifact returns the pier tarball of the platform
+func (r *Pier) Artifact(system, arch string) (*Artifact, error) {
+	a, err := findArtifact(r.Artifacts, system, arch, "", r.Version)
+	if err != nil {
+		return nil, fmt.Errorf("pier %s: %w", r.Version, err)
+	}
+
+	return a, nil
+}
+
+// Plugin returns the plugin of the appchain for the platform
+func (r *Pier) Plugin(appchain, system, arch string) (*Artifact, error) {
+	version, ok := r.Plugins[appchain]
+	if !ok {
+		return nil, fmt.Errorf("pier %s has no plugin for appchain %s", r.Version, appchain)
+	}
+	a, err := findArtifact(r.Artifacts, system, arch, appchain, version)
+	if err != nil {
+		return nil, fmt.Errorf("pier %s %s plugin: %w", r.Version, appchain, err)
+	}
+
+	return a, nil
+}
+
+// findArtifact returns the artifact of the platform with its name rendered
+// for the version, or an error listing the platforms there are artifacts for.
+// The darwin/amd64 artifact is used on darwin/arm64 if there is no native
+// one, as Apple silicon runs it by Rosetta.
+func findArtifact(artifacts []*Artifact, system, arch, appchain, version string) (*Artifact, error) {
+	var (
+		platforms []string
+		rosetta   *Artifact
+	)
+	for _, a := range artifacts {
+		if a.Appchain != appchain {
+			continue
+		}
+		if a.System == system && a.Arch == arch {
+			return a.render(version)
+		}
+		if a.System == types.DarwinSystem && a.Arch == types.AMD64Arch {
+			rosetta = a
+		}
+		platforms = append(platforms, a.System+"/"+a.Arch)
+	}
+
+	if system == types.DarwinSystem && arch == types.ARM64Arch && rosetta != nil {
+		color.Yellow("WARNING: no release for darwin/arm64, the darwin/amd64 one is used, which runs by Rosetta")
+		return rosetta.render(version)
+	}
+
+	if len(platforms) == 0 {
+		return nil, fmt.Errorf("no release for %s/%s", system, arch)
+	}
+
+	return nil, fmt.Errorf("no release for %s/%s, one of %s", system, arch, strings.Join(platforms, ", "))
+}
+
+// render returns a copy of the artifact with the file name of the version
+func (a *Artifact) render(version string) (*Artifact, error) {
+	t, err := template.New(a.Name).Option("missingkey=error").Parse(a.Name)
+	if err != nil {
+		return nil, fmt.Errorf("parse artifact name %s: %w", a.Name, err)
+	}
+	var name strings.Builder
+	if err := t.Execute(&name, struct{ Version string }{version}); err != nil {
+		return nil, fmt.Errorf("render artifact name %s: %w", a.Name, err)
+	}
+
+	artifact := *a
+	artifact.Name = name.String()
+
+	return &artifact, nil
+}
+
+// EthereumContract returns the version of the ethereum contract config used
+// with the BitXHub version, which is the one of its latest compatible pier.
+func (m *Manifest) EthereumContract(bxhVersion string) (string, error) {
+	bxh, err := m.FindBitXHub(bxhVersion)
+	if err != nil {
+		return "", err
+	}
+	if len(bxh.Pier) == 0 {
+		return "", fmt.Errorf("BitXHub %s has no compatible pier", bxhVersion)
+	}
+
+	p, err := m.FindPier(bxh.Pier[len(bxh.Pier)-1])
+	if err != nil {
+		return "", err
+	}
+
+	return p.EthereumContract, nil
+}
+
+// Checksums collects the checksums of all artifacts, the ones in the
+// checksums.sha256 of the repo take precedence.
+func (m *Manifest) Checksums(repoRoot string) (download.Manifest, error) {
+	checksums, err := download.LoadManifest(filepath.Join(repoRoot, types.ChecksumManifest))
+	if err != nil {
+		return nil, err
+	}
+
+	add := func(a *Artifact, version string) error {
+		if a.SHA256 == "" {
+			return nil
+		}
+		a, err := a.render(version)
+		if err != nil {
+			return err
+		}
+		if _, ok := checksums[a.Name]; !ok {
+			checksums[a.Name] = strings.ToLower(a.SHA256)
+		}
+		return nil
+	}
+	for _, r := range m.BitXHub {
+		for _, a := range r.Artifacts {
+			if err := add(a, r.Version); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, r := range m.Pier {
+		for _, a := range r.Artifacts {
+			version := r.Version
+			if a.Appchain != "" {
+				version = r.Plugins[a.Appchain]
+			}
+			if err := add(a, version); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return checksums, nil
+}
+
+func contains(versions []string, version string) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+
+	return false
+}
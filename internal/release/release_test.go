@@ -0,0 +1,101 @@
+package release
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptsPath is the directory of the release.json and checksums.sha256
+// shipped by goduck init
+const scriptsPath = "../../scripts"
+
+// requireChecksumEnv is the environment variable of `goduck --require-checksum`
+const requireChecksumEnv = "GODUCK_REQUIRE_CHECKSUM"
+
+func TestChecksums(t *testing.T) {
+	m, err := Load(scriptsPath)
+	require.Nil(t, err)
+	checksums, err := m.Checksums(scriptsPath)
+	require.Nil(t, err)
+
+	var missing []string
+	check := func(a *Artifact, version, url string) {
+		a, err := a.render(version)
+		require.Nil(t, err)
+		checksum := checksums.Checksum(a.Name)
+		if checksum == "" {
+			missing = append(missing, fmt.Sprintf(url, a.Name))
+			return
+		}
+		sum, err := hex.DecodeString(checksum)
+		require.True(t, err == nil && len(sum) == 32, "invalid sha256 checksum %s of %s", checksum, a.Name)
+	}
+	for _, r := range m.BitXHub {
+		for _, a := range r.Artifacts {
+			check(a, r.Version, strings.Replace(types.BitxhubReleaseUrl, "%s", r.Version, 1))
+		}
+	}
+	for _, r := range m.Pier {
+		for _, a := range r.Artifacts {
+			if a.Appchain == "" {
+				check(a, r.Version, strings.Replace(types.PierReleaseUrl, "%s", r.Version, 1))
+				continue
+			}
+			version := r.Plugins[a.Appchain]
+			url := fmt.Sprintf(types.PierPluginReleaseUrl, a.Appchain, version, "%s")
+			check(a, version, url)
+		}
+	}
+	// geth is released for linux/amd64, linux/arm64 and darwin/amd64
+	for _, platform := range [][]string{{"linux", "amd64"}, {"linux", "arm64"}, {"darwin", "amd64"}} {
+		name := fmt.Sprintf(types.GethTarName, platform[0], platform[1])
+		url := fmt.Sprintf(types.GethUrl, platform[0], platform[1])
+		check(&Artifact{Name: name}, "", strings.Replace(url, name, "%s", 1))
+	}
+
+	if len(missing) == 0 {
+		return
+	}
+	// the release check requires the checksums of all artifacts the way
+	// `goduck --require-checksum` does
+	if os.Getenv(requireChecksumEnv) == "" {
+		t.Skipf("sha256 checksums are missing for %d artifacts, set %s to require them:\n%s", len(missing), requireChecksumEnv, strings.Join(missing, "\n"))
+	}
+	require.Zero(t, len(missing), "sha256 checksums are missing for:\n%s", strings.Join(missing, "\n"))
+}
+
+func TestArtifactPlatform(t *testing.T) {
+	m, err := Load(scriptsPath)
+	require.Nil(t, err)
+	r, err := m.FindBitXHub("v1.6.5")
+	require.Nil(t, err)
+
+	tests := []struct {
+		system string
+		arch   string
+		name   string
+		err    string
+	}{
+		{system: types.LinuxSystem, arch: types.AMD64Arch, name: "bitxhub_linux-amd64_v1.6.5.tar.gz"},
+		{system: types.DarwinSystem, arch: types.AMD64Arch, name: "bitxhub_darwin_x86_64_v1.6.5.tar.gz"},
+		// Apple silicon falls back to the darwin/amd64 release
+		{system: types.DarwinSystem, arch: types.ARM64Arch, name: "bitxhub_darwin_x86_64_v1.6.5.tar.gz"},
+		{system: types.LinuxSystem, arch: types.ARM64Arch, err: "no release for linux/arm64"},
+	}
+	for _, test := range tests {
+		a, err := r.Artifact(test.system, test.arch)
+		if test.err != "" {
+			require.NotNil(t, err, test.system+"/"+test.arch)
+			require.Contains(t, err.Error(), test.err)
+			continue
+		}
+		require.Nil(t, err, test.system+"/"+test.arch)
+		require.Equal(t, test.name, a.Name)
+	}
+}
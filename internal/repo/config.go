@@ -1,9 +1,12 @@
 package repo
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 
+	"github.com/meshplus/goduck/internal/types"
 	"github.com/mitchellh/go-homedir"
 )
 
@@ -40,6 +43,8 @@ const (
 	KeyPriv = "key"
 	// proposal strategy: simple majority
 	SimpleMajority = "SimpleMajority"
+	// proposal strategy: no vote is needed
+	ZeroPermission = "ZeroPermission"
 )
 
 func PathRoot() (string, error) {
@@ -78,3 +83,36 @@ func GetCSRPath(name, dir string) string {
 func GetCertPath(name, dir string) string {
 	return filepath.Join(dir, name+".cert")
 }
+
+// GetBinPath returns the directory of the binaries of the component release
+// for the platform, the ones for amd64 keep the names without architecture.
+func GetBinPath(dir, component, system, arch, version string) string {
+	platform := system
+	if arch != types.AMD64Arch {
+		platform = system + "-" + arch
+	}
+
+	return filepath.Join(dir, "bin", fmt.Sprintf("%s_%s_%s", component, platform, version))
+}
+
+// GetLinkPath returns the directory of the locally built binary linked for
+// the component, which is used instead of the releases.
+func GetLinkPath(dir, component string) string {
+	return filepath.Join(dir, "bin", component+"_link")
+}
+
+// Linked reports whether a locally built binary is linked for the component
+func Linked(dir, component string) bool {
+	_, err := os.Stat(filepath.Join(GetLinkPath(dir, component), component))
+	return err == nil
+}
+
+// GetLocalBinPath returns the directory of the binary of the component to run
+// on this host, the linked one takes precedence over the release.
+func GetLocalBinPath(dir, component, version string) string {
+	if Linked(dir, component) {
+		return GetLinkPath(dir, component)
+	}
+
+	return GetBinPath(dir, component, runtime.GOOS, runtime.GOARCH, version)
+}
@@ -0,0 +1,295 @@
+package repo
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// ModifyConfig is a loosely parsed bxh_modify_config.toml or pier_modify_config.toml.
+// These files are also read by the shell scripts with sed, so string values are
+// usually left unquoted and the files can not be decoded as strict TOML.
+type ModifyConfig struct {
+	path    string
+	lines   []string
+	entries []*modifyEntry
+	// section is the section of the last line
+	section string
+	// pending is the entry whose array value continues on the next lines
+	pending *modifyEntry
+}
+
+type modifyEntry struct {
+	section string
+	key     string
+	value   string
+	line    int
+	// end is the last line of the value, which is line unless the value is
+	// an array written over several lines
+	end int
+}
+
+func LoadModifyConfig(path string) (*ModifyConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open modify config: %w", err)
+	}
+	defer f.Close()
+
+	c := &ModifyConfig{path: path}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		c.addLine(scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read modify config: %w", err)
+	}
+
+	return c, nil
+}
+
+func (c *ModifyConfig) Path() string {
+	return c.path
+}
+
+// Get returns the value of key, which is either a bare key or a key qualified
+// with its section, e.g. "mode" or "bitxhub.mode".
+func (c *ModifyConfig) Get(key string) string {
+	for _, e := range c.entries {
+		if e.match(key) {
+			return e.value
+		}
+	}
+
+	return ""
+}
+
+// Values returns the values of all entries matching key in file order,
+// e.g. Values("grpc_port") returns the grpc port of every node.
+func (c *ModifyConfig) Values(key string) []string {
+	var values []string
+	for _, e := range c.entries {
+		if e.match(key) {
+			values = append(values, e.value)
+		}
+	}
+
+	return values
+}
+
+func (c *ModifyConfig) Int(key string) (int, error) {
+	v := c.Get(key)
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s(%s) in %s: %w", key, v, c.path, err)
+	}
+
+	return i, nil
+}
+
+// List returns the value of key as a list, a single value is a list of itself,
+// e.g. both bitxhubAddr = localhost:60011 and bitxhubAddr = ["localhost:60011"].
+func (c *ModifyConfig) List(key string) ([]string, error) {
+	v := c.Get(key)
+	if v == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(v, "[") {
+		return []string{v}, nil
+	}
+
+	tree, err := toml.Load("list = " + v)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s(%s) in %s: %w", key, v, c.path, err)
+	}
+	items, ok := tree.Get("list").([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("parse %s(%s) in %s: not a list", key, v, c.path)
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		list = append(list, fmt.Sprint(item))
+	}
+
+	return list, nil
+}
+
+func (c *ModifyConfig) Bool(key string) bool {
+	b, _ := strconv.ParseBool(c.Get(key))
+	return b
+}
+
+// Set replaces the value of the first entry matching key. The line is
+// rewritten in place so that the file can still be read by the scripts.
+func (c *ModifyConfig) Set(key, value string) error {
+	for _, e := range c.entries {
+		if e.match(key) {
+			c.setEntry(e, value)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s not found in %s", key, c.path)
+}
+
+// SetValues replaces the values of all entries matching key in file order.
+func (c *ModifyConfig) SetValues(key string, values []string) error {
+	i := 0
+	for _, e := range c.entries {
+		if i == len(values) {
+			break
+		}
+		if e.match(key) {
+			c.setEntry(e, values[i])
+			i++
+		}
+	}
+
+	if i != len(values) {
+		return fmt.Errorf("only %d %s found in %s, %d expected", i, key, c.path, len(values))
+	}
+
+	return nil
+}
+
+// Keys returns the keys of the section in file order
+func (c *ModifyConfig) Keys(section string) []string {
+	var keys []string
+	for _, e := range c.entries {
+		if e.section == section {
+			keys = append(keys, e.key)
+		}
+	}
+
+	return keys
+}
+
+// Append adds the lines to the end of the config
+func (c *ModifyConfig) Append(lines ...string) {
+	for _, line := range lines {
+		c.addLine(line)
+	}
+}
+
+// Write writes the config to path, comments and layout are kept.
+func (c *ModifyConfig) Write(path string) error {
+	data := strings.Join(c.lines, "\n") + "\n"
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		return fmt.Errorf("write modify config: %w", err)
+	}
+
+	return nil
+}
+
+func (c *ModifyConfig) addLine(text string) {
+	c.lines = append(c.lines, text)
+	line := strings.TrimSpace(stripComment(text))
+	if c.pending != nil {
+		c.pending.value += line
+		c.pending.end = len(c.lines) - 1
+		if arrayClosed(c.pending.value) {
+			c.pending = nil
+		}
+		return
+	}
+	if line == "" {
+		return
+	}
+
+	if strings.HasPrefix(line, "[") {
+		c.section = strings.Trim(line, "[] ")
+		return
+	}
+
+	kv := strings.SplitN(line, "=", 2)
+	if len(kv) != 2 {
+		return
+	}
+	e := &modifyEntry{
+		section: c.section,
+		key:     strings.TrimSpace(kv[0]),
+		value:   unquote(strings.TrimSpace(kv[1])),
+		line:    len(c.lines) - 1,
+		end:     len(c.lines) - 1,
+	}
+	c.entries = append(c.entries, e)
+	if !arrayClosed(e.value) {
+		c.pending = e
+	}
+}
+
+func (c *ModifyConfig) setEntry(e *modifyEntry, value string) {
+	line := c.lines[e.line]
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	c.lines[e.line] = fmt.Sprintf("%s%s = %s", indent, e.key, value)
+	e.value = value
+
+	// the value is rewritten in a single line, so are the entries after it moved up
+	if removed := e.end - e.line; removed != 0 {
+		c.lines = append(c.lines[:e.line+1], c.lines[e.end+1:]...)
+		e.end = e.line
+		for _, other := range c.entries {
+			if other.line > e.line {
+				other.line -= removed
+				other.end -= removed
+			}
+		}
+	}
+}
+
+func (e *modifyEntry) match(key string) bool {
+	if e.key == key {
+		return true
+	}
+
+	return e.section != "" && e.section+"."+e.key == key
+}
+
+func stripComment(line string) string {
+	inQuote := false
+	for i, c := range line {
+		switch c {
+		case '"':
+			inQuote = !inQuote
+		case '#':
+			if !inQuote {
+				return line[:i]
+			}
+		}
+	}
+
+	return line
+}
+
+// arrayClosed reports whether the brackets of an array value are balanced,
+// a value which is not an array is always closed.
+func arrayClosed(v string) bool {
+	depth := 0
+	inQuote := false
+	for _, c := range v {
+		switch {
+		case c == '"':
+			inQuote = !inQuote
+		case c == '[' && !inQuote:
+			depth++
+		case c == ']' && !inQuote:
+			depth--
+		}
+	}
+
+	return depth <= 0
+}
+
+func unquote(v string) string {
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		return v[1 : len(v)-1]
+	}
+
+	return v
+}
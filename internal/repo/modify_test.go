@@ -0,0 +1,60 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestModifyConfigList(t *testing.T) {
+	// the validators of the older versions are written over several lines
+	config, err := LoadModifyConfig("../../scripts/pier_config/v1.6.1/pier_modify_config.toml")
+	require.Nil(t, err)
+
+	validators, err := config.List("pier.relay.bitxhubValidators")
+	require.Nil(t, err)
+	require.Equal(t, 4, len(validators))
+	require.Equal(t, "0xc7F999b83Af6DF9e67d0a37Ee7e900bF38b3D013", validators[0])
+
+	addrs, err := config.List("pier.relay.bitxhubAddr")
+	require.Nil(t, err)
+	require.Equal(t, []string{"localhost:60011"}, addrs)
+
+	require.Equal(t, "5001", config.Get("pier.direct.directPort"))
+
+	config, err = LoadModifyConfig("../../scripts/pier_config/v2.8.0/pier_modify_config.toml")
+	require.Nil(t, err)
+
+	addrs, err = config.List("pier.relay.bitxhubAddr")
+	require.Nil(t, err)
+	require.Equal(t, []string{"localhost:60011", "localhost:60012", "localhost:60013", "localhost:60014"}, addrs)
+}
+
+func TestModifyConfigSetList(t *testing.T) {
+	config, err := LoadModifyConfig("../../scripts/pier_config/v1.6.1/pier_modify_config.toml")
+	require.Nil(t, err)
+
+	require.Nil(t, config.Set("pier.relay.bitxhubValidators", `["0x1"]`))
+	require.Nil(t, config.Set("pier.direct.directPort", "5002"))
+
+	dir, err := ioutil.TempDir("", "modify")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "pier_modify_config.toml")
+	require.Nil(t, config.Write(path))
+	data, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	require.True(t, strings.Contains(string(data), "directPort = 5002"))
+
+	config, err = LoadModifyConfig(path)
+	require.Nil(t, err)
+	validators, err := config.List("pier.relay.bitxhubValidators")
+	require.Nil(t, err)
+	require.Equal(t, []string{"0x1"}, validators)
+	require.Equal(t, "5002", config.Get("pier.direct.directPort"))
+}
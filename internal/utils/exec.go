@@ -2,19 +2,40 @@ package utils
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 )
 
+const (
+	// stderrTailLines is the max number of stderr lines kept in ExecError
+	stderrTailLines = 20
+	// killGracePeriod is the time to wait after SIGTERM before the process group is killed
+	killGracePeriod = 5 * time.Second
+)
+
+// DefaultTimeout is the timeout of tasks without their own timeout, it is set
+// by --timeout. 0 means no timeout.
+var DefaultTimeout time.Duration
+
 type ExecTask struct {
 	Command string
 	Args    []string
 	Env     []string
 	Repo    string
 
+	// Ctx cancels the task, the whole process group is killed once it is done.
+	Ctx context.Context
+	// Timeout of the task, DefaultTimeout is used if it is 0.
+	Timeout time.Duration
+
 	StreamStdio  bool
 	PrintCommand bool
 }
@@ -25,6 +46,32 @@ type ExecResult struct {
 	ExitCode int
 }
 
+// ExecError is returned when a task exits with non-zero code, or it is
+// killed because of timeout, cancellation or signals.
+type ExecError struct {
+	Command  string
+	ExitCode int
+	// StderrTail is the last lines of stderr
+	StderrTail string
+	Err        error
+}
+
+func (e *ExecError) Error() string {
+	msg := fmt.Sprintf("%s exit with code %d", e.Command, e.ExitCode)
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %s", msg, e.Err)
+	}
+	if e.StderrTail != "" {
+		msg = fmt.Sprintf("%s\n%s", msg, e.StderrTail)
+	}
+
+	return msg
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
 func ExecuteShell(args []string, repoRoot string) error {
 	task := ExecTask{
 		Command:      "/bin/bash",
@@ -33,16 +80,16 @@ func ExecuteShell(args []string, repoRoot string) error {
 		StreamStdio:  true,
 		PrintCommand: true,
 	}
-	result, err := task.Execute()
-	if err != nil {
+	if _, err := task.Execute(); err != nil {
 		return fmt.Errorf("execute shell error:%w", err)
 	}
-	if result.ExitCode != 0 {
-		return fmt.Errorf("execute shell error:%s", result.Stderr)
-	}
 	return nil
 }
 
+// Execute runs the task in its own process group. SIGINT and SIGTERM received
+// by goduck are forwarded to the group, and the group is killed when the task
+// times out or its context is done. An *ExecError is returned if the task
+// does not exit with zero.
 func (et ExecTask) Execute() (ExecResult, error) {
 	argsSt := ""
 	if len(et.Args) > 0 {
@@ -66,6 +113,7 @@ func (et ExecTask) Execute() (ExecResult, error) {
 	}
 
 	cmd.Dir = et.Repo
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
 	if len(et.Env) > 0 {
 		overrides := map[string]bool{}
@@ -100,23 +148,113 @@ func (et ExecTask) Execute() (ExecResult, error) {
 
 	cmd.Stdout = stdoutWriters
 	cmd.Stderr = stderrWriters
+
+	ctx := et.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timeout := et.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
 	startErr := cmd.Start()
 
 	if startErr != nil {
 		return ExecResult{}, startErr
 	}
 
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var (
+		execErr  error
+		stopErr  error
+		killed   bool
+		killTime <-chan time.Time
+	)
+	for {
+		select {
+		case execErr = <-done:
+		case sig := <-sigCh:
+			stopErr = fmt.Errorf("interrupted by %s", sig)
+			_ = signalGroup(cmd.Process.Pid, sig.(syscall.Signal))
+			killTime = time.After(killGracePeriod)
+			continue
+		case <-ctx.Done():
+			if stopErr == nil {
+				stopErr = ctx.Err()
+				if errors.Is(stopErr, context.DeadlineExceeded) {
+					stopErr = fmt.Errorf("timeout after %s: %w", timeout, stopErr)
+				}
+				_ = signalGroup(cmd.Process.Pid, syscall.SIGTERM)
+				killTime = time.After(killGracePeriod)
+			}
+			ctx = context.Background()
+			continue
+		case <-killTime:
+			if !killed {
+				killed = true
+				_ = signalGroup(cmd.Process.Pid, syscall.SIGKILL)
+			}
+			continue
+		}
+		break
+	}
+
 	exitCode := 0
-	execErr := cmd.Wait()
 	if execErr != nil {
 		if exitError, ok := execErr.(*exec.ExitError); ok {
 			exitCode = exitError.ExitCode()
 		}
 	}
 
-	return ExecResult{
+	result := ExecResult{
 		Stdout:   string(stdoutBuff.Bytes()),
 		Stderr:   string(stderrBuff.Bytes()),
 		ExitCode: exitCode,
-	}, nil
+	}
+
+	if exitCode != 0 || stopErr != nil {
+		return result, &ExecError{
+			Command:    strings.TrimSpace(fmt.Sprintf("%s %s", et.Command, argsSt)),
+			ExitCode:   exitCode,
+			StderrTail: tail(result.Stderr, stderrTailLines),
+			Err:        stopErr,
+		}
+	}
+
+	return result, nil
+}
+
+// signalGroup sends sig to the process group of pid, and to pid itself if the
+// group is gone.
+func signalGroup(pid int, sig syscall.Signal) error {
+	if err := syscall.Kill(-pid, sig); err != nil {
+		return syscall.Kill(pid, sig)
+	}
+
+	return nil
+}
+
+func tail(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return strings.Join(lines, "\n")
 }
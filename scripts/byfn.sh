@@ -160,7 +160,7 @@ function networkUp() {
     replacePrivateKey
   fi
   generateChannelArtifacts
-  COMPOSE_FILES="-f ${COMPOSE_FILE}"
+  COMPOSE_FILES="-f ${COMPOSE_FILE} -f ${COMPOSE_FILE_GODUCK}"
   if [ "${CERTIFICATE_AUTHORITIES}" == "true" ]; then
     COMPOSE_FILES="${COMPOSE_FILES} -f ${COMPOSE_FILE_CA}"
     export BYFN_CA1_PRIVATE_KEY=$(cd crypto-config/peerOrganizations/org1.example.com/ca && ls *_sk)
@@ -221,7 +221,7 @@ function upgradeNetwork() {
     mkdir -p $LEDGERS_BACKUP
 
     export IMAGE_TAG=$IMAGETAG
-    COMPOSE_FILES="-f ${COMPOSE_FILE}"
+    COMPOSE_FILES="-f ${COMPOSE_FILE} -f ${COMPOSE_FILE_GODUCK}"
     if [ "${CERTIFICATE_AUTHORITIES}" == "true" ]; then
       COMPOSE_FILES="${COMPOSE_FILES} -f ${COMPOSE_FILE_CA}"
       export BYFN_CA1_PRIVATE_KEY=$(cd crypto-config/peerOrganizations/org1.example.com/ca && ls *_sk)
@@ -520,6 +520,8 @@ COMPOSE_FILE_KAFKA=docker-compose-kafka.yaml
 COMPOSE_FILE_RAFT2=docker-compose-etcdraft2.yaml
 # certificate authorities compose file
 COMPOSE_FILE_CA=docker-compose-ca.yaml
+# goduck labels of the containers
+COMPOSE_FILE_GODUCK=docker-compose-goduck.yaml
 #
 # use golang as the default language for chaincode
 LANGUAGE=golang
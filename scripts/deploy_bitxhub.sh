@@ -19,6 +19,9 @@ OPT=$1
 VERSION=$2
 MODIFY_CONFIG_PATH=$3
 TARGET=$4
+# the binaries of the remote platform, linux/amd64 by default
+BIN_PATH=${5:-"${CURRENT_PATH}/bin/bitxhub_linux_${VERSION}"}
+BIN_FILE=${6:-bitxhub_linux-amd64_"${VERSION}".tar.gz}
 SYSTEM=$(uname -s)
 if [ $SYSTEM == "Linux" ]; then
   SYSTEM="linux"
@@ -26,8 +29,6 @@ elif [ $SYSTEM == "Darwin" ]; then
   SYSTEM="darwin"
 fi
 BXH_DEPLOY_PATH="${CURRENT_PATH}"/bxh-deploy
-BIN_FILE=bitxhub_linux-amd64_"${VERSION}".tar.gz
-BIN_PATH="${CURRENT_PATH}/bin/bitxhub_linux_${VERSION}"
 
 MODE=$(sed '/^.*mode/!d;s/.*=//;s/[[:space:]]//g' ${MODIFY_CONFIG_PATH})
 NUM=$(sed '/^.*num/!d;s/.*=//;s/[[:space:]]//g' ${MODIFY_CONFIG_PATH})
@@ -20,6 +20,9 @@ CHAINTYPE=$2
 VERSION=$3
 MODIFY_CONFIG_PATH=$4
 TARGET=$5
+# the binaries of the remote platform, linux/amd64 by default
+BIN_PATH=${6:-"${CURRENT_PATH}/bin/pier_linux_${VERSION}"}
+BIN_FILE=${7:-pier_linux-amd64_"${VERSION}".tar.gz}
 SYSTEM=$(uname -s)
 if [ $SYSTEM == "Linux" ]; then
   SYSTEM="linux"
@@ -27,9 +30,7 @@ elif [ $SYSTEM == "Darwin" ]; then
   SYSTEM="darwin"
 fi
 PIER_DEPLOY_PATH="${CURRENT_PATH}"/pier-deploy
-BIN_FILE=pier_linux-amd64_"${VERSION}".tar.gz
 PLUGIN_BIN_FILE=$CHAINTYPE-client
-BIN_PATH="${CURRENT_PATH}/bin/pier_linux_${VERSION}"
 
 MODE=$(sed '/^.*mode/!d;s/.*=//;s/[[:space:]]//g' ${MODIFY_CONFIG_PATH})
 NUM=$(sed '/^.*num/!d;s/.*=//;s/[[:space:]]//g' ${MODIFY_CONFIG_PATH})
@@ -69,7 +70,8 @@ function prepareConfig() {
         --target "${PIER_DEPLOY_PATH}" \
         --configPath "${MODIFY_CONFIG_PATH}" \
         --upType "binary" \
-        --version "${VERSION}"
+        --version "${VERSION}" \
+        --remote
   fi
 }
 
@@ -92,6 +94,8 @@ function deploy() {
 
   # 解压bin
   ssh $WHO "if [[ -d ${TARGET}/.pier_$CHAINTYPE ]]; then tar xzf ${TARGET}/${BIN_FILE} -C ${TARGET}/.pier_$CHAINTYPE; else mkdir ${TARGET}/.pier_$CHAINTYPE && tar xzf ${TARGET}/${BIN_FILE} -C ${TARGET}/.pier_$CHAINTYPE; fi; "
+  # 生成密钥
+  ssh $WHO "export LD_LIBRARY_PATH=$LD_LIBRARY_PATH:${TARGET}/.pier_$CHAINTYPE; cd ${TARGET}/.pier_$CHAINTYPE && rm -rf ${TARGET}/.pier_key_$CHAINTYPE && (./pier --repo ${TARGET}/.pier_key_$CHAINTYPE init $MODE || ./pier --repo ${TARGET}/.pier_key_$CHAINTYPE init) >/dev/null && for key in key.json node.priv admin.json; do if [[ -f ${TARGET}/.pier_key_$CHAINTYPE/\$key ]]; then cp ${TARGET}/.pier_key_$CHAINTYPE/\$key ${TARGET}/.pier_$CHAINTYPE/; fi; done; rm -rf ${TARGET}/.pier_key_$CHAINTYPE"
   #scp ${BIN_PATH}/${PLUGIN_BIN_FILE} $FULL_TARGET/.pier_$CHAINTYPE/appchain_plugin
   # 配置插件
 #  sleep 3
@@ -56,6 +56,7 @@ function dockerUp() {
     else
       print_blue "start a new ethereum-node container"
       docker run -d --name ethereum-node-$HTTP_PORT-$WS_PORT-$PORT \
+        --label goduck.component=ethereum \
         -p $HTTP_PORT:8545 -p $WS_PORT:8546 -p $PORT:30303 \
         meshplus/ethereum:$VERSION \
         --datadir /root/datadir --dev --ws --rpc \
@@ -56,6 +56,7 @@ function networkUp() {
 
   cd "${FABRIC_SAMPLE_PATH}"/first-network
   cp "${CURRENT_PATH}"/byfn.sh ./byfn.sh
+  cp "${CURRENT_PATH}"/docker/fabric/docker-compose-goduck.yaml ./docker-compose-goduck.yaml
   # choose to regenerate crypto-config or not
   if [ -z "${CRYPTO_CONFIG_PATH}" ]; then
     print_blue "fabric crypto-config not specified, use new generated crypto-config..."
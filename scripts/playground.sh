@@ -21,7 +21,12 @@ if [ $SYSTEM == "Linux" ]; then
 elif [ $SYSTEM == "Darwin" ]; then
   SYSTEM="darwin"
 fi
-BXH_PATH="${CURRENT_PATH}/bin/bitxhub_${SYSTEM}_${VERSION}"
+# the binaries for amd64 keep the names without architecture
+PLATFORM=${SYSTEM}
+if [ "$(uname -m)" == "aarch64" ] || [ "$(uname -m)" == "arm64" ]; then
+  PLATFORM="${SYSTEM}-arm64"
+fi
+BXH_PATH="${CURRENT_PATH}/bin/bitxhub_${PLATFORM}_${VERSION}"
 
 function printHelp() {
   print_blue "Usage:  "
@@ -20,7 +20,12 @@ QUICK_PATH_TMP="${QUICK_PATH}/.quick_start"
 QUICK_BXH_CONFIG_PATH="${QUICK_PATH}/bxhConfig/${VERSION}"
 PROM_PATH="${CURRENT_PATH}/docker/prometheus"
 ETH_PATH="${CURRENT_PATH}/pier/ethereum/${ETHVERSION}"
-PLUGIN_PATH="${CURRENT_PATH}/bin/pier_linux_${VERSION}/ethereum-client"
+# the plugin runs in the docker of the architecture of the host
+PLUGIN_PLATFORM=linux
+if [ "$(uname -m)" == "aarch64" ] || [ "$(uname -m)" == "arm64" ]; then
+  PLUGIN_PLATFORM="linux-arm64"
+fi
+PLUGIN_PATH="${CURRENT_PATH}/bin/pier_${PLUGIN_PLATFORM}_${VERSION}/ethereum-client"
 BITXHUB_CONFIG_PATH="${CURRENT_PATH}"/bitxhub
 PIER_CONFIG_PATH="${CURRENT_PATH}"/pier
 PIER_SCRIPTS_PATH="${CURRENT_PATH}"/docker/pier
@@ -11,6 +11,11 @@ if [ $SYSTEM == "Linux" ]; then
 elif [ $SYSTEM == "Darwin" ]; then
   SYSTEM="darwin"
 fi
+# the binaries for amd64 keep the names without architecture
+PLATFORM=${SYSTEM}
+if [ "$(uname -m)" == "aarch64" ] || [ "$(uname -m)" == "arm64" ]; then
+  PLATFORM="${SYSTEM}-arm64"
+fi
 
 function printHelp() {
   print_blue "Usage:  "
@@ -19,10 +24,13 @@ function printHelp() {
   echo "      - 'register' - register pier to bitxhub"
   echo "      - 'up' - bring up a new pier"
   echo "      - 'down' - clear a new pier"
-  echo "    -t <mode> - pier type (default \"fabric\")"
-  echo "    -r <pier_root> - pier repo path (default \".pier_fabric\")"
-  echo "    -v <pier_version> - pier version (default \"v1.1.0-rc1\")"
-  echo "    -b <bitxhub_addr> - bitxhub addr(default \"localhost:60011\")"
+  echo "    -a <appchain_type> - appchain type of the pier"
+  echo "    -p <pier_root> - pier repo path"
+  echo "    -v <pier_version> - pier version"
+  echo "    -d <pier_bin_path> - directory of the pier binary (default \"bin/pier_\$PLATFORM_\$VERSION\")"
+  echo "    -g <plugin> - appchain plugin for 'up'"
+  echo "    -f <plugin_config> - directory of the appchain plugin config for 'up'"
+  echo "    -n <name> -t <type> -e <version> -l <validators> - appchain registered by 'register', the validators are in the pier repo"
   echo "  run_pier.sh -h (print this message)"
 }
 
@@ -180,15 +188,8 @@ function pier_binary_up() {
 }
 
 function pier_docker_register() {
-  if [ "$APPCHAINTYPE" == "fabric" ]; then
-    print_blue "======> Register pier(fabric) to bitxhub"
-    docker exec $PIERCID scripts/registerAppchain.sh $METHOD chainA fabric chainA-description 1.4.3 /root/.pier/fabric/fabric.validators consensusType $VERSION
-  fi
-
-  if [ "$APPCHAINTYPE" == "ethereum" ]; then
-    print_blue "======> Register pier(ethereum) to bitxhub"
-    docker exec $PIERCID scripts/registerAppchain.sh "${METHOD}" chainB ether chainB-description 1.9.13 /root/.pier/ethereum/ether.validators consensusType "${VERSION}"
-  fi
+  print_blue "======> Register pier(${APPCHAINTYPE}) to bitxhub"
+  docker exec $PIERCID scripts/registerAppchain.sh "${METHOD}" "${APPCHAIN_NAME}" "${APPCHAIN_TYPE}" "${APPCHAIN_NAME}"-description "${APPCHAIN_VERSION}" /root/.pier/"${VALIDATORS}" consensusType "${VERSION}"
 
   print_blue "Waiting for the administrators of BitXHub to vote for approval."
 }
@@ -202,15 +203,8 @@ function pier_binary_register() {
   print_green "======> pier_root: ${PIERREPO}"
 
   # register pier
-  if [ "$APPCHAINTYPE" == "fabric" ]; then
-    print_blue "======> Register pier(fabric) to bitxhub"
-    appchain_register_binary chainA fabric chainA-description 1.4.3 fabric/fabric.validators $METHOD
-  fi
-
-  if [ "$APPCHAINTYPE" == "ethereum" ]; then
-    print_blue "======> Register pier(ethereum) to bitxhub"
-    appchain_register_binary chainB ether chainB-description 1.9.13 ethereum/ether.validators $METHOD
-  fi
+  print_blue "======> Register pier(${APPCHAINTYPE}) to bitxhub"
+  appchain_register_binary "${APPCHAIN_NAME}" "${APPCHAIN_TYPE}" "${APPCHAIN_NAME}"-description "${APPCHAIN_VERSION}" "${VALIDATORS}" $METHOD
 
   print_blue "Waiting for the administrators of BitXHub to vote for approval."
 }
@@ -242,7 +236,9 @@ function pier_up() {
     --target "${PIERREPO}" \
     --configPath "${CONFIGPATH}" \
     --upType "${UPTYPE}" \
-    --version "${VERSION}"
+    --version "${VERSION}" \
+    --plugin "${PLUGIN}" \
+    --plugin-config "${PLUGIN_CONFIG}"
 
   if [ "${UPTYPE}" == "docker" ]; then
     x_replace "s/localhost/host.docker.internal/g" "${PIERREPO}"/pier.toml
@@ -286,11 +282,7 @@ function pier_down() {
     echo "pier-$APPCHAINTYPE docker stop"
   fi
 
-  if [ $APPCHAINTYPE == "fabric" ]; then
-    cleanPierFabricInfoFile
-  else
-    cleanPierEtherInfoFile
-  fi
+  cleanPierInfoFile
 }
 
 function pier_clean() {
@@ -329,59 +321,22 @@ function pier_clean() {
   fi
 }
 
-function cleanPierFabricInfoFile() {
+function cleanPierInfoFile() {
   PIER_CONFIG_PATH="${CURRENT_PATH}"/pier
 
-  if [ -e "${PIER_CONFIG_PATH}"/pier-fabric.pid ]; then
-    rm "${PIER_CONFIG_PATH}"/pier-fabric.pid
-  fi
-  if [ -e "${PIER_CONFIG_PATH}"/pier-fabric-binary.addr ]; then
-    rm "${PIER_CONFIG_PATH}"/pier-fabric-binary.addr
-  fi
-  if [ -e "${PIER_CONFIG_PATH}"/pier-fabric-binary.version ]; then
-    rm "${PIER_CONFIG_PATH}"/pier-fabric-binary.version
-  fi
-
-  if [ -e "${PIER_CONFIG_PATH}"/pier-fabric.cid ]; then
-    rm "${PIER_CONFIG_PATH}"/pier-fabric.cid
-  fi
-  if [ -e "${PIER_CONFIG_PATH}"/pier-fabric-docker.addr ]; then
-    rm "${PIER_CONFIG_PATH}"/pier-fabric-docker.addr
-  fi
-  if [ -e "${PIER_CONFIG_PATH}"/pier-fabric-docker.addr ]; then
-    rm "${PIER_CONFIG_PATH}"/pier-fabric-docker.addr
-  fi
-}
-
-function cleanPierEtherInfoFile() {
-  PIER_CONFIG_PATH="${CURRENT_PATH}"/pier
-
-  if [ -e "${PIER_CONFIG_PATH}"/pier-ethereum.pid ]; then
-    rm "${PIER_CONFIG_PATH}"/pier-ethereum.pid
-  fi
-  if [ -e "${PIER_CONFIG_PATH}"/pier-ethereum-binary.addr ]; then
-    rm "${PIER_CONFIG_PATH}"/pier-ethereum-binary.addr
-  fi
-  if [ -e "${PIER_CONFIG_PATH}"/pier-ethereum-binary.version ]; then
-      rm "${PIER_CONFIG_PATH}"/pier-ethereum-binary.version
+  for file in pier-${APPCHAINTYPE}.pid pier-${APPCHAINTYPE}-binary.addr pier-${APPCHAINTYPE}-binary.version \
+    pier-${APPCHAINTYPE}.cid pier-${APPCHAINTYPE}-docker.addr pier-${APPCHAINTYPE}-docker.version; do
+    if [ -e "${PIER_CONFIG_PATH}"/${file} ]; then
+      rm "${PIER_CONFIG_PATH}"/${file}
     fi
-
-  if [ -e "${PIER_CONFIG_PATH}"/pier-ethereum.cid ]; then
-    rm "${PIER_CONFIG_PATH}"/pier-ethereum.cid
-  fi
-  if [ -e "${PIER_CONFIG_PATH}"/pier-ethereum-docker.addr ]; then
-    rm "${PIER_CONFIG_PATH}"/pier-ethereum-docker.addr
-  fi
-  if [ -e "${PIER_CONFIG_PATH}"/pier-ethereum-docker.addr ]; then
-    rm "${PIER_CONFIG_PATH}"/pier-ethereum-docker.addr
-  fi
+  done
 }
 
 METHOD=""
 OPT=$1
 shift
 
-while getopts "h?a:p:c:u:v:r:m:i:" opt; do
+while getopts "h?a:p:c:u:v:r:m:i:d:g:f:n:t:e:l:" opt; do
   case "$opt" in
   h | \?)
     printHelp
@@ -411,11 +366,32 @@ while getopts "h?a:p:c:u:v:r:m:i:" opt; do
   i)
     PIERCID=$OPTARG
     ;;
+  d)
+    PIER_BIN_PATH=$OPTARG
+    ;;
+  g)
+    PLUGIN=$OPTARG
+    ;;
+  f)
+    PLUGIN_CONFIG=$OPTARG
+    ;;
+  n)
+    APPCHAIN_NAME=$OPTARG
+    ;;
+  t)
+    APPCHAIN_TYPE=$OPTARG
+    ;;
+  e)
+    APPCHAIN_VERSION=$OPTARG
+    ;;
+  l)
+    VALIDATORS=$OPTARG
+    ;;
   esac
 done
 
 PIER_CONFIG_PATH="${CURRENT_PATH}"/pier
-PIER_BIN_PATH="${CURRENT_PATH}/bin/pier_${SYSTEM}_${VERSION}"
+PIER_BIN_PATH=${PIER_BIN_PATH:-"${CURRENT_PATH}/bin/pier_${PLATFORM}_${VERSION}"}
 
 if [ "$OPT" == "up" ]; then
   pier_up